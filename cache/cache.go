@@ -10,48 +10,187 @@ import (
 	"github.com/KarelKubat/goto-meet/l"
 )
 
+// Status tells how an item relates to what the cache already holds.
+type Status int
+
+const (
+	// Added means that the item wasn't seen before. It is now stored.
+	Added Status = iota
+	// Unchanged means that the same version of the item was seen before.
+	Unchanged
+	// Changed means that the item was seen before but has been modified since. The new version is now stored.
+	Changed
+)
+
+// String returns a readable representation of a status.
+func (s Status) String() string {
+	switch s {
+	case Added:
+		return "added"
+	case Unchanged:
+		return "unchanged"
+	case Changed:
+		return "changed"
+	}
+	return fmt.Sprintf("status(%d)", int(s))
+}
+
 // Cache is the receiver that wraps necessary data.
 type Cache struct {
-	m  map[string]*item.Item
-	mu sync.Mutex
+	m       map[string]*item.Item
+	snoozed map[string]time.Time // until when notifications are postponed
+	fired   map[string]time.Time // reminder stages that fired, with the end of their event
+	mu      sync.Mutex
 }
 
 // New returns an initialized cache.
 func New() *Cache {
 	return &Cache{
-		m: map[string]*item.Item{},
+		m:       map[string]*item.Item{},
+		snoozed: map[string]time.Time{},
+		fired:   map[string]time.Time{},
+	}
+}
+
+// Lookup checks whether an item was previously stored. Items that weren't seen before, or that have a
+// different version than the stored one, are stored.
+func (c *Cache) Lookup(it *item.Item) Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := it.Key()
+	prev, ok := c.m[k]
+	switch {
+	case !ok:
+		l.Infof("notification added to cache: %v", it)
+		c.m[k] = it
+		return Added
+	case prev.Version != it.Version:
+		l.Infof("notification changed in cache: %v (version %q, was %q)", it, it.Version, prev.Version)
+		c.m[k] = it
+		return Changed
+	default:
+		return Unchanged
+	}
+}
+
+// Superseded returns true when the cache holds a different version of the item, i.e., when the event
+// was modified after this item was stored.
+func (c *Cache) Superseded(it *item.Item) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev, ok := c.m[it.Key()]
+	return ok && prev.Version != it.Version
+}
+
+// Snooze records that notifications for an item are postponed until the given time. The snooze
+// applies to all versions of the item.
+func (c *Cache) Snooze(it *item.Item, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l.Infof("notification snoozed until %v: %v", until, it)
+	c.snoozed[it.Key()] = until
+}
+
+// SnoozedUntil returns until when notifications for an item are postponed. The time is zero when the
+// item wasn't snoozed.
+func (c *Cache) SnoozedUntil(it *item.Item) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.snoozed[it.Key()]
+}
+
+// EndSnooze removes the snooze of an item that lasts until the given time. It returns false when the
+// item isn't snoozed until then, because it was snoozed again, or because another caller ended the
+// snooze first. This lets one caller claim showing the snoozed notification.
+func (c *Cache) EndSnooze(it *item.Item, until time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := it.Key()
+	if prev, ok := c.snoozed[k]; !ok || !prev.Equal(until) {
+		return false
 	}
+	delete(c.snoozed, k)
+	return true
 }
 
-// Lookup returns true when an item was previously stored. When not, the item is stored and false is returned.
-func (c *Cache) Lookup(it *item.Item) bool {
+// Fire records that the reminder stage with the given lead time fires for an item. It returns false
+// when the stage already fired for the item at its current start, so that a stage fires once, even
+// when the event is modified or the cache is cleared in the meantime. Moving the event lets the stage
+// fire again.
+func (c *Cache) Fire(it *item.Item, before time.Duration) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	k := itemKey(it)
-	if _, ok := c.m[k]; ok {
-		return true
+	k := fmt.Sprintf("%v::%v::%v", it.Key(), before, it.Start.Unix())
+	if _, ok := c.fired[k]; ok {
+		return false
 	}
-	l.Infof("notification added to cache: %v", it)
-	c.m[k] = it
-	return false
+	c.fired[k] = over(it)
+	return true
 }
 
-// Weed removes items with timestamps in the past. These don't have to be kept in memory.
+// FireEnd records that the alert before the end of an item fires. It returns false when the alert
+// already fired for the item at its current end, so that it fires once. Moving the end of the event lets
+// the alert fire again.
+func (c *Cache) FireEnd(it *item.Item) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := fmt.Sprintf("%v::end::%v", it.Key(), it.End.Unix())
+	if _, ok := c.fired[k]; ok {
+		return false
+	}
+	c.fired[k] = over(it)
+	return true
+}
+
+// FireAutoJoin records that an item is joined without asking. It returns false when the item was
+// already joined at its current start, so that it is joined once. Moving the event lets it be joined
+// again.
+func (c *Cache) FireAutoJoin(it *item.Item) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := fmt.Sprintf("%v::autojoin::%v", it.Key(), it.Start.Unix())
+	if _, ok := c.fired[k]; ok {
+		return false
+	}
+	c.fired[k] = over(it)
+	return true
+}
+
+// Weed removes items that are over, and snoozes and fired stages that have expired. These don't have to
+// be kept in memory.
 func (c *Cache) Weed() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	now := time.Now()
 	for k, it := range c.m {
-		if it.Start.Before(now) {
+		if over(it).Before(now) {
 			l.Infof("notification removed from cache: %v (it's in the past)", it)
 			delete(c.m, k)
 		}
 	}
+	for k, until := range c.snoozed {
+		if until.Before(now) {
+			delete(c.snoozed, k)
+		}
+	}
+	for k, until := range c.fired {
+		if until.Before(now) {
+			delete(c.fired, k)
+		}
+	}
 }
 
-// Clear removes all entries from the cache.
+// Clear removes all items from the cache. Snoozes and fired stages are kept, they are stamps that
+// remain valid.
 func (c *Cache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -60,7 +199,11 @@ func (c *Cache) Clear() {
 	c.m = map[string]*item.Item{}
 }
 
-// itemKey is a helper to derive a distinctive key for an item.
-func itemKey(it *item.Item) string {
-	return fmt.Sprintf("%v::%v::%v::%v", it.Title, it.JoinLink, it.CalendarLink, it.Start)
+// over is a helper to determine when an item is over: at its end, or at its start when it has no end.
+// Meetings that started may still be notified, so they are kept until then.
+func over(it *item.Item) time.Time {
+	if it.End.After(it.Start) {
+		return it.End
+	}
+	return it.Start
 }
@@ -1,139 +1,289 @@
 package cache
 
 import (
-	"fmt"
 	"testing"
 	"time"
 
 	"github.com/KarelKubat/goto-meet/item"
 )
 
-func TestItemKey(t *testing.T) {
-	now := time.Now()
-	for _, test := range []struct {
-		title        string
-		joinLink     string
-		calendarLink string
-		start        time.Time
-		wantKey      string
-	}{
-		{
-			title:        "title",
-			joinLink:     "joinLink",
-			calendarLink: "calendarLink",
-			start:        now,
-			wantKey:      fmt.Sprintf("title::joinLink::calendarLink::%v", now.String()),
-		},
-	} {
-		it := &item.Item{
-			Title:        test.title,
-			JoinLink:     test.joinLink,
-			CalendarLink: test.calendarLink,
-			Start:        now,
-		}
-		key := itemKey(it)
-		if key != test.wantKey {
-			t.Errorf("itemKey(%v) = %v, want %v", it, key, test.wantKey)
-		}
-	}
-}
-
 func TestLookup(t *testing.T) {
 	now := time.Now()
 	c := New()
 	items := []struct {
-		title        string
-		joinLink     string
-		calendarLink string
+		calendarID string
+		eventID    string
+		title      string
 	}{
 		{
-			title:        "1",
-			joinLink:     "2",
-			calendarLink: "3",
+			calendarID: "1",
+			eventID:    "2",
+			title:      "same title",
 		},
 		{
-			title:        "4",
-			joinLink:     "5",
-			calendarLink: "6",
+			calendarID: "4",
+			eventID:    "5",
+			title:      "same title",
 		},
 	}
 
 	// Insert items, they must be added
 	for _, test := range items {
 		it := &item.Item{
-			Title:        test.title,
-			JoinLink:     test.joinLink,
-			CalendarLink: test.calendarLink,
-			Start:        now,
+			CalendarID: test.calendarID,
+			EventID:    test.eventID,
+			Version:    "v1",
+			Title:      test.title,
+			Start:      now,
 		}
-		if c.Lookup(it) {
-			t.Errorf("Lookup(%v) = true", it)
+		if st := c.Lookup(it); st != Added {
+			t.Errorf("Lookup(%v) = %v, want %v", it, st, Added)
 		}
 	}
 	// Do it again, they must already be present
 	for _, test := range items {
 		it := &item.Item{
-			Title:        test.title,
-			JoinLink:     test.joinLink,
-			CalendarLink: test.calendarLink,
-			Start:        now,
+			CalendarID: test.calendarID,
+			EventID:    test.eventID,
+			Version:    "v1",
+			Title:      test.title,
+			Start:      now,
+		}
+		if st := c.Lookup(it); st != Unchanged {
+			t.Errorf("Lookup(%v) = %v, want %v", it, st, Unchanged)
+		}
+	}
+	// Renamed events with a new version are changed, and then unchanged
+	for _, test := range items {
+		it := &item.Item{
+			CalendarID: test.calendarID,
+			EventID:    test.eventID,
+			Version:    "v2",
+			Title:      "renamed",
+			Start:      now,
+		}
+		if st := c.Lookup(it); st != Changed {
+			t.Errorf("Lookup(%v) = %v, want %v", it, st, Changed)
 		}
-		if !c.Lookup(it) {
-			t.Errorf("Lookup(%v) = false", it)
+		if st := c.Lookup(it); st != Unchanged {
+			t.Errorf("Lookup(%v) = %v, want %v", it, st, Unchanged)
 		}
 	}
 }
 
+func TestSuperseded(t *testing.T) {
+	c := New()
+	v1 := &item.Item{CalendarID: "cal", EventID: "ev", Version: "v1"}
+	v2 := &item.Item{CalendarID: "cal", EventID: "ev", Version: "v2"}
+
+	if c.Superseded(v1) {
+		t.Errorf("Superseded(%v) = true for an empty cache, want false", v1)
+	}
+	c.Lookup(v1)
+	if c.Superseded(v1) {
+		t.Errorf("Superseded(%v) = true after storing it, want false", v1)
+	}
+	c.Lookup(v2)
+	if !c.Superseded(v1) {
+		t.Errorf("Superseded(%v) = false after storing %v, want true", v1, v2)
+	}
+	if c.Superseded(v2) {
+		t.Errorf("Superseded(%v) = true after storing it, want false", v2)
+	}
+}
+
 func TestWeed(t *testing.T) {
 	before := time.Now().Add(time.Hour * -1)
 	after := time.Now().Add(time.Hour)
 	c := New()
 
 	items := []struct {
-		title        string
-		joinLink     string
-		calendarLink string
-		start        time.Time
-		wantFound    bool // want this to be found after weeding?
+		calendarID string
+		eventID    string
+		start      time.Time
+		end        time.Time
+		wantStatus Status // status of a lookup after weeding
 	}{
 		{
-			title:        "1",
-			joinLink:     "2",
-			calendarLink: "3",
-			start:        after,
-			wantFound:    true,
+			calendarID: "1",
+			eventID:    "2",
+			start:      after,
+			wantStatus: Unchanged,
+		},
+		{
+			calendarID: "4",
+			eventID:    "5",
+			start:      before,
+			wantStatus: Added,
 		},
 		{
-			title:        "4",
-			joinLink:     "5",
-			calendarLink: "6",
-			start:        before,
-			wantFound:    false,
+			// Meetings in progress are kept.
+			calendarID: "6",
+			eventID:    "7",
+			start:      before,
+			end:        after,
+			wantStatus: Unchanged,
 		},
 	}
 
 	// Add items, all must be flagged as "added"
 	for _, test := range items {
 		it := &item.Item{
-			Title:        test.title,
-			JoinLink:     test.joinLink,
-			CalendarLink: test.calendarLink,
-			Start:        test.start,
+			CalendarID: test.calendarID,
+			EventID:    test.eventID,
+			Start:      test.start,
+			End:        test.end,
 		}
-		if c.Lookup(it) {
-			t.Errorf("Lookup(%v) = false", it)
+		if st := c.Lookup(it); st != Added {
+			t.Errorf("Lookup(%v) = %v, want %v", it, st, Added)
 		}
 	}
 	c.Weed()
 	for _, test := range items {
 		it := &item.Item{
-			Title:        test.title,
-			JoinLink:     test.joinLink,
-			CalendarLink: test.calendarLink,
-			Start:        test.start,
+			CalendarID: test.calendarID,
+			EventID:    test.eventID,
+			Start:      test.start,
+			End:        test.end,
 		}
-		if found := c.Lookup(it); found != test.wantFound {
-			t.Errorf("Lookup(%v) = %v, want %v", it, found, test.wantFound)
+		if st := c.Lookup(it); st != test.wantStatus {
+			t.Errorf("Lookup(%v) = %v, want %v", it, st, test.wantStatus)
+		}
+	}
+}
+
+func TestSnooze(t *testing.T) {
+	c := New()
+	v1 := &item.Item{CalendarID: "cal", EventID: "ev", Version: "v1"}
+	v2 := &item.Item{CalendarID: "cal", EventID: "ev", Version: "v2"}
+	other := &item.Item{CalendarID: "cal", EventID: "other"}
+
+	if got := c.SnoozedUntil(v1); !got.IsZero() {
+		t.Errorf("SnoozedUntil(%v) = %v for an empty cache, want zero", v1, got)
+	}
+	until := time.Now().Add(time.Minute)
+	c.Snooze(v1, until)
+	c.Clear()
+	// Snoozes survive clearing and apply to all versions.
+	if got := c.SnoozedUntil(v2); !got.Equal(until) {
+		t.Errorf("SnoozedUntil(%v) = %v, want %v", v2, got, until)
+	}
+	if got := c.SnoozedUntil(other); !got.IsZero() {
+		t.Errorf("SnoozedUntil(%v) = %v, want zero", other, got)
+	}
+
+	// Expired snoozes are weeded.
+	c.Snooze(other, time.Now().Add(-time.Second))
+	c.Weed()
+	if got := c.SnoozedUntil(other); !got.IsZero() {
+		t.Errorf("SnoozedUntil(%v) after Weed() = %v, want zero", other, got)
+	}
+	if got := c.SnoozedUntil(v1); !got.Equal(until) {
+		t.Errorf("SnoozedUntil(%v) after Weed() = %v, want %v", v1, got, until)
+	}
+
+	// A snooze is ended once, and only when it lasts until the given time.
+	if c.EndSnooze(v1, until.Add(time.Second)) {
+		t.Errorf("EndSnooze(%v) with another time = true, want false", v1)
+	}
+	if !c.EndSnooze(v2, until) {
+		t.Errorf("EndSnooze(%v) = false, want true", v2)
+	}
+	if c.EndSnooze(v1, until) {
+		t.Errorf("EndSnooze(%v) a second time = true, want false", v1)
+	}
+	if got := c.SnoozedUntil(v1); !got.IsZero() {
+		t.Errorf("SnoozedUntil(%v) after EndSnooze() = %v, want zero", v1, got)
+	}
+}
+
+func TestFire(t *testing.T) {
+	c := New()
+	start := time.Now().Add(time.Hour)
+	v1 := &item.Item{CalendarID: "cal", EventID: "ev", Version: "v1", Start: start}
+	v2 := &item.Item{CalendarID: "cal", EventID: "ev", Version: "v2", Start: start}
+	moved := &item.Item{CalendarID: "cal", EventID: "ev", Version: "v3", Start: start.Add(time.Hour)}
+
+	for _, test := range []struct {
+		it     *item.Item
+		before time.Duration
+		want   bool
+	}{
+		{it: v1, before: time.Minute * 10, want: true},
+		{it: v1, before: time.Minute, want: true},
+		{it: v1, before: time.Minute * 10, want: false},
+		// Modifications don't matter, moving the event does.
+		{it: v2, before: time.Minute * 10, want: false},
+		{it: moved, before: time.Minute * 10, want: true},
+	} {
+		c.Clear()
+		if got := c.Fire(test.it, test.before); got != test.want {
+			t.Errorf("Fire(%v, %v) = %v, want %v", test.it, test.before, got, test.want)
+		}
+	}
+
+	// Stages of past events are weeded.
+	past := &item.Item{CalendarID: "cal", EventID: "past", Start: time.Now().Add(-time.Minute)}
+	c.Fire(past, 0)
+	c.Weed()
+	if !c.Fire(past, 0) {
+		t.Errorf("Fire(%v, 0) after Weed() = false, want true", past)
+	}
+	// Stages of meetings in progress are kept.
+	ongoing := &item.Item{CalendarID: "cal", EventID: "ongoing", Start: time.Now().Add(-time.Minute), End: time.Now().Add(time.Hour)}
+	c.Fire(ongoing, 0)
+	c.Weed()
+	if c.Fire(ongoing, 0) {
+		t.Errorf("Fire(%v, 0) after Weed() = true, want false", ongoing)
+	}
+}
+
+func TestFireEnd(t *testing.T) {
+	c := New()
+	start := time.Now().Add(time.Hour)
+	v1 := &item.Item{CalendarID: "cal", EventID: "ev", Version: "v1", Start: start, End: start.Add(time.Hour)}
+	v2 := &item.Item{CalendarID: "cal", EventID: "ev", Version: "v2", Start: start, End: start.Add(time.Hour)}
+	longer := &item.Item{CalendarID: "cal", EventID: "ev", Version: "v3", Start: start, End: start.Add(time.Hour * 2)}
+
+	for _, test := range []struct {
+		it   *item.Item
+		want bool
+	}{
+		{it: v1, want: true},
+		{it: v1, want: false},
+		// Modifications don't matter, moving the end does.
+		{it: v2, want: false},
+		{it: longer, want: true},
+	} {
+		if got := c.FireEnd(test.it); got != test.want {
+			t.Errorf("FireEnd(%v) = %v, want %v", test.it, got, test.want)
+		}
+	}
+	// End alerts don't count as reminder stages.
+	if !c.Fire(v1, 0) {
+		t.Errorf("Fire(%v, 0) after FireEnd() = false, want true", v1)
+	}
+}
+
+func TestFireAutoJoin(t *testing.T) {
+	c := New()
+	start := time.Now().Add(time.Hour)
+	v1 := &item.Item{CalendarID: "cal", EventID: "ev", Version: "v1", Start: start}
+	v2 := &item.Item{CalendarID: "cal", EventID: "ev", Version: "v2", Start: start}
+	moved := &item.Item{CalendarID: "cal", EventID: "ev", Version: "v3", Start: start.Add(time.Hour)}
+
+	for _, test := range []struct {
+		it   *item.Item
+		want bool
+	}{
+		{it: v1, want: true},
+		{it: v1, want: false},
+		// Modifications don't matter, moving the event does.
+		{it: v2, want: false},
+		{it: moved, want: true},
+	} {
+		if got := c.FireAutoJoin(test.it); got != test.want {
+			t.Errorf("FireAutoJoin(%v) = %v, want %v", test.it, got, test.want)
 		}
 	}
 }
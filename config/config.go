@@ -0,0 +1,533 @@
+// Package config wraps the optional configuration file of goto-meet.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Config is the receiver that holds the contents of a configuration file.
+type Config struct {
+	Notifiers []*Notifier `json:"notifiers"` // user-defined notification types
+	Webhooks  []*Webhook  `json:"webhooks"`  // notification types that post to URLs
+	Emails    []*Email    `json:"emails"`    // notification types that send email
+	MQTT      *MQTT       `json:"mqtt"`      // broker to publish the lifecycle of meetings to, may be nil
+	Stages    []*Stage    `json:"stages"`    // reminders before each event, none for one at --starts-in
+	Quiet     *Quiet      `json:"quiet"`     // when notifications are suppressed, may be nil
+	Hooks     []*Hook     `json:"hooks"`     // commands that run around meetings
+	AutoJoin  []*AutoJoin `json:"auto_join"` // meetings that are joined without asking
+	Browsers  []*Browser  `json:"browsers"`  // which browser opens which links, first match wins
+	Sound     *Sound      `json:"sound"`     // audible alert along with the notification types of --notification, may be nil
+}
+
+// Duration is a time.Duration that is represented in JSON as a string, such as "1m30s".
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("durations must be strings such as \"1m30s\": %v", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Notifier defines a notification type that runs an external command.
+type Notifier struct {
+	Name     string   `json:"name"`     // name to use in --notification=NAME
+	Command  []string `json:"command"`  // program and arguments, each a template
+	Input    string   `json:"input"`    // "stdin" (default) or "args": how to pass the expanded template
+	Template string   `json:"template"` // template to expand for the command
+	Escape   string   `json:"escape"`   // how template values are escaped: "none", "applescript", "shell", "json" or "pango"; required
+	Actions  []*Match `json:"actions"`  // how to interpret the outcome of the command, first match wins
+	Modal    bool     `json:"modal"`    // the command shows a dialog, which waits for other dialogs to go away
+}
+
+// Match maps the outcome of a notifier command to an action.
+type Match struct {
+	Output   string `json:"output"`    // regexp that the output of the command must match, "" matches anything
+	ExitCode *int   `json:"exit_code"` // exit code that the command must return, absent matches any
+	Action   string `json:"action"`    // "join", "calendar", "notes", "mute", "skip", "snooze", "snooze 5m", "snooze until start" or "none"
+}
+
+// Webhook defines a notification type that posts a JSON payload to URLs.
+type Webhook struct {
+	Name            string            `json:"name"`             // name to use in --notification=NAME
+	URLs            []string          `json:"urls"`             // where to post to
+	Template        string            `json:"template"`         // template for the JSON body, "" for a default payload
+	Headers         map[string]string `json:"headers"`          // extra HTTP headers
+	Secret          string            `json:"secret"`           // key to sign the body with (HMAC-SHA256), "" for no signature
+	SignatureHeader string            `json:"signature_header"` // header that holds the signature, default X-Signature-256
+	Retries         *int              `json:"retries"`          // number of retries after a failure, default 3
+	Backoff         Duration          `json:"backoff"`          // wait time before the first retry, doubled for each next one, default 1s
+	Timeout         Duration          `json:"timeout"`          // timeout of each post, default 10s
+}
+
+// Defaults of webhooks.
+const (
+	DefaultSignatureHeader = "X-Signature-256"
+	DefaultRetries         = 3
+	DefaultBackoff         = Duration(time.Second)
+	DefaultTimeout         = Duration(time.Second * 10)
+)
+
+// Email defines a notification type that sends reminders through an SMTP server.
+type Email struct {
+	Name       string       `json:"name"`       // name to use in --notification=NAME
+	Server     string       `json:"server"`     // SMTP server as host:port
+	StartTLS   *bool        `json:"starttls"`   // require STARTTLS, default true
+	Username   string       `json:"username"`   // user to authenticate as, "" for no authentication
+	Password   string       `json:"password"`   // password of the user
+	From       string       `json:"from"`       // sender address
+	Subject    string       `json:"subject"`    // template for the subject, "" for a default
+	Body       string       `json:"body"`       // template for the body, "" for a default
+	Recipients []*Recipient `json:"recipients"` // who to send reminders to
+	Timeout    Duration     `json:"timeout"`    // timeout of a conversation with the server, default 30s
+}
+
+// Recipient is an addressee of an email reminder, optionally with their own templates.
+type Recipient struct {
+	Address string `json:"address"` // recipient address
+	Subject string `json:"subject"` // template for the subject, "" for the one of the email
+	Body    string `json:"body"`    // template for the body, "" for the one of the email
+}
+
+// DefaultEmailTimeout is the default timeout of a conversation with an SMTP server.
+const DefaultEmailTimeout = Duration(time.Second * 30)
+
+// MQTT defines a broker to publish the lifecycle of meetings to.
+type MQTT struct {
+	Broker    string     `json:"broker"`     // broker as host:port
+	TLS       bool       `json:"tls"`        // connect using TLS
+	ClientID  string     `json:"client_id"`  // identification towards the broker, default goto-meet-HOSTNAME
+	Username  string     `json:"username"`   // user to authenticate as, "" for no authentication
+	Password  string     `json:"password"`   // password of the user
+	QoS       int        `json:"qos"`        // quality of service: 0 (default), 1 or 2
+	Topics    MQTTTopics `json:"topics"`     // where to publish to
+	KeepAlive Duration   `json:"keep_alive"` // interval at which the connection is kept alive, default 1m
+	Timeout   Duration   `json:"timeout"`    // timeout of connecting and of each exchange with the broker, default 10s
+}
+
+// MQTTTopics are the topics to publish to. Except for Status, they are templates that can use
+// {{.Account}}.
+type MQTTTopics struct {
+	Status  string `json:"status"`  // "online" or "offline"
+	Event   string `json:"event"`   // the last change of any meeting
+	State   string `json:"state"`   // "idle", "upcoming", "starting" or "in-progress"
+	Current string `json:"current"` // the meeting in progress
+	Next    string `json:"next"`    // the next meeting
+}
+
+// Defaults of MQTT.
+const (
+	DefaultMQTTStatusTopic  = "goto-meet/status"
+	DefaultMQTTEventTopic   = "goto-meet/{{.Account}}/event"
+	DefaultMQTTStateTopic   = "goto-meet/{{.Account}}/state"
+	DefaultMQTTCurrentTopic = "goto-meet/{{.Account}}/current"
+	DefaultMQTTNextTopic    = "goto-meet/{{.Account}}/next"
+	DefaultMQTTKeepAlive    = Duration(time.Minute)
+	DefaultMQTTTimeout      = Duration(time.Second * 10)
+)
+
+// Stage defines a reminder at a lead time before events, through its own notification types.
+type Stage struct {
+	Before       Duration `json:"before"`       // lead time, "0s" is at the start of the event
+	Notification string   `json:"notification"` // comma-separated notification types, "" for those of --notification
+	Sound        *Sound   `json:"sound"`        // audible alert along with the notification, may be nil
+}
+
+// Sound defines an audible alert: a sound file that is played, or the meeting that is spoken. Sounds
+// play for the reminders before meetings start, and for the other alerts that they list.
+type Sound struct {
+	File    string   `json:"file"`    // sound file to play, "" to speak the title and time to start
+	Command []string `json:"command"` // program and arguments, each a template; default afplay/say on macOS, paplay/espeak-ng elsewhere
+	Alerts  []string `json:"alerts"`  // other alerts that play the sound: AlertEnd and AlertAutoJoin, none by default
+}
+
+// Alerts that sounds can play for, next to the reminders before meetings start.
+const (
+	AlertEnd      = "end"       // the alerts before meetings end, see --ends-in
+	AlertAutoJoin = "auto_join" // the countdowns of auto-joins
+)
+
+// Quiet defines when notifications are suppressed: outside working hours, and during events such as
+// out-of-office time.
+type Quiet struct {
+	WorkingHours    []*WorkingHours `json:"working_hours"`    // when notifications are shown, none for any time
+	EventTypes      []string        `json:"event_types"`      // calendar event types that suppress notifications while they last, default outOfOffice and focusTime
+	WorkingLocation bool            `json:"working_location"` // days with working location events are working days, the times of these events are the working hours
+}
+
+// WorkingHours is a period on days of the week.
+type WorkingHours struct {
+	Days []string `json:"days"` // "mon", "tue" etc., absent for every day
+	From string   `json:"from"` // start as "09:00"
+	To   string   `json:"to"`   // end as "17:30", "24:00" for midnight
+}
+
+// ClockLayout is the layout of the times of working hours.
+const ClockLayout = "15:04"
+
+// Weekdays are the names of the days of working hours.
+var Weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// DefaultQuietEventTypes are the default event types that suppress notifications.
+var DefaultQuietEventTypes = []string{"outOfOffice", "focusTime"}
+
+// ParseClock converts a time of working hours, such as "09:00", to the duration since midnight.
+// "24:00" is accepted as the end of a day.
+func ParseClock(s string) (time.Duration, error) {
+	if s == "24:00" {
+		return time.Hour * 24, nil
+	}
+	t, err := time.Parse(ClockLayout, s)
+	if err != nil {
+		return 0, fmt.Errorf("times must be formatted as HH:MM: %v", err)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Hook defines a command that runs around meetings.
+type Hook struct {
+	Name    string   `json:"name"`    // name for logging, default the program
+	When    string   `json:"when"`    // HookStart (default), HookJoin or HookEnd
+	Offset  Duration `json:"offset"`  // for HookStart: how long before the start, for HookEnd: how long after the end
+	Command []string `json:"command"` // program and arguments
+	Timeout Duration `json:"timeout"` // max run time, default 30s
+}
+
+// Moments at which hooks run.
+const (
+	HookStart = "start" // at the offset before the start of a meeting
+	HookJoin  = "join"  // when the user chooses to join a meeting
+	HookEnd   = "end"   // at the offset after the end of a meeting
+)
+
+// DefaultHookTimeout is the default max run time of a hook.
+const DefaultHookTimeout = Duration(time.Second * 30)
+
+// AutoJoin defines meetings that are joined without asking, after a countdown that the user can cancel.
+// A meeting must match both the title and the calendars.
+type AutoJoin struct {
+	Title     string   `json:"title"`     // regexp that the title must match, "" matches anything
+	Calendars []string `json:"calendars"` // calendars that hold the meetings, empty for any
+	Before    Duration `json:"before"`    // how long before the start to join, default at the start
+	Countdown Duration `json:"countdown"` // how long the user can cancel, default 10s
+}
+
+// DefaultCountdown is the default time to cancel joining a meeting.
+const DefaultCountdown = Duration(time.Second * 10)
+
+// Browser routes the links of meetings to a browser. A rule applies when the link, the provider, the
+// calendar and the account all match; empty ones match anything.
+type Browser struct {
+	Link        string   `json:"link"`        // LinkJoin, LinkCalendar or LinkNotes, "" for all
+	Provider    string   `json:"provider"`    // provider of the join link, one of Providers or ProviderOther
+	Calendars   []string `json:"calendars"`   // calendars that hold the meetings
+	Accounts    []string `json:"accounts"`    // email addresses of the account, or domains as "@example.com"
+	Application string   `json:"application"` // browser, e.g. "Google Chrome" on macOS or "google-chrome" on Linux; "" for the default browser
+	Args        []string `json:"args"`        // arguments for the browser, e.g. "--new-window"
+}
+
+// Kinds of links that browsers are routed for.
+const (
+	LinkJoin     = "join"     // the link to join a meeting
+	LinkCalendar = "calendar" // the link to the event in the calendar
+	LinkNotes    = "notes"    // the links to the attachments and documents of a meeting
+)
+
+// Providers maps the providers of join links to the hosts of their links. Subdomains of the hosts
+// match too.
+var Providers = map[string][]string{
+	"meet":  {"meet.google.com"},
+	"teams": {"teams.microsoft.com", "teams.live.com"},
+	"zoom":  {"zoom.us"},
+	"webex": {"webex.com"},
+}
+
+// ProviderOther is the provider of join links that match none of Providers.
+const ProviderOther = "other"
+
+// Input modes of a notifier.
+const (
+	InputStdin = "stdin" // the expanded template is piped to the command
+	InputArgs  = "args"  // the expanded template is the last argument of the command
+)
+
+// Load reads a configuration file. A non-existing file results in an empty configuration.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q: %v", path, err)
+	}
+	cfg := &Config{}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %v", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%q: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// validate is a helper to check a loaded configuration and to fill in defaults.
+func (c *Config) validate() error {
+	seen := map[string]struct{}{}
+	checkName := func(kind string, i int, name string) error {
+		if name == "" {
+			return fmt.Errorf("%s %d has no name", kind, i)
+		}
+		if _, ok := seen[name]; ok {
+			return fmt.Errorf("%s %q: name is defined more than once", kind, name)
+		}
+		seen[name] = struct{}{}
+		return nil
+	}
+
+	for i, n := range c.Notifiers {
+		if err := checkName("notifier", i, n.Name); err != nil {
+			return err
+		}
+		if len(n.Command) == 0 {
+			return fmt.Errorf("notifier %q has no command", n.Name)
+		}
+		if strings.TrimSpace(n.Command[0]) == "" {
+			return fmt.Errorf("notifier %q: command must start with a program", n.Name)
+		}
+		// Titles come from invites that anyone can send, so the escaping is a conscious choice.
+		if n.Escape == "" {
+			return fmt.Errorf("notifier %q has no escape, use \"none\", \"applescript\", \"shell\", \"json\" or \"pango\"", n.Name)
+		}
+		switch n.Input {
+		case "":
+			n.Input = InputStdin
+		case InputStdin, InputArgs:
+		default:
+			return fmt.Errorf("notifier %q: input must be %q or %q, not %q", n.Name, InputStdin, InputArgs, n.Input)
+		}
+	}
+
+	for i, w := range c.Webhooks {
+		if err := checkName("webhook", i, w.Name); err != nil {
+			return err
+		}
+		if len(w.URLs) == 0 {
+			return fmt.Errorf("webhook %q has no urls", w.Name)
+		}
+		if w.SignatureHeader == "" {
+			w.SignatureHeader = DefaultSignatureHeader
+		}
+		if w.Retries == nil {
+			r := DefaultRetries
+			w.Retries = &r
+		}
+		if *w.Retries < 0 {
+			return fmt.Errorf("webhook %q: retries may not be negative", w.Name)
+		}
+		if w.Backoff == 0 {
+			w.Backoff = DefaultBackoff
+		}
+		if w.Timeout == 0 {
+			w.Timeout = DefaultTimeout
+		}
+	}
+
+	for i, e := range c.Emails {
+		if err := checkName("email", i, e.Name); err != nil {
+			return err
+		}
+		if _, _, err := net.SplitHostPort(e.Server); err != nil {
+			return fmt.Errorf("email %q: server must be host:port: %v", e.Name, err)
+		}
+		if _, err := mail.ParseAddress(e.From); err != nil {
+			return fmt.Errorf("email %q: bad from address %q: %v", e.Name, e.From, err)
+		}
+		if len(e.Recipients) == 0 {
+			return fmt.Errorf("email %q has no recipients", e.Name)
+		}
+		for _, r := range e.Recipients {
+			if _, err := mail.ParseAddress(r.Address); err != nil {
+				return fmt.Errorf("email %q: bad recipient address %q: %v", e.Name, r.Address, err)
+			}
+		}
+		if e.StartTLS == nil {
+			t := true
+			e.StartTLS = &t
+		}
+		if e.Timeout == 0 {
+			e.Timeout = DefaultEmailTimeout
+		}
+	}
+
+	checkSound := func(s *Sound) error {
+		if s == nil {
+			return nil
+		}
+		for _, a := range s.Alerts {
+			if a != AlertEnd && a != AlertAutoJoin {
+				return fmt.Errorf("alerts must be %q or %q, not %q", AlertEnd, AlertAutoJoin, a)
+			}
+		}
+		return nil
+	}
+	if err := checkSound(c.Sound); err != nil {
+		return fmt.Errorf("sound: %v", err)
+	}
+
+	leads := map[Duration]struct{}{}
+	for _, st := range c.Stages {
+		if st.Before < 0 {
+			return fmt.Errorf("stage %v: lead time may not be negative", st.Before)
+		}
+		if _, ok := leads[st.Before]; ok {
+			return fmt.Errorf("stage %v: lead time is defined more than once", st.Before)
+		}
+		leads[st.Before] = struct{}{}
+		if err := checkSound(st.Sound); err != nil {
+			return fmt.Errorf("stage %v: sound: %v", st.Before, err)
+		}
+	}
+
+	if q := c.Quiet; q != nil {
+		if q.EventTypes == nil {
+			q.EventTypes = DefaultQuietEventTypes
+		}
+		for i, wh := range q.WorkingHours {
+			for _, d := range wh.Days {
+				if _, ok := Weekdays[d]; !ok {
+					return fmt.Errorf("quiet: working hours %d: no such day %q, use mon, tue, wed, thu, fri, sat or sun", i, d)
+				}
+			}
+			from, err := ParseClock(wh.From)
+			if err != nil {
+				return fmt.Errorf("quiet: working hours %d: bad from: %v", i, err)
+			}
+			to, err := ParseClock(wh.To)
+			if err != nil {
+				return fmt.Errorf("quiet: working hours %d: bad to: %v", i, err)
+			}
+			if from >= to {
+				return fmt.Errorf("quiet: working hours %d: from must be before to", i)
+			}
+		}
+	}
+
+	for i, h := range c.Hooks {
+		if len(h.Command) == 0 {
+			return fmt.Errorf("hook %d has no command", i)
+		}
+		if h.Name == "" {
+			h.Name = h.Command[0]
+		}
+		switch h.When {
+		case "":
+			h.When = HookStart
+		case HookStart, HookJoin, HookEnd:
+		default:
+			return fmt.Errorf("hook %q: when must be %q, %q or %q, not %q", h.Name, HookStart, HookJoin, HookEnd, h.When)
+		}
+		if h.Offset < 0 {
+			return fmt.Errorf("hook %q: offset may not be negative", h.Name)
+		}
+		if h.Timeout == 0 {
+			h.Timeout = DefaultHookTimeout
+		}
+	}
+
+	for i, a := range c.AutoJoin {
+		if a.Title == "" && len(a.Calendars) == 0 {
+			return fmt.Errorf("auto_join %d matches every meeting, give a title or calendars", i)
+		}
+		if _, err := regexp.Compile(a.Title); err != nil {
+			return fmt.Errorf("auto_join %d: bad title: %v", i, err)
+		}
+		if a.Before < 0 {
+			return fmt.Errorf("auto_join %d: before may not be negative", i)
+		}
+		if a.Countdown < 0 {
+			return fmt.Errorf("auto_join %d: countdown may not be negative", i)
+		}
+		if a.Countdown == 0 {
+			a.Countdown = DefaultCountdown
+		}
+	}
+
+	for i, b := range c.Browsers {
+		switch b.Link {
+		case "", LinkJoin, LinkCalendar, LinkNotes:
+		default:
+			return fmt.Errorf("browser %d: link must be %q, %q or %q, not %q", i, LinkJoin, LinkCalendar, LinkNotes, b.Link)
+		}
+		if _, ok := Providers[b.Provider]; !ok && b.Provider != "" && b.Provider != ProviderOther {
+			return fmt.Errorf("browser %d: no such provider %q", i, b.Provider)
+		}
+		if len(b.Args) > 0 && b.Application == "" {
+			return fmt.Errorf("browser %d: args need an application", i)
+		}
+	}
+
+	if m := c.MQTT; m != nil {
+		if _, _, err := net.SplitHostPort(m.Broker); err != nil {
+			return fmt.Errorf("mqtt: broker must be host:port: %v", err)
+		}
+		if m.QoS < 0 || m.QoS > 2 {
+			return fmt.Errorf("mqtt: qos must be 0, 1 or 2, not %v", m.QoS)
+		}
+		if m.ClientID == "" {
+			host, err := os.Hostname()
+			if err != nil {
+				host = "unknown"
+			}
+			m.ClientID = "goto-meet-" + host
+		}
+		for _, t := range []struct {
+			topic *string
+			def   string
+		}{
+			{&m.Topics.Status, DefaultMQTTStatusTopic},
+			{&m.Topics.Event, DefaultMQTTEventTopic},
+			{&m.Topics.State, DefaultMQTTStateTopic},
+			{&m.Topics.Current, DefaultMQTTCurrentTopic},
+			{&m.Topics.Next, DefaultMQTTNextTopic},
+		} {
+			if *t.topic == "" {
+				*t.topic = t.def
+			}
+		}
+		if m.KeepAlive == 0 {
+			m.KeepAlive = DefaultMQTTKeepAlive
+		}
+		if m.Timeout == 0 {
+			m.Timeout = DefaultMQTTTimeout
+		}
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	for _, test := range []struct {
+		contents  string
+		wantError string
+	}{
+		{
+			// Empty but valid
+			contents: `{}`,
+		},
+		{
+			contents: `{"notifiers": [{"name": "n", "command": ["notify-send"], "input": "args", "escape": "pango"}]}`,
+		},
+		{
+			contents:  `{"notifiers": [{"name": "n", "command": ["notify-send"]}]}`,
+			wantError: "has no escape",
+		},
+		{
+			contents:  `{"notifiers": [`,
+			wantError: "cannot parse",
+		},
+		{
+			contents:  `{"notifiers": [{"command": ["notify-send"]}]}`,
+			wantError: "has no name",
+		},
+		{
+			contents:  `{"notifiers": [{"name": "n"}]}`,
+			wantError: "has no command",
+		},
+		{
+			contents:  `{"notifiers": [{"name": "n", "command": [" ", "x"]}]}`,
+			wantError: "must start with a program",
+		},
+		{
+			contents:  `{"notifiers": [{"name": "n", "command": ["x"], "escape": "none"}, {"name": "n", "command": ["y"], "escape": "none"}]}`,
+			wantError: "more than once",
+		},
+		{
+			contents:  `{"notifiers": [{"name": "n", "command": ["x"], "escape": "none", "input": "telepathy"}]}`,
+			wantError: "input must be",
+		},
+		{
+			contents: `{"webhooks": [{"name": "w", "urls": ["https://chat"], "backoff": "2s", "retries": 0}]}`,
+		},
+		{
+			contents:  `{"webhooks": [{"name": "w"}]}`,
+			wantError: "has no urls",
+		},
+		{
+			contents:  `{"notifiers": [{"name": "n", "command": ["x"], "escape": "none"}], "webhooks": [{"name": "n", "urls": ["https://chat"]}]}`,
+			wantError: "more than once",
+		},
+		{
+			contents:  `{"webhooks": [{"name": "w", "urls": ["https://chat"], "retries": -1}]}`,
+			wantError: "may not be negative",
+		},
+		{
+			contents:  `{"webhooks": [{"name": "w", "urls": ["https://chat"], "backoff": 2}]}`,
+			wantError: "durations must be strings",
+		},
+		{
+			contents:  `{"webhooks": [{"name": "w", "urls": ["https://chat"], "backoff": "2 parsecs"}]}`,
+			wantError: "2 parsecs",
+		},
+		{
+			contents: `{"emails": [{"name": "e", "server": "smtp:587", "from": "me@example.com", "recipients": [{"address": "Me <me@example.com>"}]}]}`,
+		},
+		{
+			contents:  `{"emails": [{"name": "e", "server": "smtp", "from": "me@example.com", "recipients": [{"address": "me@example.com"}]}]}`,
+			wantError: "host:port",
+		},
+		{
+			contents:  `{"emails": [{"name": "e", "server": "smtp:587", "from": "me", "recipients": [{"address": "me@example.com"}]}]}`,
+			wantError: "bad from address",
+		},
+		{
+			contents:  `{"emails": [{"name": "e", "server": "smtp:587", "from": "me@example.com"}]}`,
+			wantError: "has no recipients",
+		},
+		{
+			contents:  `{"emails": [{"name": "e", "server": "smtp:587", "from": "me@example.com", "recipients": [{"address": "you"}]}]}`,
+			wantError: "bad recipient address",
+		},
+		{
+			contents: `{"mqtt": {"broker": "localhost:1883", "qos": 1, "topics": {"state": "office/{{.Account}}/lamp"}}}`,
+		},
+		{
+			contents:  `{"mqtt": {"broker": "localhost"}}`,
+			wantError: "host:port",
+		},
+		{
+			contents:  `{"mqtt": {"broker": "localhost:1883", "qos": 3}}`,
+			wantError: "qos must be",
+		},
+		{
+			contents: `{"stages": [{"before": "10m", "notification": "linux_dbus"}, {"before": "1m"}, {"before": "0s"}]}`,
+		},
+		{
+			contents:  `{"stages": [{"before": "-1m"}]}`,
+			wantError: "may not be negative",
+		},
+		{
+			contents:  `{"stages": [{"before": "1m"}, {"before": "60s"}]}`,
+			wantError: "more than once",
+		},
+		{
+			contents: `{"sound": {"alerts": ["end", "auto_join"]}, "stages": [{"before": "0s", "sound": {"alerts": ["auto_join"]}}]}`,
+		},
+		{
+			contents:  `{"sound": {"alerts": ["start"]}}`,
+			wantError: "alerts must be",
+		},
+		{
+			contents:  `{"stages": [{"before": "0s", "sound": {"alerts": ["ending"]}}]}`,
+			wantError: "alerts must be",
+		},
+		{
+			contents: `{"quiet": {"working_hours": [{"days": ["mon", "fri"], "from": "09:00", "to": "17:30"}, {"from": "20:00", "to": "24:00"}], "working_location": true}}`,
+		},
+		{
+			contents:  `{"quiet": {"working_hours": [{"days": ["monday"], "from": "09:00", "to": "17:30"}]}}`,
+			wantError: "no such day",
+		},
+		{
+			contents:  `{"quiet": {"working_hours": [{"from": "9am", "to": "17:30"}]}}`,
+			wantError: "bad from",
+		},
+		{
+			contents:  `{"quiet": {"working_hours": [{"from": "17:30", "to": "09:00"}]}}`,
+			wantError: "from must be before to",
+		},
+		{
+			contents: `{"hooks": [{"command": ["playerctl", "pause"], "offset": "2m"}, {"when": "join", "command": ["x"]}, {"when": "end", "command": ["x"]}]}`,
+		},
+		{
+			contents:  `{"hooks": [{"name": "h"}]}`,
+			wantError: "has no command",
+		},
+		{
+			contents:  `{"hooks": [{"when": "later", "command": ["x"]}]}`,
+			wantError: "when must be",
+		},
+		{
+			contents:  `{"hooks": [{"command": ["x"], "offset": "-1m"}]}`,
+			wantError: "may not be negative",
+		},
+		{
+			contents: `{"auto_join": [{"title": "^standup$", "before": "1m"}, {"calendars": ["team"], "countdown": "5s"}]}`,
+		},
+		{
+			contents: `{"browsers": [{"provider": "meet", "application": "Google Chrome", "args": ["--profile-directory=Profile 1"]}, {"link": "calendar"}, {"link": "notes", "application": "firefox"}]}`,
+		},
+		{
+			contents:  `{"browsers": [{"link": "chat"}]}`,
+			wantError: "link must be",
+		},
+		{
+			contents:  `{"browsers": [{"provider": "skype"}]}`,
+			wantError: "no such provider",
+		},
+		{
+			contents:  `{"browsers": [{"args": ["--new-window"]}]}`,
+			wantError: "args need an application",
+		},
+		{
+			contents:  `{"auto_join": [{"before": "1m"}]}`,
+			wantError: "matches every meeting",
+		},
+		{
+			contents:  `{"auto_join": [{"title": "standup("}]}`,
+			wantError: "bad title",
+		},
+		{
+			contents:  `{"auto_join": [{"title": "standup", "countdown": "-5s"}]}`,
+			wantError: "may not be negative",
+		},
+		{
+			contents:  `{"webhooks": [{"name": "e", "urls": ["https://chat"]}], "emails": [{"name": "e", "server": "smtp:587", "from": "me@example.com", "recipients": [{"address": "me@example.com"}]}]}`,
+			wantError: "more than once",
+		},
+	} {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(test.contents), 0600); err != nil {
+			t.Fatalf("cannot write %q: %v", path, err)
+		}
+		_, err := Load(path)
+		switch {
+		case err == nil && test.wantError != "":
+			t.Errorf("Load(%q) = _,nil, want error with %q", test.contents, test.wantError)
+		case err != nil && test.wantError == "":
+			t.Errorf("Load(%q) = _,%v, want nil error", test.contents, err)
+		case err != nil && !strings.Contains(err.Error(), test.wantError):
+			t.Errorf("Load(%q) = _,%v, want error with %q", test.contents, err, test.wantError)
+		}
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	// Non-existing files are empty configurations
+	cfg, err := Load("/non/existing/config.json")
+	if err != nil {
+		t.Fatalf("Load(/non/existing) = _,%v, require nil error", err)
+	}
+	if len(cfg.Notifiers) != 0 {
+		t.Errorf("Load(/non/existing) has notifiers %v, want none", cfg.Notifiers)
+	}
+
+	// Input defaults to stdin
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"notifiers": [{"name": "n", "command": ["x"], "escape": "none"}]}`), 0600); err != nil {
+		t.Fatalf("cannot write %q: %v", path, err)
+	}
+	cfg, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load(%q) = _,%v, require nil error", path, err)
+	}
+	if cfg.Notifiers[0].Input != InputStdin {
+		t.Errorf("Load(%q): input = %q, want %q", path, cfg.Notifiers[0].Input, InputStdin)
+	}
+}
+
+func TestWebhookDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"webhooks": [{"name": "w", "urls": ["https://chat"]}, {"name": "x", "urls": ["https://chat"], "retries": 0, "backoff": "3s"}]}`), 0600); err != nil {
+		t.Fatalf("cannot write %q: %v", path, err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load(%q) = _,%v, require nil error", path, err)
+	}
+	w := cfg.Webhooks[0]
+	if w.SignatureHeader != DefaultSignatureHeader || *w.Retries != DefaultRetries || w.Backoff != DefaultBackoff || w.Timeout != DefaultTimeout {
+		t.Errorf("Load(%q): webhook = %+v, want defaults", path, w)
+	}
+	x := cfg.Webhooks[1]
+	if *x.Retries != 0 || x.Backoff != Duration(time.Second*3) {
+		t.Errorf("Load(%q): webhook = %+v, want 0 retries and 3s backoff", path, x)
+	}
+}
+
+func TestEmailDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"emails": [{"name": "e", "server": "smtp:587", "from": "me@example.com", "recipients": [{"address": "me@example.com"}]}, {"name": "f", "server": "localhost:25", "starttls": false, "timeout": "5s", "from": "me@example.com", "recipients": [{"address": "me@example.com"}]}]}`), 0600); err != nil {
+		t.Fatalf("cannot write %q: %v", path, err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load(%q) = _,%v, require nil error", path, err)
+	}
+	e := cfg.Emails[0]
+	if !*e.StartTLS || e.Timeout != DefaultEmailTimeout {
+		t.Errorf("Load(%q): email = %+v, want defaults", path, e)
+	}
+	f := cfg.Emails[1]
+	if *f.StartTLS || f.Timeout != Duration(time.Second*5) {
+		t.Errorf("Load(%q): email = %+v, want no STARTTLS and 5s timeout", path, f)
+	}
+}
+
+func TestMQTTDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"mqtt": {"broker": "localhost:1883", "topics": {"state": "lamp"}}}`), 0600); err != nil {
+		t.Fatalf("cannot write %q: %v", path, err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load(%q) = _,%v, require nil error", path, err)
+	}
+	m := cfg.MQTT
+	want := MQTTTopics{
+		Status:  DefaultMQTTStatusTopic,
+		Event:   DefaultMQTTEventTopic,
+		State:   "lamp",
+		Current: DefaultMQTTCurrentTopic,
+		Next:    DefaultMQTTNextTopic,
+	}
+	if m.Topics != want || m.KeepAlive != DefaultMQTTKeepAlive || m.Timeout != DefaultMQTTTimeout || !strings.HasPrefix(m.ClientID, "goto-meet-") {
+		t.Errorf("Load(%q): mqtt = %+v, want defaults and topics %+v", path, m, want)
+	}
+}
+
+func TestQuietDefaults(t *testing.T) {
+	for _, test := range []struct {
+		contents       string
+		wantEventTypes []string
+	}{
+		{contents: `{"quiet": {}}`, wantEventTypes: DefaultQuietEventTypes},
+		{contents: `{"quiet": {"event_types": []}}`, wantEventTypes: []string{}},
+		{contents: `{"quiet": {"event_types": ["outOfOffice"]}}`, wantEventTypes: []string{"outOfOffice"}},
+	} {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(test.contents), 0600); err != nil {
+			t.Fatalf("cannot write %q: %v", path, err)
+		}
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%q) = _,%v, require nil error", test.contents, err)
+		}
+		if got := cfg.Quiet.EventTypes; strings.Join(got, ",") != strings.Join(test.wantEventTypes, ",") || got == nil {
+			t.Errorf("Load(%q): event types = %v, want %v", test.contents, got, test.wantEventTypes)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	for _, test := range []struct {
+		s         string
+		want      time.Duration
+		wantError bool
+	}{
+		{s: "00:00", want: 0},
+		{s: "09:30", want: time.Hour*9 + time.Minute*30},
+		{s: "24:00", want: time.Hour * 24},
+		{s: "9am", wantError: true},
+		{s: "25:00", wantError: true},
+	} {
+		got, err := ParseClock(test.s)
+		if (err != nil) != test.wantError || got != test.want {
+			t.Errorf("ParseClock(%q) = %v,%v, want %v, error: %v", test.s, got, err, test.want, test.wantError)
+		}
+	}
+}
+
+func TestHookDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"hooks": [{"command": ["playerctl", "pause"]}]}`), 0600); err != nil {
+		t.Fatalf("cannot write %q: %v", path, err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load(%q) = _,%v, require nil error", path, err)
+	}
+	h := cfg.Hooks[0]
+	if h.Name != "playerctl" || h.When != HookStart || h.Timeout != DefaultHookTimeout {
+		t.Errorf("Load(%q): hook = %+v, want name playerctl, when %q and timeout %v", path, h, HookStart, DefaultHookTimeout)
+	}
+}
+
+func TestAutoJoinDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"auto_join": [{"title": "standup"}]}`), 0600); err != nil {
+		t.Fatalf("cannot write %q: %v", path, err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load(%q) = _,%v, require nil error", path, err)
+	}
+	if a := cfg.AutoJoin[0]; a.Before != 0 || a.Countdown != DefaultCountdown {
+		t.Errorf("Load(%q): auto_join = %+v, want before 0 and countdown %v", path, a, DefaultCountdown)
+	}
+}
@@ -4,22 +4,35 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/KarelKubat/goto-meet/client"
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/hooks"
+	"github.com/KarelKubat/goto-meet/item"
 	"github.com/KarelKubat/goto-meet/l"
 	"github.com/KarelKubat/goto-meet/lib"
+	"github.com/KarelKubat/goto-meet/lifecycle"
 	"github.com/KarelKubat/goto-meet/lister"
+	"github.com/KarelKubat/goto-meet/mqtt"
+	"github.com/KarelKubat/goto-meet/mute"
+	"github.com/KarelKubat/goto-meet/quiet"
 	"github.com/KarelKubat/goto-meet/ui"
 )
 
 const (
 	// Version of this package, increased upon releasing.
-	version = "0.11"
+	version = "0.36"
 )
 
+// Log file in watch mode, unless --log points elsewhere than stdout.
+const watchLog = "~/.goto-meet/goto-meet.log"
+
 var (
 	// How to contact Google Calendar
 	tokenFileFlag       = flag.String("token", "~/.goto-meet/token.json", "path to JSON configuration with access access_token etc., supports `~/` prefix")
@@ -27,16 +40,31 @@ var (
 	clientTimeoutFlag   = flag.Duration("timeout", time.Second*30, "timeout when polling for new calendar entries, 0 to prevent timing out")
 
 	// Calendar processing
-	calendarsFlag      = flag.String("calendars", "primary", "comma-separated list of calendars to inspect, 'primary' is your default calendar")
-	resultsPerPollFlag = flag.Int("results", 50, "max results to process per calendar poll")
-	pollIntervalFlag   = flag.Duration("interval", time.Minute*10, "wait time between calendar polls")
-	lookaheadFlag      = flag.Duration("look-ahead", time.Hour*1, "fetch calendar events that start before this duration")
-	startsInFlag       = flag.Duration("starts-in", time.Minute, "how much in advance of a meeting should an alert be generated")
+	calendarsFlag         = flag.String("calendars", "primary", "comma-separated list of calendars to inspect, 'primary' is your default calendar")
+	resultsPerPollFlag    = flag.Int("results", 50, "max results to process per calendar poll")
+	pollIntervalFlag      = flag.Duration("interval", time.Minute*10, "wait time between calendar polls")
+	lookaheadFlag         = flag.Duration("look-ahead", time.Hour*1, "fetch calendar events that start before this duration")
+	startsInFlag          = flag.Duration("starts-in", time.Minute, "how much in advance of a meeting should an alert be generated")
+	graceFlag             = flag.Duration("grace", ui.DefaultGrace, "meetings that started up to this long ago are still notified, e.g. 10m, 0 to disable")
+	endsInFlag            = flag.Duration("ends-in", 0, "how much in advance of the end of a meeting should an alert be generated, 0 to disable, which also disables the warning of a meeting right after")
+	calendarRemindersFlag = flag.Bool("calendar-reminders", false, "alert at the popup reminders of events, or the defaults of their calendars, instead of --starts-in")
 
 	// How to notify the user
-	notificationTypeFlag = flag.String("notification", "macos_osascript", "type of notifications to generate")
+	notificationTypeFlag = flag.String("notification", "macos_osascript", "type(s) of notifications to generate, comma-separated")
 	onscreenSecFlag      = flag.Int("onscreen-sec", 120, "number of seconds to keep a notification visible")
 	browserFlag          = flag.String("browser", "", "browser to activate for calendar links, '' means default browser")
+	configFlag           = flag.String("config", "~/.goto-meet/config.json", "path to optional JSON configuration with user-defined notifiers etc., supports '~/' prefix")
+	watchFlag            = flag.Bool("watch", false, "show an agenda of upcoming meetings in the terminal, adds the 'terminal' notification type")
+	groupWindowFlag      = flag.Duration("group-window", ui.DefaultGroupWindow, "reminders that are due within this window share one notification, 0 to disable")
+	modalLimitFlag       = flag.Int("modal-limit", 1, "max # of dialogs on screen at once, others wait their turn")
+	pauseFlag            = flag.Duration("pause", 0, "pause the notifications of a running goto-meet for this duration and stop, 0 to resume them")
+	pauseFileFlag        = flag.String("pause-file", "~/.goto-meet/pause", "path to the file that holds the end of a pause, supports '~/' prefix")
+	joinNotesFlag        = flag.Bool("join-notes", false, "joining a meeting also opens its attachments and linked documents, such as its agenda")
+	muteFileFlag         = flag.String("mute-file", "~/.goto-meet/muted.json", "path to the file that lists muted recurring meetings, supports '~/' prefix")
+	mutedFlag            = flag.Bool("muted", false, "list the muted recurring meetings and stop")
+	unmuteFlag           = flag.String("unmute", "", "unmute the recurring meeting with this series ID, as shown by --muted, and stop")
+	joinRecordFlag       = flag.String("join-record", "~/.goto-meet/auto-joined.log", "path to the file that records meetings that were joined without asking, supports '~/' prefix, '' for none")
+	snoozeFlag           = flag.String("snooze", "1m,2m,5m,start", "comma-separated snooze choices, durations or 'start', the first one is the default")
 
 	// General
 	loopsFlag    = flag.Int("loops", 0, "polling loops to execute before stopping, 0 means forever (mainly for debugging)")
@@ -56,6 +84,60 @@ func main() {
 		os.Exit(0)
 	}
 
+	pausePath, err := lib.ExpandPath(*pauseFileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if flagGiven("pause") {
+		if err := quiet.Pause(pausePath, pauseUntil(*pauseFlag, time.Now())); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	mutePath, err := lib.ExpandPath(*muteFileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	muted, err := mute.Load(mutePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *mutedFlag {
+		listMuted(os.Stdout, muted.Entries())
+		os.Exit(0)
+	}
+	if *unmuteFlag != "" {
+		ok, err := muted.Unmute(*unmuteFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if !ok {
+			fmt.Fprintf(os.Stderr, "series %q is not muted, see `goto-meet --muted`\n", *unmuteFlag)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// In watch mode the terminal shows the agenda, so logging goes elsewhere and the terminal backend
+	// is added to the notification types.
+	if *watchFlag {
+		if *logFlag == "file://stdout" {
+			logPath, err := lib.ExpandPath(watchLog)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			*logFlag = "file://" + logPath
+		}
+		*notificationTypeFlag = watchNotification(*notificationTypeFlag, flagGiven("notification"))
+	}
+
 	if err := l.SetOutput(*logFlag); err != nil {
 		fmt.Fprintf(os.Stderr, "cannot set log destination: %v\n", err)
 		os.Exit(1)
@@ -73,18 +155,94 @@ func main() {
 		l.Fatalf("%v", err)
 	}
 	l.Infof("path to credentials file: %v", credentialsPath)
+	configPath, err := lib.ExpandPath(*configFlag)
+	if err != nil {
+		l.Fatalf("%v", err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		l.Fatalf("cannot load configuration: %v", err)
+	}
+	l.Infof("path to configuration file: %v", configPath)
 
+	policy, err := quiet.New(&quiet.Opts{
+		Config:    cfg.Quiet,
+		PauseFile: pausePath,
+	})
+	if err != nil {
+		l.Fatalf("cannot set up quiet hours: %v", err)
+	}
+
+	// Stopping goto-meet runs the deferred cleanup: the terminal is restored, running hooks are stopped
+	// and the MQTT broker is told that goto-meet goes offline. Signaling again stops at once.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		l.Infof("stopping upon a signal")
+		stop()
+	}()
+
+	runner, err := hooks.New(&hooks.Opts{Hooks: cfg.Hooks})
+	if err != nil {
+		l.Fatalf("cannot set up hooks: %v", err)
+	}
+	defer runner.Stop()
+	for _, h := range cfg.Hooks {
+		l.Infof("hook %q runs %q at %v", h.Name, h.Command, h.When)
+	}
+
+	joinRecordPath, err := lib.ExpandPath(*joinRecordFlag)
+	if err != nil {
+		l.Fatalf("%v", err)
+	}
+
+	snooze, err := ui.ParseSnooze(*snoozeFlag)
+	if err != nil {
+		l.Fatalf("bad --snooze: %v", err)
+	}
 	notifier, err := ui.New(&ui.Opts{
-		Name:          *notificationTypeFlag,
-		StartsIn:      *startsInFlag,
-		VisibilitySec: *onscreenSecFlag,
-		Browser:       *browserFlag,
+		Name:              *notificationTypeFlag,
+		StartsIn:          *startsInFlag,
+		VisibilitySec:     *onscreenSecFlag,
+		Browser:           *browserFlag,
+		Config:            cfg,
+		Snooze:            snooze,
+		CalendarReminders: *calendarRemindersFlag,
+		GroupWindow:       *groupWindowFlag,
+		ModalLimit:        *modalLimitFlag,
+		Grace:             *graceFlag,
+		Quiet:             policy,
+		Hooks:             runner,
+		EndsIn:            *endsInFlag,
+		JoinRecord:        joinRecordPath,
+		Mute:              muted,
+		JoinNotes:         *joinNotesFlag,
 	})
 	if err != nil {
 		l.Fatalf("%v", err)
 	}
+	defer notifier.Close()
+
+	// Publish the lifecycle of meetings when a broker is configured.
+	var tracker *lifecycle.Tracker
+	if cfg.MQTT != nil {
+		publisher, err := mqtt.New(cfg.MQTT)
+		if err != nil {
+			l.Fatalf("%v", err)
+		}
+		defer publisher.Close()
+		tracker, err = lifecycle.New(&lifecycle.Opts{
+			StartsIn: *startsInFlag,
+			Report:   publisher.Report,
+		})
+		if err != nil {
+			l.Fatalf("%v", err)
+		}
+		defer tracker.Stop()
+		l.Infof("publishing meetings to MQTT broker %v", cfg.MQTT.Broker)
+	}
 
-	ctx := context.Background()
 	srv, err := client.New(ctx, &client.Opts{
 		TokenFile:       tokenPath,
 		CredentialsFile: credentialsPath,
@@ -109,6 +267,9 @@ func main() {
 	for {
 		// Quit after the indicated # of loops or when we've been failing all the time.
 		nLoops++
+		if ctx.Err() != nil {
+			break
+		}
 		l.Infof("---------- Polling loop %v (%v consecutive errors) ----------", nLoops, nFailures)
 		if *loopsFlag > 0 && nLoops > *loopsFlag {
 			l.Warnf("exiting before loop %v", nLoops)
@@ -116,28 +277,87 @@ func main() {
 		}
 
 		// Get next entries and have the ui schedule alerts.
-		if err := lister.Fetch(ctx); err != nil {
+		if err := lister.Fetch(ctx); err != nil && ctx.Err() != nil {
+			break
+		} else if err != nil {
 			nFailures++
 			l.Warnf("failure %v: cannot fetch next calendar entries: %v", nFailures, err)
 			if nFailures >= *failuresFlag {
 				l.Fatalf("%v consecutive failures, giving up", nFailures)
 			}
-			time.Sleep(time.Second * 5)
+			sleep(ctx, time.Second*5)
 			continue
 		} else {
 			nFailures = 0
 		}
 
+		items := []*item.Item{}
 		for it := lister.First(); it != nil; it = lister.Next() {
 			notifier.Schedule(it)
+			items = append(items, it)
+		}
+		policy.Update(items)
+		runner.Update(items)
+		notifier.Agenda(items)
+		if tracker != nil {
+			tracker.Update(items)
 		}
 
 		// Honor the polling interval, unless this is the first time around
 		if nLoops > 1 {
-			time.Sleep(*pollIntervalFlag)
+			sleep(ctx, *pollIntervalFlag)
 		}
 	}
 
 	// Allow any notifications from the last loop to appear.
-	time.Sleep(time.Second)
+	sleep(ctx, time.Second)
+}
+
+// sleep is a helper to wait for a duration, or until the context is done.
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// flagGiven is a helper to check whether a flag was set on the command line.
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
+}
+
+// pauseUntil is a helper to determine the end of a pause of a duration, the zero time resumes.
+func pauseUntil(d time.Duration, now time.Time) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return now.Add(d)
+}
+
+// listMuted is a helper to print the muted series: their ID, when they were muted, their calendar and
+// their title.
+func listMuted(w io.Writer, entries []*mute.Entry) {
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%q\n", e.Series, e.Muted.Local().Format("2006-01-02 15:04"), e.Calendar, e.Title)
+	}
+}
+
+// watchNotification is a helper to determine the notification types in watch mode. The terminal
+// replaces the default type, or is added to explicitly given ones.
+func watchNotification(types string, given bool) string {
+	if !given {
+		return "terminal"
+	}
+	for _, t := range strings.Split(types, ",") {
+		if strings.TrimSpace(t) == "terminal" {
+			return types
+		}
+	}
+	return types + ",terminal"
 }
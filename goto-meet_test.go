@@ -1,9 +1,67 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
 
-// There are no tests for main, this file is empty.
-// main() is just the top level calling point.
+	"github.com/KarelKubat/goto-meet/mute"
+)
 
-func TestMain(t *testing.T) {
+// main() is just the top level calling point and isn't tested, its helpers are.
+
+func TestWatchNotification(t *testing.T) {
+	for _, test := range []struct {
+		types string
+		given bool
+		want  string
+	}{
+		{types: "macos_osascript", given: false, want: "terminal"},
+		{types: "linux_dbus", given: true, want: "linux_dbus,terminal"},
+		{types: "linux_dbus, terminal", given: true, want: "linux_dbus, terminal"},
+	} {
+		if got := watchNotification(test.types, test.given); got != test.want {
+			t.Errorf("watchNotification(%q, %v) = %q, want %q", test.types, test.given, got, test.want)
+		}
+	}
+}
+
+func TestPauseUntil(t *testing.T) {
+	now := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	if got := pauseUntil(time.Hour, now); !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("pauseUntil(1h, %v) = %v, want %v", now, got, now.Add(time.Hour))
+	}
+	if got := pauseUntil(0, now); !got.IsZero() {
+		t.Errorf("pauseUntil(0, %v) = %v, want zero", now, got)
+	}
+}
+
+func TestListMuted(t *testing.T) {
+	muted := time.Date(2021, 11, 1, 10, 0, 0, 0, time.Local)
+	buf := new(bytes.Buffer)
+	listMuted(buf, []*mute.Entry{
+		{Series: "abc", Calendar: "primary", Title: "standup", Muted: muted},
+		{Series: "def", Calendar: "team@group.calendar.google.com", Title: "retro \"monthly\"", Muted: muted},
+	})
+	want := "abc\t2021-11-01 10:00\tprimary\t\"standup\"\n" +
+		"def\t2021-11-01 10:00\tteam@group.calendar.google.com\t\"retro \\\"monthly\\\"\"\n"
+	if got := buf.String(); got != want {
+		t.Errorf("listMuted() = %q, want %q", got, want)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	sleep(ctx, time.Hour)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("sleep() of a done context took %v, want it to return at once", d)
+	}
+	start = time.Now()
+	sleep(context.Background(), time.Millisecond*50)
+	if d := time.Since(start); d < time.Millisecond*50 {
+		t.Errorf("sleep(50ms) took %v, want at least 50ms", d)
+	}
 }
@@ -0,0 +1,304 @@
+// Package hooks runs commands around meetings: before they start, when the user joins them, and after
+// they end.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/l"
+	"github.com/KarelKubat/goto-meet/schedule"
+)
+
+// envPrefix starts the names of the environment variables that describe a meeting to a hook.
+const envPrefix = "GOTO_MEET_"
+
+// Opts wraps the options to create a runner.
+type Opts struct {
+	Hooks []*config.Hook // the hooks to run, as loaded from the configuration
+	Clock schedule.Clock // clock to follow, nil for the clock of the system
+}
+
+// Meeting is what a hook receives on stdin, as JSON. The fields are also passed as environment
+// variables, such as GOTO_MEET_TITLE.
+type Meeting struct {
+	Hook         string    `json:"hook"`          // name of the hook
+	When         string    `json:"when"`          // "start", "join" or "end"
+	Title        string    `json:"title"`         // event title
+	Start        time.Time `json:"start"`         // event start stamp
+	End          time.Time `json:"end"`           // event end stamp
+	JoinLink     string    `json:"join_link"`     // link to join the meet
+	CalendarLink string    `json:"calendar_link"` // link to see the event on the calendar
+	Calendar     string    `json:"calendar"`      // calendar that holds the event
+	EventID      string    `json:"event_id"`      // ID of the event within its calendar
+	Attendees    []string  `json:"attendees"`     // email addresses of the attendees
+}
+
+// entry is a meeting with hooks and the keys of its start and end hooks in the scheduler.
+type entry struct {
+	it      *item.Item
+	jobs    []string
+	started bool // start hooks ran, so end hooks are due
+	ended   bool // end hooks ran
+}
+
+// Runner is the receiver.
+type Runner struct {
+	opts     *Opts
+	sched    *schedule.Scheduler // runs start and end hooks when they are due
+	mu       sync.Mutex
+	meetings map[string]*entry
+	ran      map[string]time.Time // start and end hooks that ran, with the end of their meeting
+	wg       sync.WaitGroup       // running hooks
+}
+
+// New creates a Runner.
+func New(opts *Opts) (*Runner, error) {
+	for _, h := range opts.Hooks {
+		if len(h.Command) == 0 {
+			return nil, fmt.Errorf("hook %q has no command", h.Name)
+		}
+	}
+	return &Runner{
+		opts:     opts,
+		sched:    schedule.New(opts.Clock),
+		meetings: map[string]*entry{},
+		ran:      map[string]time.Time{},
+	}, nil
+}
+
+// Update tells the runner which items a calendar poll returned. Start and end hooks are scheduled for
+// new meetings and rescheduled for modified ones. Meetings that are no longer returned before they
+// ended, e.g. because they were canceled, get their end hooks right away when their start hooks ran.
+// Items without a join link and all-day items aren't meetings.
+func (r *Runner) Update(items []*item.Item) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.sched.Now()
+	seen := map[string]struct{}{}
+	for _, it := range items {
+		if it.AllDay || it.JoinLink == "" {
+			continue
+		}
+		k := it.Key()
+		seen[k] = struct{}{}
+		e, ok := r.meetings[k]
+		if ok && e.it.Version == it.Version && e.it.Start.Equal(it.Start) && e.it.End.Equal(it.End) {
+			continue
+		}
+		if !ok {
+			e = &entry{}
+			r.meetings[k] = e
+		}
+		e.it = it
+		r.schedule(k, e, now)
+	}
+	for k, e := range r.meetings {
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		r.stop(e)
+		delete(r.meetings, k)
+		if e.started && !e.ended {
+			l.Infof("%v is no longer in the calendar, running its end hooks", e.it)
+			r.runAll(config.HookEnd, e.it)
+		}
+	}
+	for k, until := range r.ran {
+		if until.Before(now.Add(-r.maxOffset())) {
+			delete(r.ran, k)
+		}
+	}
+}
+
+// Join runs the join hooks of a meeting. It doesn't wait for them.
+func (r *Runner) Join(it *item.Item) {
+	r.runAll(config.HookJoin, it)
+}
+
+// Stop stops scheduling hooks and waits for the running ones.
+func (r *Runner) Stop() {
+	r.sched.Close()
+	r.mu.Lock()
+	r.meetings = map[string]*entry{}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+// schedule is a helper to arrange for the start and end hooks of a meeting. Start hooks that are due
+// run right away, as long as the meeting didn't start. Each hook runs once for the times of a meeting.
+// Hooks follow the wall clock: when the machine wakes up from sleep after a meeting started, its start
+// hooks are skipped, but its end hooks run. The caller must hold the lock.
+func (r *Runner) schedule(k string, e *entry, now time.Time) {
+	r.stop(e)
+	for i, h := range r.opts.Hooks {
+		var due time.Time
+		switch h.When {
+		case config.HookStart:
+			if !now.Before(e.it.Start) {
+				continue
+			}
+			due = e.it.Start.Add(-time.Duration(h.Offset))
+		case config.HookEnd:
+			due = e.it.End.Add(time.Duration(h.Offset))
+			if due.Before(now) {
+				continue
+			}
+		default:
+			continue
+		}
+		key := fmt.Sprintf("%v::%d::%v::%v", k, i, e.it.Start.Unix(), e.it.End.Unix())
+		if _, ok := r.ran[key]; ok {
+			continue
+		}
+		h, it := h, e.it
+		job := fmt.Sprintf("%v::%d", k, i)
+		e.jobs = append(e.jobs, job)
+		r.sched.At(job, due, func(now time.Time) {
+			r.mu.Lock()
+			// The meeting may have been modified or removed in the meantime.
+			if cur, ok := r.meetings[k]; !ok || cur != e || e.it != it {
+				r.mu.Unlock()
+				return
+			}
+			if _, ok := r.ran[key]; ok {
+				r.mu.Unlock()
+				return
+			}
+			if h.When == config.HookStart && !now.Before(it.Start) {
+				r.mu.Unlock()
+				l.Infof("hook %q for %v: skipped, the meeting started while the machine slept", h.Name, it)
+				return
+			}
+			r.ran[key] = it.End
+			if h.When == config.HookStart {
+				e.started = true
+			} else {
+				e.ended = true
+			}
+			r.mu.Unlock()
+			r.start(h, it)
+		})
+	}
+}
+
+// stop is a helper to cancel the scheduled hooks of a meeting. The caller must hold the lock.
+func (r *Runner) stop(e *entry) {
+	for _, job := range e.jobs {
+		r.sched.Cancel(job)
+	}
+	e.jobs = nil
+}
+
+// maxOffset is a helper to find the longest offset of the hooks, for how long to remember that hooks
+// ran.
+func (r *Runner) maxOffset() time.Duration {
+	var out time.Duration
+	for _, h := range r.opts.Hooks {
+		if d := time.Duration(h.Offset); d > out {
+			out = d
+		}
+	}
+	return out
+}
+
+// runAll is a helper to run the hooks of a moment for a meeting.
+func (r *Runner) runAll(when string, it *item.Item) {
+	for _, h := range r.opts.Hooks {
+		if h.When == when {
+			r.start(h, it)
+		}
+	}
+}
+
+// start is a helper to run a hook in the background.
+func (r *Runner) start(h *config.Hook, it *item.Item) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := run(h, it); err != nil {
+			l.Warnf("hook %q for %v: %v", h.Name, it, err)
+		}
+	}()
+}
+
+// run is a helper to run a hook for a meeting and to log its exit status.
+func run(h *config.Hook, it *item.Item) error {
+	m := meeting(h, it)
+	stdin, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("cannot describe the meeting: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(h.Timeout))
+	defer cancel()
+
+	l.Infof("hook %q for %v: running %q", h.Name, it, h.Command)
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Env = append(os.Environ(), environ(m)...)
+	out, err := cmd.CombinedOutput()
+	if len(out) > 0 {
+		l.Infof("hook %q: %s", h.Name, strings.TrimSpace(string(out)))
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("killed, it didn't terminate within %v", time.Duration(h.Timeout))
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("exit status %d", exitErr.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("cannot run %q: %v", h.Command, err)
+	}
+	l.Infof("hook %q for %v: exit status 0", h.Name, it)
+	return nil
+}
+
+// meeting is a helper to describe a meeting to a hook.
+func meeting(h *config.Hook, it *item.Item) *Meeting {
+	m := &Meeting{
+		Hook:         h.Name,
+		When:         h.When,
+		Title:        it.Title,
+		Start:        it.Start,
+		End:          it.End,
+		JoinLink:     it.JoinLink,
+		CalendarLink: it.CalendarLink,
+		Calendar:     it.CalendarID,
+		EventID:      it.EventID,
+		Attendees:    []string{},
+	}
+	if it.Event != nil {
+		for _, a := range it.Event.Attendees {
+			m.Attendees = append(m.Attendees, a.Email)
+		}
+	}
+	return m
+}
+
+// environ is a helper to describe a meeting as environment variables.
+func environ(m *Meeting) []string {
+	return []string{
+		envPrefix + "HOOK=" + m.Hook,
+		envPrefix + "WHEN=" + m.When,
+		envPrefix + "TITLE=" + m.Title,
+		envPrefix + "START=" + m.Start.Format(time.RFC3339),
+		envPrefix + "END=" + m.End.Format(time.RFC3339),
+		envPrefix + "JOIN_LINK=" + m.JoinLink,
+		envPrefix + "CALENDAR_LINK=" + m.CalendarLink,
+		envPrefix + "CALENDAR=" + m.Calendar,
+		envPrefix + "EVENT_ID=" + m.EventID,
+		envPrefix + "ATTENDEES=" + strings.Join(m.Attendees, ","),
+	}
+}
@@ -0,0 +1,231 @@
+package hooks
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/schedule"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// logHook is a helper to create a hook that appends its moment and the meeting's title to a file.
+func logHook(path, when string, offset time.Duration) *config.Hook {
+	return &config.Hook{
+		Name:    when,
+		When:    when,
+		Offset:  config.Duration(offset),
+		Command: []string{"sh", "-c", `echo "$GOTO_MEET_WHEN $GOTO_MEET_TITLE" >> ` + path},
+		Timeout: config.DefaultHookTimeout,
+	}
+}
+
+// lines is a helper to read the lines that hooks wrote to a file.
+func lines(t *testing.T, path string) []string {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("os.ReadFile(%q) = _,%v, require nil error", path, err)
+	}
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(&Opts{Hooks: []*config.Hook{{Name: "empty"}}}); err == nil {
+		t.Errorf("New() with a hook without a command = _,nil, want error")
+	}
+	if _, err := New(&Opts{}); err != nil {
+		t.Errorf("New() without hooks = _,%v, want nil error", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	it := &item.Item{
+		Title:      "standup",
+		Start:      start,
+		End:        start.Add(time.Minute * 15),
+		JoinLink:   "https://meet",
+		CalendarID: "primary",
+		EventID:    "abc",
+		Event: &calendar.Event{
+			Attendees: []*calendar.EventAttendee{{Email: "a@b.c"}, {Email: "d@e.f"}},
+		},
+	}
+
+	// The meeting is described in the environment and on stdin.
+	envPath := filepath.Join(dir, "env")
+	stdinPath := filepath.Join(dir, "stdin")
+	h := &config.Hook{
+		Name:    "describe",
+		When:    config.HookJoin,
+		Command: []string{"sh", "-c", `env | grep ^GOTO_MEET_ | sort > ` + envPath + `; cat > ` + stdinPath},
+		Timeout: config.DefaultHookTimeout,
+	}
+	if err := run(h, it); err != nil {
+		t.Fatalf("run() = %v, require nil error", err)
+	}
+	wantEnv := []string{
+		"GOTO_MEET_ATTENDEES=a@b.c,d@e.f",
+		"GOTO_MEET_CALENDAR=primary",
+		"GOTO_MEET_CALENDAR_LINK=",
+		"GOTO_MEET_END=2021-11-01T10:15:00Z",
+		"GOTO_MEET_EVENT_ID=abc",
+		"GOTO_MEET_HOOK=describe",
+		"GOTO_MEET_JOIN_LINK=https://meet",
+		"GOTO_MEET_START=2021-11-01T10:00:00Z",
+		"GOTO_MEET_TITLE=standup",
+		"GOTO_MEET_WHEN=join",
+	}
+	if got := lines(t, envPath); !equal(got, wantEnv) {
+		t.Errorf("run() environment = %q, want %q", got, wantEnv)
+	}
+	b, err := os.ReadFile(stdinPath)
+	if err != nil {
+		t.Fatalf("os.ReadFile(%q) = _,%v, require nil error", stdinPath, err)
+	}
+	m := &Meeting{}
+	if err := json.Unmarshal(b, m); err != nil {
+		t.Fatalf("json.Unmarshal(%q) = %v, require nil error", b, err)
+	}
+	if m.Title != "standup" || !m.Start.Equal(start) || m.When != config.HookJoin || !equal(m.Attendees, []string{"a@b.c", "d@e.f"}) {
+		t.Errorf("run() stdin = %+v, want a description of the meeting", m)
+	}
+
+	for _, test := range []struct {
+		command []string
+		timeout time.Duration
+		wantErr string
+	}{
+		{command: []string{"true"}, timeout: time.Second},
+		{command: []string{"sh", "-c", "exit 3"}, timeout: time.Second, wantErr: "exit status 3"},
+		{command: []string{"sleep", "5"}, timeout: time.Millisecond * 100, wantErr: "killed"},
+		{command: []string{filepath.Join(dir, "missing")}, timeout: time.Second, wantErr: "cannot run"},
+	} {
+		h := &config.Hook{Name: "test", Command: test.command, Timeout: config.Duration(test.timeout)}
+		err := run(h, it)
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Errorf("run(%q) = %v, want nil error", test.command, err)
+		case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+			t.Errorf("run(%q) = %v, want error with %q", test.command, err, test.wantErr)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	r, err := New(&Opts{Hooks: []*config.Hook{
+		logHook(path, config.HookStart, time.Minute),
+		logHook(path, config.HookJoin, 0),
+		logHook(path, config.HookEnd, 0),
+	}})
+	if err != nil {
+		t.Fatalf("New() = _,%v, require nil error", err)
+	}
+	defer r.Stop()
+
+	// The start hook is due already, the end hook runs when the meeting ends. Items without a join link
+	// aren't meetings.
+	now := time.Now()
+	standup := &item.Item{
+		EventID:  "standup",
+		Title:    "standup",
+		JoinLink: "https://meet",
+		Version:  "1",
+		Start:    now.Add(time.Millisecond * 200),
+		End:      now.Add(time.Millisecond * 400),
+	}
+	lunch := &item.Item{
+		EventID: "lunch",
+		Title:   "lunch",
+		Version: "1",
+		Start:   now.Add(time.Millisecond * 100),
+		End:     now.Add(time.Millisecond * 200),
+	}
+	r.Update([]*item.Item{standup, lunch})
+	time.Sleep(time.Millisecond * 100)
+	if got, want := lines(t, path), []string{"start standup"}; !equal(got, want) {
+		t.Errorf("after Update(), hooks ran %q, want %q", got, want)
+	}
+
+	// Polling again doesn't repeat the start hook.
+	r.Update([]*item.Item{standup, lunch})
+	r.Join(standup)
+	time.Sleep(time.Millisecond * 500)
+	if got, want := lines(t, path), []string{"start standup", "join standup", "end standup"}; !equal(got, want) {
+		t.Errorf("after the end of the meeting, hooks ran %q, want %q", got, want)
+	}
+
+	// A meeting that disappears after it started gets its end hook right away.
+	now = time.Now()
+	retro := &item.Item{
+		EventID:  "retro",
+		Title:    "retro",
+		JoinLink: "https://meet",
+		Version:  "1",
+		Start:    now.Add(time.Millisecond * 100),
+		End:      now.Add(time.Hour),
+	}
+	r.Update([]*item.Item{retro})
+	time.Sleep(time.Millisecond * 100)
+	r.Update(nil)
+	r.Stop()
+	want := []string{"start standup", "join standup", "end standup", "start retro", "end retro"}
+	if got := lines(t, path); !equal(got, want) {
+		t.Errorf("after removing a started meeting, hooks ran %q, want %q", got, want)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	c := schedule.NewFakeClock(time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC))
+	r, err := New(&Opts{
+		Hooks: []*config.Hook{
+			logHook(path, config.HookStart, time.Minute*5),
+			logHook(path, config.HookEnd, 0),
+		},
+		Clock: c,
+	})
+	if err != nil {
+		t.Fatalf("New() = _,%v, require nil error", err)
+	}
+	now := c.Now()
+	r.Update([]*item.Item{{
+		EventID:  "standup",
+		Title:    "standup",
+		JoinLink: "https://meet",
+		Version:  "1",
+		Start:    now.Add(time.Minute * 30),
+		End:      now.Add(time.Hour),
+	}})
+
+	// The machine sleeps through the whole meeting. Only its end hook runs when it wakes up.
+	c.Advance(time.Hour * 2)
+	time.Sleep(time.Millisecond * 100)
+	r.Stop()
+	if got, want := lines(t, path), []string{"end standup"}; !equal(got, want) {
+		t.Errorf("after waking up, hooks ran %q, want %q", got, want)
+	}
+}
@@ -4,10 +4,14 @@ package item
 import (
 	"errors"
 	"fmt"
+	"html"
+	"net/url"
 	"regexp"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/KarelKubat/goto-meet/lib"
+	"github.com/KarelKubat/goto-meet/l"
 
 	"google.golang.org/api/calendar/v3"
 )
@@ -20,27 +24,67 @@ var joinRegexes = []*regexp.Regexp{
 	regexp.MustCompile(`.*href="(http://go/[^"]*-livestream)"`),
 }
 
+// linkRegex finds web links in descriptions, as plain text or in HTML.
+var linkRegex = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// notesHosts are the hosts of documents, such as agendas and meeting notes, that are linked in
+// descriptions. Subdomains of the hosts match too.
+var notesHosts = []string{
+	"docs.google.com",
+	"drive.google.com",
+	"notion.so",
+	"notion.site",
+	"atlassian.net",
+	"sharepoint.com",
+	"quip.com",
+	"paper.dropbox.com",
+}
+
 // Item is the receiver struct.
 type Item struct {
-	Event        *calendar.Event // item as returned by Google Calendar
-	Title        string          // description of the event
-	JoinLink     string          // extracted URL to join
-	CalendarLink string          // extracted URL to see the calendar item
-	Start        time.Time       // event start stamp
-	StartsIn     time.Duration   // event start from now
+	Event         *calendar.Event // item as returned by Google Calendar
+	CalendarID    string          // calendar that the event was fetched from
+	EventID       string          // ID of the event within its calendar
+	OriginalStart string          // original start of a recurring instance, "" otherwise
+	Version       string          // ETag or last update stamp, changes when the event is modified
+	Title         string          // description of the event
+	JoinLink      string          // extracted URL to join
+	CalendarLink  string          // extracted URL to see the calendar item
+	Notes         []string        // extracted URLs of attachments and documents, such as agendas
+	Start         time.Time       // event start stamp
+	End           time.Time       // event end stamp, same as the start when the event has no end
+	AllDay        bool            // true for events that have a date but no time
+	StartsIn      time.Duration   // event start from now
+	Reminders     []time.Duration // lead times of the popup reminders, longest first, see SetReminders
 }
 
-// New creates an Item.
-func New(event *calendar.Event) (*Item, error) {
+// New creates an Item for an event that was fetched from the given calendar.
+func New(calendarID string, event *calendar.Event) (*Item, error) {
 	out := &Item{
 		Event:        event,
-		Title:        lib.Sanitize(event.Summary),
+		CalendarID:   calendarID,
+		EventID:      event.Id,
+		Version:      event.Etag,
+		Title:        event.Summary,
 		CalendarLink: event.HtmlLink,
 	}
+	if out.Version == "" {
+		out.Version = event.Updated
+	}
+	if ost := event.OriginalStartTime; ost != nil {
+		out.OriginalStart = ost.DateTime
+		if out.OriginalStart == "" {
+			out.OriginalStart = ost.Date
+		}
+	}
 	if ers := out.findStart(); ers != nil {
 		return nil, ers
 	}
+	if ers := out.findEnd(); ers != nil {
+		return nil, ers
+	}
 	out.findJoinLink()
+	out.findNotes()
 
 	return out, nil
 }
@@ -51,6 +95,41 @@ func (i *Item) String() string {
 		i.Title, i.JoinLink, i.CalendarLink, i.Start, i.StartsIn)
 }
 
+// Key returns a distinctive key for an item. The key is stable when the event is renamed or moved, and
+// distinguishes the instances of a recurring event.
+func (i *Item) Key() string {
+	return fmt.Sprintf("%v::%v::%v", i.CalendarID, i.EventID, i.OriginalStart)
+}
+
+// SeriesKey returns the start of the keys of all instances of the recurring event that an item belongs
+// to, or "" when it doesn't recur. Google Calendar derives the IDs of instances from the ID of their
+// series, as in "<series>_20211101T100000Z".
+func (i *Item) SeriesKey() string {
+	if i.Event == nil || i.Event.RecurringEventId == "" {
+		return ""
+	}
+	return fmt.Sprintf("%v::%v_", i.CalendarID, i.Event.RecurringEventId)
+}
+
+// Account returns the email address of the account that sees an item: the attendee or organizer that
+// is marked as self, or else the calendar when it is an address. It returns "" when that is unknown.
+func (i *Item) Account() string {
+	if ev := i.Event; ev != nil {
+		for _, a := range ev.Attendees {
+			if a.Self {
+				return a.Email
+			}
+		}
+		if ev.Organizer != nil && ev.Organizer.Self {
+			return ev.Organizer.Email
+		}
+	}
+	if strings.Contains(i.CalendarID, "@") {
+		return i.CalendarID
+	}
+	return ""
+}
+
 // findJoinLink is a helper to find a link to join a meeting in the calendar event.
 func (i *Item) findJoinLink() {
 	// Preferred is the hangout link, if absent, check the summary and description for known
@@ -73,6 +152,40 @@ func (i *Item) findJoinLink() {
 	}
 }
 
+// findNotes is a helper to find the links to the attachments of an event, and to known documents in
+// its description.
+func (i *Item) findNotes() {
+	i.Notes = nil
+	seen := map[string]struct{}{}
+	add := func(link string) {
+		if _, ok := seen[link]; ok || link == "" || link == i.JoinLink {
+			return
+		}
+		seen[link] = struct{}{}
+		i.Notes = append(i.Notes, link)
+	}
+	for _, a := range i.Event.Attachments {
+		if a != nil {
+			add(a.FileUrl)
+		}
+	}
+	for _, match := range linkRegex.FindAllString(i.Event.Description, -1) {
+		// Plain text links may be followed by punctuation.
+		link := strings.TrimRight(html.UnescapeString(match), ".,;:!?)")
+		u, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+		host := strings.ToLower(u.Hostname())
+		for _, h := range notesHosts {
+			if host == h || strings.HasSuffix(host, "."+h) {
+				add(link)
+				break
+			}
+		}
+	}
+}
+
 // findStart is a helper to extract the starting date/time of a calendar event.
 func (i *Item) findStart() error {
 	startCandidates := []string{}
@@ -102,8 +215,60 @@ func (i *Item) findStart() error {
 	if !timeFound {
 		return errors.New("cannot find event start")
 	}
+	i.AllDay = i.Event.Start != nil && i.Event.Start.DateTime == "" && i.Event.Start.Date != ""
 	i.StartsIn = i.Start.Sub(time.Now())
-	fmt.Println("item start:", i.Start, "now:", time.Now(), "startsIn:", i.StartsIn)
+	l.Infof("item start: %v, now: %v, starts in: %v", i.Start, time.Now(), i.StartsIn)
+
+	return nil
+}
 
+// findEnd is a helper to extract the ending date/time of a calendar event. Events without an end
+// end when they start.
+func (i *Item) findEnd() error {
+	i.End = i.Start
+	if i.Event.End == nil {
+		return nil
+	}
+	end := i.Event.End.DateTime
+	if end == "" {
+		end = i.Event.End.Date
+	}
+	if end == "" {
+		return nil
+	}
+	if len(end) < 20 {
+		end += "T00:00:00.000Z"
+	}
+	var err error
+	i.End, err = time.Parse(time.RFC3339, end)
+	if err != nil {
+		return fmt.Errorf("cannot parse timestamp %q: %v", end, err)
+	}
 	return nil
 }
+
+// SetReminders derives the lead times of the popup reminders of the event. Events that use the
+// defaults of their calendar get the given defaults. Reminders of other methods, such as "email", are
+// ignored.
+func (i *Item) SetReminders(defaults []*calendar.EventReminder) {
+	reminders := defaults
+	if r := i.Event.Reminders; r != nil && !r.UseDefault {
+		reminders = r.Overrides
+	}
+	i.Reminders = nil
+	seen := map[time.Duration]struct{}{}
+	for _, r := range reminders {
+		if r == nil || r.Method != "popup" || r.Minutes < 0 {
+			continue
+		}
+		d := time.Duration(r.Minutes) * time.Minute
+		if _, ok := seen[d]; ok {
+			continue
+		}
+		seen[d] = struct{}{}
+		i.Reminders = append(i.Reminders, d)
+	}
+	sort.Slice(i.Reminders, func(a, b int) bool {
+		return i.Reminders[a] > i.Reminders[b]
+	})
+}
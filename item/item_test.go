@@ -1,11 +1,111 @@
 package item
 
 import (
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"google.golang.org/api/calendar/v3"
 )
 
+func TestKey(t *testing.T) {
+	for _, test := range []struct {
+		calendarID    string
+		eventID       string
+		originalStart string
+		title         string
+		wantKey       string
+	}{
+		{
+			calendarID: "primary",
+			eventID:    "abc",
+			title:      "title",
+			wantKey:    "primary::abc::",
+		},
+		{
+			// The title doesn't matter
+			calendarID: "primary",
+			eventID:    "abc",
+			title:      "another title",
+			wantKey:    "primary::abc::",
+		},
+		{
+			// Instances of recurring events are distinct
+			calendarID:    "primary",
+			eventID:       "abc",
+			originalStart: "2021-11-01T10:00:00Z",
+			wantKey:       "primary::abc::2021-11-01T10:00:00Z",
+		},
+	} {
+		it := &Item{
+			CalendarID:    test.calendarID,
+			EventID:       test.eventID,
+			OriginalStart: test.originalStart,
+			Title:         test.title,
+		}
+		key := it.Key()
+		if key != test.wantKey {
+			t.Errorf("Key() of %v = %v, want %v", it, key, test.wantKey)
+		}
+	}
+}
+
+func TestSeriesKey(t *testing.T) {
+	if got := (&Item{CalendarID: "primary", EventID: "abc"}).SeriesKey(); got != "" {
+		t.Errorf("SeriesKey() of an event that doesn't recur = %q, want \"\"", got)
+	}
+	instance := func(id string) *Item {
+		return &Item{
+			CalendarID:    "primary",
+			EventID:       id,
+			OriginalStart: "2021-11-01T10:00:00Z",
+			Event:         &calendar.Event{Id: id, RecurringEventId: "abc"},
+		}
+	}
+	series := instance("abc_20211101T100000Z").SeriesKey()
+	if other := instance("abc_20211102T100000Z").Key(); !strings.HasPrefix(other, series) {
+		t.Errorf("Key() of another instance = %q, want it to start with SeriesKey() = %q", other, series)
+	}
+	if other := instance("abcd_20211101T100000Z").Key(); strings.HasPrefix(other, series) {
+		t.Errorf("Key() of another series = %q, want it not to start with SeriesKey() = %q", other, series)
+	}
+}
+
+func TestAccount(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		it   *Item
+		want string
+	}{
+		{
+			name: "the attendee that is self",
+			it: &Item{CalendarID: "primary", Event: &calendar.Event{Attendees: []*calendar.EventAttendee{
+				{Email: "other@example.com"}, {Email: "me@work.com", Self: true},
+			}}},
+			want: "me@work.com",
+		},
+		{
+			name: "the organizer that is self",
+			it:   &Item{CalendarID: "primary", Event: &calendar.Event{Organizer: &calendar.EventOrganizer{Email: "me@work.com", Self: true}}},
+			want: "me@work.com",
+		},
+		{
+			name: "the calendar",
+			it:   &Item{CalendarID: "me@work.com", Event: &calendar.Event{}},
+			want: "me@work.com",
+		},
+		{
+			name: "unknown",
+			it:   &Item{CalendarID: "primary"},
+		},
+	} {
+		if got := test.it.Account(); got != test.want {
+			t.Errorf("%v: Account() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
 func TestFindJoinLink(t *testing.T) {
 	for _, test := range []struct {
 		hangoutLink  string
@@ -62,3 +162,196 @@ func TestFindJoinLink(t *testing.T) {
 		}
 	}
 }
+
+func TestNew(t *testing.T) {
+	for _, test := range []struct {
+		event             *calendar.Event
+		wantEventID       string
+		wantVersion       string
+		wantOriginalStart string
+	}{
+		{
+			// ETags are preferred as version
+			event: &calendar.Event{
+				Id:      "abc",
+				Etag:    `"123"`,
+				Updated: "2021-11-01T09:00:00.000Z",
+				Start:   &calendar.EventDateTime{DateTime: "2021-11-01T10:00:00Z"},
+			},
+			wantEventID: "abc",
+			wantVersion: `"123"`,
+		},
+		{
+			// Without ETag, the update stamp is the version
+			event: &calendar.Event{
+				Id:      "abc",
+				Updated: "2021-11-01T09:00:00.000Z",
+				Start:   &calendar.EventDateTime{DateTime: "2021-11-01T10:00:00Z"},
+			},
+			wantEventID: "abc",
+			wantVersion: "2021-11-01T09:00:00.000Z",
+		},
+		{
+			// Recurring instances have an original start
+			event: &calendar.Event{
+				Id:                "abc_20211101",
+				Etag:              `"123"`,
+				Start:             &calendar.EventDateTime{DateTime: "2021-11-01T10:30:00Z"},
+				OriginalStartTime: &calendar.EventDateTime{DateTime: "2021-11-01T10:00:00Z"},
+			},
+			wantEventID:       "abc_20211101",
+			wantVersion:       `"123"`,
+			wantOriginalStart: "2021-11-01T10:00:00Z",
+		},
+		{
+			// All-day instances have a date as original start
+			event: &calendar.Event{
+				Id:                "abc_20211101",
+				Etag:              `"123"`,
+				Start:             &calendar.EventDateTime{Date: "2021-11-01"},
+				OriginalStartTime: &calendar.EventDateTime{Date: "2021-11-01"},
+			},
+			wantEventID:       "abc_20211101",
+			wantVersion:       `"123"`,
+			wantOriginalStart: "2021-11-01",
+		},
+	} {
+		it, err := New("cal", test.event)
+		if err != nil {
+			t.Fatalf("New(_, %+v) = _,%v, require nil error", test.event, err)
+		}
+		if it.CalendarID != "cal" {
+			t.Errorf("New(_, %+v): CalendarID = %q, want %q", test.event, it.CalendarID, "cal")
+		}
+		if it.EventID != test.wantEventID {
+			t.Errorf("New(_, %+v): EventID = %q, want %q", test.event, it.EventID, test.wantEventID)
+		}
+		if it.Version != test.wantVersion {
+			t.Errorf("New(_, %+v): Version = %q, want %q", test.event, it.Version, test.wantVersion)
+		}
+		if it.OriginalStart != test.wantOriginalStart {
+			t.Errorf("New(_, %+v): OriginalStart = %q, want %q", test.event, it.OriginalStart, test.wantOriginalStart)
+		}
+	}
+}
+
+func TestFindEnd(t *testing.T) {
+	for _, test := range []struct {
+		event      *calendar.Event
+		wantEnd    time.Time
+		wantAllDay bool
+	}{
+		{
+			event: &calendar.Event{
+				Start: &calendar.EventDateTime{DateTime: "2021-11-01T10:00:00Z"},
+				End:   &calendar.EventDateTime{DateTime: "2021-11-01T10:30:00Z"},
+			},
+			wantEnd: time.Date(2021, 11, 1, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			// Without an end, the event ends when it starts
+			event: &calendar.Event{
+				Start: &calendar.EventDateTime{DateTime: "2021-11-01T10:00:00Z"},
+			},
+			wantEnd: time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			event: &calendar.Event{
+				Start: &calendar.EventDateTime{Date: "2021-11-01"},
+				End:   &calendar.EventDateTime{Date: "2021-11-02"},
+			},
+			wantEnd:    time.Date(2021, 11, 2, 0, 0, 0, 0, time.UTC),
+			wantAllDay: true,
+		},
+	} {
+		it, err := New("cal", test.event)
+		if err != nil {
+			t.Fatalf("New(_, %+v) = _,%v, require nil error", test.event, err)
+		}
+		if !it.End.Equal(test.wantEnd) || it.AllDay != test.wantAllDay {
+			t.Errorf("New(_, %+v): End, AllDay = %v,%v, want %v,%v", test.event, it.End, it.AllDay, test.wantEnd, test.wantAllDay)
+		}
+	}
+}
+
+func TestSetReminders(t *testing.T) {
+	defaults := []*calendar.EventReminder{
+		{Method: "popup", Minutes: 10},
+		{Method: "email", Minutes: 60},
+	}
+	for _, test := range []struct {
+		name      string
+		reminders *calendar.EventReminders
+		want      []time.Duration
+	}{
+		{
+			name: "no reminder settings",
+			want: []time.Duration{time.Minute * 10},
+		},
+		{
+			name:      "calendar defaults",
+			reminders: &calendar.EventReminders{UseDefault: true},
+			want:      []time.Duration{time.Minute * 10},
+		},
+		{
+			name: "overrides",
+			reminders: &calendar.EventReminders{
+				Overrides: []*calendar.EventReminder{
+					{Method: "popup", Minutes: 1},
+					{Method: "email", Minutes: 30},
+					{Method: "popup", Minutes: 15},
+					{Method: "popup", Minutes: 1},
+				},
+			},
+			want: []time.Duration{time.Minute * 15, time.Minute},
+		},
+		{
+			name:      "no reminders",
+			reminders: &calendar.EventReminders{},
+		},
+	} {
+		it := &Item{Event: &calendar.Event{Reminders: test.reminders}}
+		it.SetReminders(defaults)
+		if !reflect.DeepEqual(it.Reminders, test.want) {
+			t.Errorf("%v: SetReminders() = %v, want %v", test.name, it.Reminders, test.want)
+		}
+	}
+}
+
+func TestFindNotes(t *testing.T) {
+	for _, test := range []struct {
+		attachments []*calendar.EventAttachment
+		description string
+		want        []string
+	}{
+		{
+			// Nothing to find.
+			description: "Weekly sync, see https://example.com/about.",
+		},
+		{
+			// Attachments come first, links in the description are unescaped and found once.
+			attachments: []*calendar.EventAttachment{
+				{FileUrl: "https://drive.google.com/file/d/abc", Title: "slides"},
+				{FileUrl: "https://docs.google.com/document/d/agenda/edit"},
+			},
+			description: `Agenda: <a href="https://docs.google.com/document/d/agenda/edit">agenda</a><br>` +
+				`Notes: <a href="https://team.atlassian.net/wiki/x?a=1&amp;b=2">https://team.atlassian.net/wiki/x?a=1&amp;b=2</a>`,
+			want: []string{
+				"https://drive.google.com/file/d/abc",
+				"https://docs.google.com/document/d/agenda/edit",
+				"https://team.atlassian.net/wiki/x?a=1&b=2",
+			},
+		},
+		{
+			// Plain text links lose trailing punctuation.
+			description: "Notes are in https://www.notion.so/team/standup. Join at https://meet.google.com/abc.",
+			want:        []string{"https://www.notion.so/team/standup"},
+		},
+	} {
+		it := &Item{Event: &calendar.Event{Attachments: test.attachments, Description: test.description}}
+		it.findNotes()
+		if !reflect.DeepEqual(it.Notes, test.want) {
+			t.Errorf("findNotes() with event %+v = %q, want %q", it.Event, it.Notes, test.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package lifecycle follows meetings through their states: upcoming, starting, in progress and ended.
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/l"
+	"github.com/KarelKubat/goto-meet/schedule"
+)
+
+// State is where a meeting is in its lifecycle.
+type State int
+
+const (
+	// Upcoming means that the meeting is in the calendar.
+	Upcoming State = iota
+	// Starting means that the meeting starts within the lead time of notifications.
+	Starting
+	// InProgress means that the meeting has started.
+	InProgress
+	// Ended means that the meeting is over, or that it was removed from the calendar.
+	Ended
+)
+
+// String returns a readable representation of a state.
+func (s State) String() string {
+	switch s {
+	case Upcoming:
+		return "upcoming"
+	case Starting:
+		return "starting"
+	case InProgress:
+		return "in-progress"
+	case Ended:
+		return "ended"
+	}
+	return fmt.Sprintf("state(%d)", int(s))
+}
+
+// Meeting is a calendar item and its state.
+type Meeting struct {
+	Item  *item.Item
+	State State
+}
+
+// Change is reported when a meeting enters a state.
+type Change struct {
+	Meeting          // the meeting and its new state
+	Account string   // account of the meeting, see AccountOf
+	Current *Meeting // meeting in progress of the same account that started first, or nil
+	Next    *Meeting // meeting of the same account that starts first and didn't start yet, or nil
+}
+
+// Opts wraps the options to create a tracker.
+type Opts struct {
+	StartsIn time.Duration  // lead time of notifications, when meetings are starting
+	Report   func(*Change)  // called for each change, in order
+	Clock    schedule.Clock // clock to follow, nil for the clock of the system
+}
+
+// entry is a tracked meeting.
+type entry struct {
+	Meeting
+	gen int // increased when its next state is rescheduled
+}
+
+// Tracker is the receiver.
+type Tracker struct {
+	opts     *Opts
+	sched    *schedule.Scheduler // runs the transitions to next states
+	mu       sync.Mutex
+	meetings map[string]*entry
+}
+
+// New creates a Tracker.
+func New(opts *Opts) (*Tracker, error) {
+	if opts.Report == nil {
+		return nil, errors.New("cannot track meetings without a way to report changes")
+	}
+	return &Tracker{
+		opts:     opts,
+		sched:    schedule.New(opts.Clock),
+		meetings: map[string]*entry{},
+	}, nil
+}
+
+// Update tells the tracker which items a calendar poll returned. New items are tracked, modified
+// ones are re-evaluated and items that are no longer returned have ended. All-day items are ignored.
+func (t *Tracker) Update(items []*item.Item) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.sched.Now()
+	seen := map[string]struct{}{}
+	for _, it := range items {
+		if it.AllDay {
+			continue
+		}
+		k := it.Key()
+		seen[k] = struct{}{}
+		e, ok := t.meetings[k]
+		switch {
+		case !ok && stateAt(it, t.opts.StartsIn, now) == Ended:
+			continue
+		case !ok:
+			e = &entry{Meeting: Meeting{Item: it, State: -1}}
+			t.meetings[k] = e
+		case e.Item.Version == it.Version && e.Item.Start.Equal(it.Start) && e.Item.End.Equal(it.End):
+			continue
+		default:
+			e.Item = it
+		}
+		t.advance(k, e, now)
+	}
+	for k, e := range t.meetings {
+		if _, ok := seen[k]; !ok {
+			l.Infof("%v is no longer in the calendar", e.Item)
+			t.enter(k, e, Ended)
+		}
+	}
+}
+
+// Stop stops tracking all meetings, without reporting changes.
+func (t *Tracker) Stop() {
+	t.sched.Close()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.meetings = map[string]*entry{}
+}
+
+// advance is a helper to bring a meeting into its state at the given time, and to arrange for the
+// next state. The transition follows the wall clock, so that a machine that wakes up from sleep
+// brings meetings into the state that they reached in the meantime.
+func (t *Tracker) advance(k string, e *entry, now time.Time) {
+	state := stateAt(e.Item, t.opts.StartsIn, now)
+	if state != e.State {
+		t.enter(k, e, state)
+	}
+	if state == Ended {
+		return
+	}
+	e.gen++
+	gen := e.gen
+	t.sched.At(k, transition(e.Item, t.opts.StartsIn, state), func(now time.Time) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		// The meeting may have been modified or removed in the meantime.
+		if cur, ok := t.meetings[k]; ok && cur == e && e.gen == gen {
+			t.advance(k, e, now)
+		}
+	})
+}
+
+// enter is a helper to put a meeting into a state and to report that. Ended meetings are no longer
+// tracked.
+func (t *Tracker) enter(k string, e *entry, state State) {
+	e.State = state
+	if state == Ended {
+		t.sched.Cancel(k)
+		delete(t.meetings, k)
+	}
+	l.Infof("%v is %v", e.Item, state)
+	account := AccountOf(e.Item)
+	current, next := t.currentNext(account)
+	t.opts.Report(&Change{
+		Meeting: e.Meeting,
+		Account: account,
+		Current: current,
+		Next:    next,
+	})
+}
+
+// currentNext is a helper to find the meeting in progress and the next one of an account.
+func (t *Tracker) currentNext(account string) (*Meeting, *Meeting) {
+	var meetings []Meeting
+	for _, e := range t.meetings {
+		if AccountOf(e.Item) == account {
+			meetings = append(meetings, e.Meeting)
+		}
+	}
+	sort.Slice(meetings, func(i, j int) bool {
+		return meetings[i].Item.Start.Before(meetings[j].Item.Start)
+	})
+	var current, next *Meeting
+	for i := range meetings {
+		m := &meetings[i]
+		switch {
+		case m.State == InProgress && current == nil:
+			current = m
+		case (m.State == Upcoming || m.State == Starting) && next == nil:
+			next = m
+		}
+	}
+	return current, next
+}
+
+// AccountOf returns the account that sees an item, so that the meetings of its calendars are followed
+// together. When the account is unknown, the calendar stands for it.
+func AccountOf(it *item.Item) string {
+	if a := it.Account(); a != "" {
+		return a
+	}
+	return it.CalendarID
+}
+
+// stateAt is a helper to determine the state of an item at a given time.
+func stateAt(it *item.Item, startsIn time.Duration, now time.Time) State {
+	switch {
+	case !now.Before(it.End) && !now.Before(it.Start):
+		return Ended
+	case !now.Before(it.Start):
+		return InProgress
+	case !now.Before(it.Start.Add(-startsIn)):
+		return Starting
+	}
+	return Upcoming
+}
+
+// transition is a helper to determine when an item leaves a state.
+func transition(it *item.Item, startsIn time.Duration, state State) time.Time {
+	switch state {
+	case Upcoming:
+		return it.Start.Add(-startsIn)
+	case Starting:
+		return it.Start
+	}
+	return it.End
+}
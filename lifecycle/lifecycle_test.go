@@ -0,0 +1,251 @@
+package lifecycle
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/schedule"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// recorder collects reported changes.
+type recorder struct {
+	mu      sync.Mutex
+	changes []*Change
+}
+
+func (r *recorder) report(c *Change) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, c)
+}
+
+// states returns the reported states of an event.
+func (r *recorder) states(eventID string) []State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []State
+	for _, c := range r.changes {
+		if c.Item.EventID == eventID {
+			out = append(out, c.State)
+		}
+	}
+	return out
+}
+
+func (r *recorder) last() *Change {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.changes) == 0 {
+		return nil
+	}
+	return r.changes[len(r.changes)-1]
+}
+
+func newTestTracker(t *testing.T, startsIn time.Duration) (*Tracker, *recorder) {
+	r := &recorder{}
+	tr, err := New(&Opts{
+		StartsIn: startsIn,
+		Report:   r.report,
+	})
+	if err != nil {
+		t.Fatalf("New() = _,%v, require nil error", err)
+	}
+	t.Cleanup(tr.Stop)
+	return tr, r
+}
+
+func equalStates(a, b []State) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStateAt(t *testing.T) {
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	it := &item.Item{
+		Start: start,
+		End:   start.Add(time.Minute * 30),
+	}
+	for _, test := range []struct {
+		now       time.Time
+		wantState State
+	}{
+		{now: start.Add(-time.Minute * 5), wantState: Upcoming},
+		{now: start.Add(-time.Minute), wantState: Starting},
+		{now: start.Add(-time.Second), wantState: Starting},
+		{now: start, wantState: InProgress},
+		{now: start.Add(time.Minute * 29), wantState: InProgress},
+		{now: start.Add(time.Minute * 30), wantState: Ended},
+	} {
+		if got := stateAt(it, time.Minute, test.now); got != test.wantState {
+			t.Errorf("stateAt(_, 1m, %v) = %v, want %v", test.now, got, test.wantState)
+		}
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	tr, r := newTestTracker(t, time.Millisecond*40)
+	now := time.Now()
+	tr.Update([]*item.Item{
+		{
+			CalendarID: "cal",
+			EventID:    "a",
+			Start:      now.Add(time.Millisecond * 80),
+			End:        now.Add(time.Millisecond * 160),
+		},
+		{
+			// Already in progress
+			CalendarID: "cal",
+			EventID:    "b",
+			Start:      now.Add(-time.Minute),
+			End:        now.Add(time.Millisecond * 120),
+		},
+		{
+			// All-day events are ignored
+			CalendarID: "cal",
+			EventID:    "c",
+			Start:      now.Add(-time.Hour),
+			End:        now.Add(time.Hour),
+			AllDay:     true,
+		},
+	})
+	time.Sleep(time.Millisecond * 300)
+
+	if got, want := r.states("a"), []State{Upcoming, Starting, InProgress, Ended}; !equalStates(got, want) {
+		t.Errorf("states of a = %v, want %v", got, want)
+	}
+	if got, want := r.states("b"), []State{InProgress, Ended}; !equalStates(got, want) {
+		t.Errorf("states of b = %v, want %v", got, want)
+	}
+	if got := r.states("c"); len(got) != 0 {
+		t.Errorf("states of c = %v, want none", got)
+	}
+	if last := r.last(); last.Current != nil || last.Next != nil {
+		t.Errorf("last change %+v has a current or next meeting, want none", last)
+	}
+}
+
+func TestCurrentNext(t *testing.T) {
+	tr, r := newTestTracker(t, time.Minute)
+	now := time.Now()
+	first := &item.Item{CalendarID: "cal", EventID: "first", Start: now.Add(time.Hour), End: now.Add(time.Hour * 2)}
+	second := &item.Item{CalendarID: "cal", EventID: "second", Start: now.Add(time.Hour * 3), End: now.Add(time.Hour * 4)}
+	current := &item.Item{CalendarID: "cal", EventID: "current", Start: now.Add(-time.Hour), End: now.Add(time.Hour)}
+	other := &item.Item{CalendarID: "other", EventID: "other", Start: now.Add(-time.Hour), End: now.Add(time.Hour)}
+
+	tr.Update([]*item.Item{second, first, current, other})
+	tr.mu.Lock()
+	cur, next := tr.currentNext("cal")
+	tr.mu.Unlock()
+	if cur == nil || cur.Item != current || next == nil || next.Item != first {
+		t.Errorf("currentNext(cal) = %+v,%+v, want current and first", cur, next)
+	}
+
+	// Removing the first meeting makes the second one next.
+	tr.Update([]*item.Item{second, current, other})
+	last := r.last()
+	if last.Item != first || last.State != Ended || last.Next == nil || last.Next.Item != second || last.Current == nil || last.Current.Item != current {
+		t.Errorf("last change after removal = %+v, want first ended, second next", last)
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	tr, r := newTestTracker(t, time.Minute)
+	now := time.Now()
+	self := &calendar.Event{Attendees: []*calendar.EventAttendee{{Email: "me@work.com", Self: true}}}
+	standup := &item.Item{CalendarID: "me@work.com", EventID: "standup", Start: now.Add(-time.Minute), End: now.Add(time.Hour)}
+	review := &item.Item{CalendarID: "team", EventID: "review", Event: self, Start: now.Add(time.Hour * 2), End: now.Add(time.Hour * 3)}
+	dentist := &item.Item{CalendarID: "family", EventID: "dentist", Start: now.Add(time.Hour), End: now.Add(time.Hour * 2)}
+
+	// Meetings in other calendars of the same account are its current and next ones.
+	tr.Update([]*item.Item{standup, dentist, review})
+	tr.mu.Lock()
+	cur, next := tr.currentNext("me@work.com")
+	tr.mu.Unlock()
+	if cur == nil || cur.Item != standup || next == nil || next.Item != review {
+		t.Errorf("currentNext(me@work.com) = %+v,%+v, want standup and review", cur, next)
+	}
+	r.mu.Lock()
+	for _, c := range r.changes {
+		if want := AccountOf(c.Item); c.Account != want {
+			t.Errorf("change of %v is reported for account %q, want %q", c.Item.EventID, c.Account, want)
+		}
+	}
+	r.mu.Unlock()
+	if got := AccountOf(dentist); got != "family" {
+		t.Errorf("AccountOf() of an event in a calendar without an address = %q, want the calendar", got)
+	}
+}
+
+func TestModified(t *testing.T) {
+	tr, r := newTestTracker(t, time.Millisecond*20)
+	now := time.Now()
+	it := &item.Item{CalendarID: "cal", EventID: "a", Version: "1", Start: now.Add(time.Millisecond * 40), End: now.Add(time.Hour)}
+	tr.Update([]*item.Item{it})
+
+	// Unchanged items aren't reported again.
+	tr.Update([]*item.Item{it})
+	if got, want := r.states("a"), []State{Upcoming}; !equalStates(got, want) {
+		t.Errorf("states of a = %v, want %v", got, want)
+	}
+
+	// Postponing the meeting cancels the pending transition.
+	moved := &item.Item{CalendarID: "cal", EventID: "a", Version: "2", Start: now.Add(time.Hour), End: now.Add(time.Hour * 2)}
+	tr.Update([]*item.Item{moved})
+	time.Sleep(time.Millisecond * 100)
+	if got, want := r.states("a"), []State{Upcoming}; !equalStates(got, want) {
+		t.Errorf("states of postponed a = %v, want %v", got, want)
+	}
+
+	// Advancing it into the lead time makes it start.
+	soon := &item.Item{CalendarID: "cal", EventID: "a", Version: "3", Start: time.Now().Add(time.Millisecond * 10), End: time.Now().Add(time.Hour)}
+	tr.Update([]*item.Item{soon})
+	time.Sleep(time.Millisecond * 50)
+	if got, want := r.states("a"), []State{Upcoming, Starting, InProgress}; !equalStates(got, want) {
+		t.Errorf("states of advanced a = %v, want %v", got, want)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	r := &recorder{}
+	c := schedule.NewFakeClock(time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC))
+	tr, err := New(&Opts{
+		StartsIn: time.Minute * 5,
+		Report:   r.report,
+		Clock:    c,
+	})
+	if err != nil {
+		t.Fatalf("New() = _,%v, require nil error", err)
+	}
+	defer tr.Stop()
+	now := c.Now()
+	tr.Update([]*item.Item{
+		{CalendarID: "cal", EventID: "a", Start: now.Add(time.Minute * 30), End: now.Add(time.Hour)},
+		{CalendarID: "cal", EventID: "b", Start: now.Add(time.Hour * 3), End: now.Add(time.Hour * 4)},
+	})
+
+	// The wall clock jumps ahead when the machine wakes up, the monotonic clock doesn't.
+	c.Advance(time.Hour * 2)
+	time.Sleep(time.Millisecond * 100)
+	if got, want := r.states("a"), []State{Upcoming, Ended}; !equalStates(got, want) {
+		t.Errorf("states of a after waking up = %v, want %v", got, want)
+	}
+	if got, want := r.states("b"), []State{Upcoming}; !equalStates(got, want) {
+		t.Errorf("states of b after waking up = %v, want %v", got, want)
+	}
+	c.Advance(time.Minute * 55)
+	time.Sleep(time.Millisecond * 100)
+	if got, want := r.states("b"), []State{Upcoming, Starting}; !equalStates(got, want) {
+		t.Errorf("states of b in its lead time = %v, want %v", got, want)
+	}
+}
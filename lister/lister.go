@@ -29,8 +29,9 @@ type List struct {
 
 // Lister is the receiver.
 type Lister struct {
-	opts *Opts
-	list *List
+	opts      *Opts
+	list      *List
+	reminders map[string][]*calendar.EventReminder // default reminders per calendar
 }
 
 // New creates a Lister.
@@ -59,9 +60,14 @@ func New(ctx context.Context, opts *Opts) (*Lister, error) {
 		"primary": {}, // "primary" always exists
 	}
 	availableNames := []string{}
+	reminders := map[string][]*calendar.EventReminder{}
 	for _, it := range cals.Items {
 		availableMap[it.Id] = struct{}{}
 		availableNames = append(availableNames, it.Id)
+		reminders[it.Id] = it.DefaultReminders
+		if it.Primary {
+			reminders["primary"] = it.DefaultReminders
+		}
 	}
 	for _, cal := range opts.Calendars {
 		if _, ok := availableMap[cal]; !ok {
@@ -71,11 +77,14 @@ func New(ctx context.Context, opts *Opts) (*Lister, error) {
 
 	l.Infof("calendar lister will look ahead %v and fetch max %v entries each run", opts.LookAhead, opts.MaxResultsPerPoll)
 	return &Lister{
-		opts: opts,
+		opts:      opts,
+		reminders: reminders,
 	}, nil
 }
 
-// Fetch polls for pending items and populates the list to process.
+// Fetch polls for pending items and populates the list to process. TimeMin bounds the end of events,
+// so meetings in progress are fetched too: they may still be notified when they started a short while
+// ago, e.g. after the laptop woke up.
 func (lis *Lister) Fetch(ctx context.Context) error {
 	timeMin := time.Now().Format(time.RFC3339)
 	timeMax := time.Now().Add(lis.opts.LookAhead).Format(time.RFC3339)
@@ -97,10 +106,11 @@ func (lis *Lister) Fetch(ctx context.Context) error {
 			return fmt.Errorf("unable to retrieve next %v events for calendar %q: %v", lis.opts.MaxResultsPerPoll, calendar, err)
 		}
 		for _, it := range events.Items {
-			i, err := item.New(it)
+			i, err := item.New(calendar, it)
 			if err != nil {
 				return fmt.Errorf("cannot initialize calendar item: %v", err)
 			}
+			i.SetReminders(lis.reminders[calendar])
 			lis.list.Items = append(lis.list.Items, i)
 		}
 		l.Infof("calendar %v: %v upcoming events", calendar, len(lis.list.Items))
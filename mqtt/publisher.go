@@ -0,0 +1,224 @@
+// Package mqtt publishes the lifecycle of meetings to an MQTT broker.
+package mqtt
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/l"
+	"github.com/KarelKubat/goto-meet/lifecycle"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Payloads of the status topic.
+const (
+	statusOnline  = "online"
+	statusOffline = "offline"
+)
+
+// stateIdle is the state of a calendar without meetings in progress or upcoming.
+const stateIdle = "idle"
+
+// meetingPayload is the JSON representation of a meeting.
+type meetingPayload struct {
+	State        string    `json:"state"`
+	Calendar     string    `json:"calendar"`
+	Title        string    `json:"title"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	JoinLink     string    `json:"join_link"`
+	CalendarLink string    `json:"calendar_link"`
+}
+
+// Publisher publishes changes in the lifecycle of meetings as retained messages.
+type Publisher struct {
+	cfg     *config.MQTT
+	client  paho.Client
+	event   *template.Template
+	state   *template.Template
+	current *template.Template
+	next    *template.Template
+	mu      sync.Mutex
+	last    map[string]string // last payload per topic
+}
+
+// New creates a Publisher for a broker from the configuration file.
+func New(cfg *config.MQTT) (*Publisher, error) {
+	p := &Publisher{
+		cfg:  cfg,
+		last: map[string]string{},
+	}
+	for _, t := range []struct {
+		tpl  **template.Template
+		name string
+		text string
+	}{
+		{&p.event, "event", cfg.Topics.Event},
+		{&p.state, "state", cfg.Topics.State},
+		{&p.current, "current", cfg.Topics.Current},
+		{&p.next, "next", cfg.Topics.Next},
+	} {
+		tpl, err := template.New(t.name).Parse(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("mqtt: cannot parse %v topic: %v", t.name, err)
+		}
+		if _, err := topicFor(tpl, "me@example.com"); err != nil {
+			return nil, fmt.Errorf("mqtt: cannot expand %v topic: %v", t.name, err)
+		}
+		*t.tpl = tpl
+	}
+
+	// The client connects in the background and reconnects when the connection is lost. Messages are
+	// kept until they can be sent.
+	timeout := time.Duration(cfg.Timeout)
+	opts := paho.NewClientOptions().
+		AddBroker("tcp://"+cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetKeepAlive(time.Duration(cfg.KeepAlive)).
+		SetPingTimeout(timeout).
+		SetConnectTimeout(timeout).
+		SetWriteTimeout(timeout).
+		SetConnectRetry(true).
+		SetAutoReconnect(true).
+		SetBinaryWill(cfg.Topics.Status, []byte(statusOffline), byte(cfg.QoS), true).
+		SetOnConnectHandler(func(c paho.Client) {
+			l.Infof("mqtt: connected to %v", cfg.Broker)
+			// Waiting for the token would block the client.
+			c.Publish(cfg.Topics.Status, byte(cfg.QoS), true, statusOnline)
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			l.Warnf("mqtt: connection to %v lost: %v", cfg.Broker, err)
+		})
+	if cfg.TLS {
+		host, _, err := net.SplitHostPort(cfg.Broker)
+		if err != nil {
+			return nil, fmt.Errorf("mqtt: %v", err)
+		}
+		opts.Servers[0].Scheme = "tls"
+		opts.SetTLSConfig(&tls.Config{ServerName: host})
+	}
+	p.client = paho.NewClient(opts)
+	if t := p.client.Connect(); !t.WaitTimeout(timeout) {
+		l.Warnf("mqtt: cannot connect to %v yet, retrying in the background", cfg.Broker)
+	}
+	return p, nil
+}
+
+// Report publishes a change: the meeting and its state, and the state, the current meeting and the
+// next meeting of its calendar. Messages that wouldn't change what the broker retains are skipped.
+// Report doesn't wait for the broker, as the lifecycle tracker calls it while it holds its lock;
+// failures are logged when they happen.
+func (p *Publisher) Report(c *lifecycle.Change) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, t := range []struct {
+		tpl     *template.Template
+		payload string
+	}{
+		{p.event, meetingJSON(&c.Meeting)},
+		{p.state, calendarState(c)},
+		{p.current, meetingJSON(c.Current)},
+		{p.next, meetingJSON(c.Next)},
+	} {
+		topic, err := topicFor(t.tpl, c.Account)
+		if err != nil {
+			l.Warnf("mqtt: cannot determine topic for account %q: %v", c.Account, err)
+			continue
+		}
+		if last, ok := p.last[topic]; ok && last == t.payload {
+			continue
+		}
+		p.last[topic] = t.payload
+		go p.confirm(p.publish(topic, t.payload), c.State, topic, t.payload)
+	}
+}
+
+// Close announces that goto-meet goes offline and disconnects.
+func (p *Publisher) Close() error {
+	err := p.wait(p.publish(p.cfg.Topics.Status, statusOffline))
+	p.client.Disconnect(uint(time.Duration(p.cfg.Timeout) / time.Millisecond))
+	return err
+}
+
+// publish is a helper to publish a retained message, without waiting for the broker.
+func (p *Publisher) publish(topic, payload string) paho.Token {
+	return p.client.Publish(topic, byte(p.cfg.QoS), true, payload)
+}
+
+// confirm is a helper to wait until the broker has a message of a change, and to log when that fails.
+// A payload that the broker didn't get is forgotten, so that the next change publishes it again.
+func (p *Publisher) confirm(t paho.Token, state lifecycle.State, topic, payload string) {
+	err := p.wait(t)
+	if err == nil {
+		return
+	}
+	l.Warnf("mqtt: cannot publish %v to %v: %v", state, topic, err)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.last[topic] == payload {
+		delete(p.last, topic)
+	}
+}
+
+// wait is a helper to wait until the broker has a message, as far as the QoS tells.
+func (p *Publisher) wait(t paho.Token) error {
+	if !t.WaitTimeout(time.Duration(p.cfg.Timeout)) {
+		return errors.New("timeout, the broker may be unreachable")
+	}
+	return t.Error()
+}
+
+// topicFor is a helper to expand a topic template for an account. Characters that have a meaning in
+// topics are replaced.
+func topicFor(tpl *template.Template, account string) (string, error) {
+	buf := new(bytes.Buffer)
+	err := tpl.Execute(buf, struct{ Account string }{
+		Account: strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(account),
+	})
+	return buf.String(), err
+}
+
+// calendarState is a helper to summarize a calendar as the state of its most advanced meeting.
+func calendarState(c *lifecycle.Change) string {
+	switch {
+	case c.Current != nil:
+		return lifecycle.InProgress.String()
+	case c.Next != nil:
+		return c.Next.State.String()
+	}
+	return stateIdle
+}
+
+// meetingJSON is a helper to represent a meeting as JSON, or as {} when there is none.
+func meetingJSON(m *lifecycle.Meeting) string {
+	if m == nil {
+		return "{}"
+	}
+	b, err := json.Marshal(&meetingPayload{
+		State:        m.State.String(),
+		Calendar:     m.Item.CalendarID,
+		Title:        m.Item.Title,
+		Start:        m.Item.Start,
+		End:          m.Item.End,
+		JoinLink:     m.Item.JoinLink,
+		CalendarLink: m.Item.CalendarLink,
+	})
+	if err != nil {
+		// Can't happen, the payload has only strings and time stamps.
+		return "{}"
+	}
+	return string(b)
+}
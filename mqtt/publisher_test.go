@@ -0,0 +1,478 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/lifecycle"
+)
+
+// Packet types of MQTT 3.1.1, shifted into the upper nibble of the fixed header.
+const (
+	packetConnect    = 0x10
+	packetConnack    = 0x20
+	packetPublish    = 0x30
+	packetPuback     = 0x40
+	packetPubrec     = 0x50
+	packetPubrel     = 0x62 // includes the mandatory flags
+	packetPubcomp    = 0x70
+	packetPingreq    = 0xC0
+	packetPingresp   = 0xD0
+	packetDisconnect = 0xE0
+)
+
+// message is what the broker received.
+type message struct {
+	topic   string
+	payload []byte
+	qos     byte
+	retain  bool
+}
+
+// broker is a minimal MQTT broker that records what it receives. Retained messages are kept per
+// topic, as a real broker does.
+type broker struct {
+	ln          net.Listener
+	refuse      byte // CONNACK return code, 0 to accept
+	dropAfter   int  // close the next connection after this many publishes, 0 to never
+	mu          sync.Mutex
+	connects    []connectInfo
+	published   []*message
+	retained    map[string]string
+	pings       int
+	disconnects int
+}
+
+// connectInfo is what a CONNECT packet held.
+type connectInfo struct {
+	clientID, username, password string
+	will                         *message
+	keepAlive                    uint16
+}
+
+func newBroker(t *testing.T) *broker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	b := &broker{
+		ln:       ln,
+		retained: map[string]string{},
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return b
+}
+
+func (b *broker) addr() string {
+	return b.ln.Addr().String()
+}
+
+// snapshot returns the recorded publishes and retained messages.
+func (b *broker) snapshot() ([]*message, map[string]string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	retained := map[string]string{}
+	for k, v := range b.retained {
+		retained[k] = v
+	}
+	return append([]*message{}, b.published...), retained
+}
+
+// waitFor returns the recorded publishes and retained messages once they satisfy `done`, or after a
+// while: Report doesn't wait for the broker.
+func (b *broker) waitFor(done func([]*message, map[string]string) bool) ([]*message, map[string]string) {
+	for i := 0; ; i++ {
+		published, retained := b.snapshot()
+		if done(published, retained) || i == 100 {
+			return published, retained
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+}
+
+func (b *broker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	nPublished := 0
+	var will *message
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			// A lost connection, not a DISCONNECT: publish the will.
+			if will != nil {
+				b.store(will)
+			}
+			return
+		}
+		switch header & 0xF0 {
+		case packetConnect:
+			info := parseConnect(body)
+			will = info.will
+			b.mu.Lock()
+			b.connects = append(b.connects, info)
+			b.mu.Unlock()
+			conn.Write([]byte{packetConnack, 2, 0, b.refuse})
+			if b.refuse != 0 {
+				return
+			}
+		case packetPublish:
+			qos := header >> 1 & 3
+			m := &message{qos: qos, retain: header&1 == 1}
+			n := int(binary.BigEndian.Uint16(body))
+			m.topic, body = string(body[2:2+n]), body[2+n:]
+			var id []byte
+			if qos > 0 {
+				id, body = body[:2], body[2:]
+			}
+			m.payload = body
+			b.store(m)
+			nPublished++
+			switch qos {
+			case 1:
+				conn.Write(append([]byte{packetPuback, 2}, id...))
+			case 2:
+				conn.Write(append([]byte{packetPubrec, 2}, id...))
+			}
+			b.mu.Lock()
+			drop := b.dropAfter > 0 && nPublished >= b.dropAfter
+			if drop {
+				b.dropAfter = 0
+			}
+			b.mu.Unlock()
+			if drop {
+				return
+			}
+		case packetPubrel & 0xF0:
+			conn.Write(append([]byte{packetPubcomp, 2}, body...))
+		case packetPingreq:
+			b.mu.Lock()
+			b.pings++
+			b.mu.Unlock()
+			conn.Write([]byte{packetPingresp, 0})
+		case packetDisconnect:
+			b.mu.Lock()
+			b.disconnects++
+			b.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (b *broker) store(m *message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published = append(b.published, m)
+	if m.retain {
+		b.retained[m.topic] = string(m.payload)
+	}
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	size, mult := 0, 1
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size += int(c&0x7F) * mult
+		if c&0x80 == 0 {
+			break
+		}
+		mult *= 128
+	}
+	body := make([]byte, size)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+func parseConnect(body []byte) connectInfo {
+	str := func() string {
+		n := int(binary.BigEndian.Uint16(body))
+		s := string(body[2 : 2+n])
+		body = body[2+n:]
+		return s
+	}
+	str() // protocol name
+	flags := body[1]
+	info := connectInfo{keepAlive: binary.BigEndian.Uint16(body[2:4])}
+	body = body[4:]
+	info.clientID = str()
+	if flags&0x04 != 0 {
+		info.will = &message{qos: flags >> 3 & 3, retain: flags&0x20 != 0}
+		info.will.topic = str()
+		info.will.payload = []byte(str())
+	}
+	if flags&0x80 != 0 {
+		info.username = str()
+	}
+	if flags&0x40 != 0 {
+		info.password = str()
+	}
+	return info
+}
+
+func newTestPublisher(t *testing.T, b *broker) *Publisher {
+	p, err := New(&config.MQTT{
+		Broker:   b.addr(),
+		ClientID: "test",
+		Username: "user",
+		Password: "secret",
+		QoS:      1,
+		Topics: config.MQTTTopics{
+			Status:  config.DefaultMQTTStatusTopic,
+			Event:   config.DefaultMQTTEventTopic,
+			State:   "office/{{.Account}}/lamp",
+			Current: config.DefaultMQTTCurrentTopic,
+			Next:    config.DefaultMQTTNextTopic,
+		},
+		Timeout: config.Duration(time.Second),
+	})
+	if err != nil {
+		t.Fatalf("New() = _,%v, require nil error", err)
+	}
+	return p
+}
+
+func TestReport(t *testing.T) {
+	b := newBroker(t)
+	p := newTestPublisher(t, b)
+
+	start := time.Date(2021, 11, 1, 10, 30, 0, 0, time.UTC)
+	sync := &item.Item{
+		CalendarID: "me@example.com",
+		EventID:    "sync",
+		Title:      "Sync",
+		JoinLink:   "https://meet/sync",
+		Start:      start,
+		End:        start.Add(time.Minute * 30),
+	}
+	demo := &item.Item{
+		CalendarID: "me@example.com",
+		EventID:    "demo",
+		Title:      "Demo",
+		Start:      start.Add(time.Hour),
+		End:        start.Add(time.Hour * 2),
+	}
+	for _, test := range []struct {
+		change       *lifecycle.Change
+		wantState    string
+		wantEvent    string
+		wantCurrent  string
+		wantNext     string
+		wantMessages int
+	}{
+		{
+			change: &lifecycle.Change{
+				Account: "me@example.com",
+				Meeting: lifecycle.Meeting{Item: sync, State: lifecycle.Upcoming},
+				Next:    &lifecycle.Meeting{Item: sync, State: lifecycle.Upcoming},
+			},
+			wantState:    "upcoming",
+			wantEvent:    "upcoming",
+			wantNext:     "Sync",
+			wantMessages: 5, // status and all four topics
+		},
+		{
+			// The next meeting doesn't change
+			change: &lifecycle.Change{
+				Account: "me@example.com",
+				Meeting: lifecycle.Meeting{Item: demo, State: lifecycle.Upcoming},
+				Next:    &lifecycle.Meeting{Item: sync, State: lifecycle.Upcoming},
+			},
+			wantState:    "upcoming",
+			wantEvent:    "upcoming",
+			wantNext:     "Sync",
+			wantMessages: 6,
+		},
+		{
+			change: &lifecycle.Change{
+				Account: "me@example.com",
+				Meeting: lifecycle.Meeting{Item: sync, State: lifecycle.Starting},
+				Next:    &lifecycle.Meeting{Item: sync, State: lifecycle.Starting},
+			},
+			wantState:    "starting",
+			wantEvent:    "starting",
+			wantNext:     "Sync",
+			wantMessages: 9,
+		},
+		{
+			change: &lifecycle.Change{
+				Account: "me@example.com",
+				Meeting: lifecycle.Meeting{Item: sync, State: lifecycle.InProgress},
+				Current: &lifecycle.Meeting{Item: sync, State: lifecycle.InProgress},
+				Next:    &lifecycle.Meeting{Item: demo, State: lifecycle.Upcoming},
+			},
+			wantState:    "in-progress",
+			wantEvent:    "in-progress",
+			wantCurrent:  "Sync",
+			wantNext:     "Demo",
+			wantMessages: 13,
+		},
+		{
+			change: &lifecycle.Change{
+				Account: "me@example.com",
+				Meeting: lifecycle.Meeting{Item: sync, State: lifecycle.Ended},
+				Next:    &lifecycle.Meeting{Item: demo, State: lifecycle.Upcoming},
+			},
+			wantState:    "upcoming",
+			wantEvent:    "ended",
+			wantNext:     "Demo",
+			wantMessages: 16,
+		},
+		{
+			change: &lifecycle.Change{
+				Account: "me@example.com",
+				Meeting: lifecycle.Meeting{Item: demo, State: lifecycle.Ended},
+			},
+			wantState:    "idle",
+			wantEvent:    "ended",
+			wantMessages: 19,
+		},
+	} {
+		p.Report(test.change)
+		published, retained := b.waitFor(func(published []*message, _ map[string]string) bool {
+			return len(published) >= test.wantMessages
+		})
+		if len(published) != test.wantMessages {
+			t.Errorf("Report(%v %v): broker got %v messages, want %v", test.change.Item.Title, test.change.State, len(published), test.wantMessages)
+		}
+		if got := retained["office/me@example.com/lamp"]; got != test.wantState {
+			t.Errorf("Report(%v %v): state = %q, want %q", test.change.Item.Title, test.change.State, got, test.wantState)
+		}
+		for _, m := range []struct {
+			topic     string
+			wantField string
+			wantValue string
+		}{
+			{"goto-meet/me@example.com/event", "state", test.wantEvent},
+			{"goto-meet/me@example.com/current", "title", test.wantCurrent},
+			{"goto-meet/me@example.com/next", "title", test.wantNext},
+		} {
+			got := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(retained[m.topic]), &got); err != nil {
+				t.Fatalf("Report(%v %v): %v = %q is not JSON: %v", test.change.Item.Title, test.change.State, m.topic, retained[m.topic], err)
+			}
+			if v, _ := got[m.wantField].(string); v != m.wantValue {
+				t.Errorf("Report(%v %v): %v = %q, want %v %q", test.change.Item.Title, test.change.State, m.topic, retained[m.topic], m.wantField, m.wantValue)
+			}
+		}
+	}
+
+	if err := p.Close(); err != nil {
+		t.Errorf("Close() = %v, want nil error", err)
+	}
+	if _, retained := b.snapshot(); retained[config.DefaultMQTTStatusTopic] != statusOffline {
+		t.Errorf("Close(): status = %q, want %q", retained[config.DefaultMQTTStatusTopic], statusOffline)
+	}
+}
+
+func TestTopicFor(t *testing.T) {
+	p := newTestPublisher(t, newBroker(t))
+	for _, test := range []struct {
+		account   string
+		wantTopic string
+	}{
+		{account: "me@example.com", wantTopic: "office/me@example.com/lamp"},
+		{account: "a/b+c#d", wantTopic: "office/a_b_c_d/lamp"},
+	} {
+		got, err := topicFor(p.state, test.account)
+		if err != nil || got != test.wantTopic {
+			t.Errorf("topicFor(_, %q) = %q,%v, want %q", test.account, got, err, test.wantTopic)
+		}
+	}
+}
+
+func TestConnect(t *testing.T) {
+	b := newBroker(t)
+	b.dropAfter = 2
+	p := newTestPublisher(t, b)
+	defer p.Close()
+
+	// The broker drops the connection after the birth message and the first meeting, the client
+	// reconnects.
+	for _, title := range []string{"Sync", "Demo", "Retro"} {
+		p.Report(&lifecycle.Change{
+			Account: "me@example.com",
+			Meeting: lifecycle.Meeting{Item: &item.Item{Title: title}, State: lifecycle.Upcoming},
+		})
+	}
+	_, retained := b.waitFor(func(_ []*message, retained map[string]string) bool {
+		return strings.Contains(retained["goto-meet/me@example.com/event"], "Retro")
+	})
+	got := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(retained["goto-meet/me@example.com/event"]), &got); err != nil || got["title"] != "Retro" {
+		t.Errorf("after reconnecting, event = %q,%v, want the last meeting", retained["goto-meet/me@example.com/event"], err)
+	}
+	if retained[config.DefaultMQTTStatusTopic] != statusOnline {
+		t.Errorf("after reconnecting, status = %q, want %q", retained[config.DefaultMQTTStatusTopic], statusOnline)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.connects) < 2 {
+		t.Fatalf("broker got %v connects, want a reconnect", len(b.connects))
+	}
+	info := b.connects[0]
+	if info.clientID != "test" || info.username != "user" || info.password != "secret" || info.will == nil ||
+		info.will.topic != config.DefaultMQTTStatusTopic || string(info.will.payload) != statusOffline || !info.will.retain {
+		t.Errorf("broker got CONNECT %+v, want client ID, credentials and will", info)
+	}
+}
+
+func TestReportUnreachable(t *testing.T) {
+	// Nothing listens at the broker's address.
+	b := newBroker(t)
+	b.ln.Close()
+	p := newTestPublisher(t, b)
+	defer p.Close()
+
+	start := time.Now()
+	for _, state := range []lifecycle.State{lifecycle.Upcoming, lifecycle.Starting, lifecycle.InProgress} {
+		p.Report(&lifecycle.Change{
+			Account: "me@example.com",
+			Meeting: lifecycle.Meeting{Item: &item.Item{Title: "Sync"}, State: state},
+		})
+	}
+	if d := time.Since(start); d > time.Millisecond*500 {
+		t.Errorf("Report() to an unreachable broker took %v, want it not to wait", d)
+	}
+}
+
+func TestTopics(t *testing.T) {
+	_, err := New(&config.MQTT{
+		Broker:  "localhost:1883",
+		Timeout: config.Duration(time.Second),
+		Topics: config.MQTTTopics{
+			Status:  config.DefaultMQTTStatusTopic,
+			Event:   config.DefaultMQTTEventTopic,
+			State:   "office/{{.Calendar}}/lamp",
+			Current: config.DefaultMQTTCurrentTopic,
+			Next:    config.DefaultMQTTNextTopic,
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "cannot expand state topic") {
+		t.Errorf("New() with a topic for a calendar = _,%v, want error with %q", err, "cannot expand state topic")
+	}
+}
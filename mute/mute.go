@@ -0,0 +1,189 @@
+// Package mute keeps the list of recurring meetings that the user doesn't want to be notified of. The
+// list is stored in a file, so that it survives restarts and can be changed by other goto-meet
+// processes, such as `goto-meet --unmute`.
+package mute
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/l"
+)
+
+// Entry is a muted series of meetings.
+type Entry struct {
+	Series   string    `json:"series"`   // ID of the recurring event
+	Calendar string    `json:"calendar"` // calendar that holds the series
+	Title    string    `json:"title"`    // title of the meeting when it was muted
+	Muted    time.Time `json:"muted"`    // when it was muted
+}
+
+// List is the receiver.
+type List struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]*Entry // by series
+	modTime time.Time         // of the file when it was read
+	size    int64             // of the file when it was read
+}
+
+// Load reads a mute list. A non-existing file is an empty list.
+func Load(path string) (*List, error) {
+	m := &List{path: path, entries: map[string]*Entry{}}
+	if err := m.refresh(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Series returns the ID of the series of an item, or "" when it is not an instance of a recurring event.
+func Series(it *item.Item) string {
+	if it.Event == nil {
+		return ""
+	}
+	return it.Event.RecurringEventId
+}
+
+// Muted returns true when the series of an item is muted.
+func (m *List) Muted(it *item.Item) bool {
+	s := Series(it)
+	if s == "" {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.refresh(); err != nil {
+		l.Warnf("cannot refresh the mute list: %v", err)
+	}
+	_, ok := m.entries[s]
+	return ok
+}
+
+// Mute adds the series of an item to the list.
+func (m *List) Mute(it *item.Item) error {
+	s := Series(it)
+	if s == "" {
+		return fmt.Errorf("%v is not a recurring event", it)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.refresh(); err != nil {
+		return err
+	}
+	m.entries[s] = &Entry{
+		Series:   s,
+		Calendar: it.CalendarID,
+		Title:    it.Title,
+		Muted:    time.Now().Round(time.Second),
+	}
+	return m.save()
+}
+
+// Unmute removes a series from the list. It returns false when the series wasn't muted.
+func (m *List) Unmute(series string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.refresh(); err != nil {
+		return false, err
+	}
+	if _, ok := m.entries[series]; !ok {
+		return false, nil
+	}
+	delete(m.entries, series)
+	return true, m.save()
+}
+
+// Entries returns the muted series, by title.
+func (m *List) Entries() []*Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.refresh(); err != nil {
+		l.Warnf("cannot refresh the mute list: %v", err)
+	}
+	out := []*Entry{}
+	for _, e := range m.entries {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Title != out[j].Title {
+			return out[i].Title < out[j].Title
+		}
+		return out[i].Series < out[j].Series
+	})
+	return out
+}
+
+// refresh is a helper to read the file again when it was modified since it was read. The caller must
+// hold the lock.
+func (m *List) refresh() error {
+	st, err := os.Stat(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		m.entries = map[string]*Entry{}
+		m.modTime, m.size = time.Time{}, 0
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot stat %q: %v", m.path, err)
+	}
+	if st.ModTime().Equal(m.modTime) && st.Size() == m.size {
+		return nil
+	}
+	b, err := os.ReadFile(m.path)
+	if err != nil {
+		return fmt.Errorf("cannot read %q: %v", m.path, err)
+	}
+	entries := []*Entry{}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return fmt.Errorf("cannot parse %q: %v", m.path, err)
+	}
+	m.entries = map[string]*Entry{}
+	for _, e := range entries {
+		m.entries[e.Series] = e
+	}
+	m.modTime, m.size = st.ModTime(), st.Size()
+	return nil
+}
+
+// save is a helper to write the list. The file is replaced at once, so that readers never see half of
+// it. The caller must hold the lock.
+func (m *List) save() error {
+	entries := []*Entry{}
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Series < entries[j].Series
+	})
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode the mute list: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), ".muted-*")
+	if err != nil {
+		return fmt.Errorf("cannot write the mute list: %v", err)
+	}
+	_, err = tmp.Write(append(b, '\n'))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), m.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("cannot write %q: %v", m.path, err)
+	}
+	st, err := os.Stat(m.path)
+	if err != nil {
+		return fmt.Errorf("cannot stat %q: %v", m.path, err)
+	}
+	m.modTime, m.size = st.ModTime(), st.Size()
+	return nil
+}
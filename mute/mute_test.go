@@ -0,0 +1,78 @@
+package mute
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KarelKubat/goto-meet/item"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// recurring is a helper to create an instance of a recurring event.
+func recurring(series, title string) *item.Item {
+	return &item.Item{
+		CalendarID: "primary",
+		EventID:    series + "_20211101T100000Z",
+		Title:      title,
+		Event:      &calendar.Event{RecurringEventId: series},
+	}
+}
+
+func TestMute(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "muted.json")
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() of a missing file = _,%v, require nil error", err)
+	}
+	standup := recurring("abc", "standup")
+	retro := recurring("def", "retro")
+	single := &item.Item{EventID: "ghi", Title: "interview", Event: &calendar.Event{}}
+
+	if m.Muted(standup) {
+		t.Errorf("Muted() of an empty list = true, want false")
+	}
+	if err := m.Mute(single); err == nil {
+		t.Errorf("Mute() of a single event = nil, want error")
+	}
+	for _, it := range []*item.Item{standup, retro} {
+		if err := m.Mute(it); err != nil {
+			t.Fatalf("Mute(%v) = %v, require nil error", it, err)
+		}
+	}
+	if !m.Muted(standup) || m.Muted(single) {
+		t.Errorf("Muted() = %v,%v for a muted series and a single event, want true,false", m.Muted(standup), m.Muted(single))
+	}
+	entries := m.Entries()
+	if len(entries) != 2 || entries[0].Title != "retro" || entries[1].Series != "abc" || entries[1].Calendar != "primary" {
+		t.Errorf("Entries() = %+v, want retro and standup", entries)
+	}
+
+	// Other processes see the changes.
+	other, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() = _,%v, require nil error", err)
+	}
+	if ok, err := other.Unmute("abc"); !ok || err != nil {
+		t.Errorf("Unmute(abc) = %v,%v, want true,nil", ok, err)
+	}
+	if ok, err := other.Unmute("abc"); ok || err != nil {
+		t.Errorf("Unmute(abc) again = %v,%v, want false,nil", ok, err)
+	}
+	if m.Muted(standup) || !m.Muted(retro) {
+		t.Errorf("after unmuting elsewhere, Muted() = %v,%v, want false,true", m.Muted(standup), m.Muted(retro))
+	}
+
+	// A removed file is an empty list, a broken one is an error.
+	os.Remove(path)
+	if m.Muted(retro) {
+		t.Errorf("Muted() after removing the file = true, want false")
+	}
+	if err := os.WriteFile(path, []byte("nonsense"), 0600); err != nil {
+		t.Fatalf("os.WriteFile() = %v, require nil error", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Errorf("Load() of a broken file = _,nil, want error")
+	}
+}
@@ -0,0 +1,226 @@
+// Package quiet decides when notifications are suppressed: while they are paused, during events such as
+// out-of-office time, and outside working hours.
+package quiet
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+)
+
+// workingLocation is the type of calendar events that tell where the user works.
+const workingLocation = "workingLocation"
+
+// dateLayout is the layout of the dates of all-day events.
+const dateLayout = "2006-01-02"
+
+// Opts wraps the options to create a policy.
+type Opts struct {
+	Config    *config.Quiet  // working hours etc., may be nil
+	PauseFile string         // file that holds the end of a pause, "" when notifications can't be paused
+	Location  *time.Location // time zone of working hours, nil for local time
+}
+
+// window is a period of a day, as durations since midnight.
+type window struct {
+	from, to time.Duration
+}
+
+// Policy is the receiver.
+type Policy struct {
+	opts  *Opts
+	hours map[time.Weekday][]window // working hours per day, nil when not configured
+	mu    sync.Mutex
+	items []*item.Item // events of the last calendar poll
+}
+
+// New creates a Policy.
+func New(opts *Opts) (*Policy, error) {
+	p := &Policy{opts: opts}
+	if p.opts.Location == nil {
+		p.opts.Location = time.Local
+	}
+	if opts.Config == nil {
+		return p, nil
+	}
+	for i, wh := range opts.Config.WorkingHours {
+		from, err := config.ParseClock(wh.From)
+		if err != nil {
+			return nil, fmt.Errorf("working hours %d: %v", i, err)
+		}
+		to, err := config.ParseClock(wh.To)
+		if err != nil {
+			return nil, fmt.Errorf("working hours %d: %v", i, err)
+		}
+		days := wh.Days
+		if len(days) == 0 {
+			for d := range config.Weekdays {
+				days = append(days, d)
+			}
+		}
+		for _, d := range days {
+			wd, ok := config.Weekdays[d]
+			if !ok {
+				return nil, fmt.Errorf("working hours %d: no such day %q", i, d)
+			}
+			if p.hours == nil {
+				p.hours = map[time.Weekday][]window{}
+			}
+			p.hours[wd] = append(p.hours[wd], window{from: from, to: to})
+		}
+	}
+	return p, nil
+}
+
+// Update tells the policy which items a calendar poll returned, so that it knows about events such as
+// out-of-office time and working locations.
+func (p *Policy) Update(items []*item.Item) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.items = items
+}
+
+// Quiet returns why notifications are suppressed at a given time, or "" when they aren't.
+func (p *Policy) Quiet(now time.Time) string {
+	if until, err := PausedUntil(p.opts.PauseFile); err == nil && now.Before(until) {
+		return fmt.Sprintf("notifications are paused until %v", until.In(p.opts.Location).Format("Mon 15:04"))
+	}
+	q := p.opts.Config
+	if q == nil {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, it := range p.items {
+		if it.Event == nil || !p.covers(it, now) {
+			continue
+		}
+		for _, t := range q.EventTypes {
+			if it.Event.EventType == t {
+				return fmt.Sprintf("%s event %q is going on", t, it.Title)
+			}
+		}
+	}
+	if !p.working(now) {
+		return "it's outside working hours"
+	}
+	return ""
+}
+
+// working is a helper to determine whether a time is within working hours. Working location events,
+// when enabled, take precedence over the configured working hours: timed ones are the working hours,
+// all-day ones make their days working days. Without working hours, any time is.
+func (p *Policy) working(now time.Time) bool {
+	now = now.In(p.opts.Location)
+	if p.opts.Config.WorkingLocation {
+		found := false
+		for _, it := range p.items {
+			if it.Event == nil || it.Event.EventType != workingLocation || !p.onDay(it, now) {
+				continue
+			}
+			found = true
+			if p.covers(it, now) && (!it.AllDay || p.withinHours(now, true)) {
+				return true
+			}
+		}
+		if found {
+			return false
+		}
+	}
+	return p.withinHours(now, false)
+}
+
+// withinHours is a helper to determine whether a time is within the configured working hours. Days
+// without working hours are non-working days, unless they are working days anyway: then the whole day
+// counts.
+func (p *Policy) withinHours(now time.Time, workingDay bool) bool {
+	if p.hours == nil {
+		return true
+	}
+	windows, ok := p.hours[now.Weekday()]
+	if !ok {
+		return workingDay
+	}
+	y, m, d := now.Date()
+	since := now.Sub(time.Date(y, m, d, 0, 0, 0, 0, now.Location()))
+	for _, w := range windows {
+		if since >= w.from && since < w.to {
+			return true
+		}
+	}
+	return false
+}
+
+// covers is a helper to determine whether an event is going on at a time. All-day events cover their
+// dates in the time zone of the policy.
+func (p *Policy) covers(it *item.Item, now time.Time) bool {
+	if it.AllDay {
+		start, end := eventDates(it)
+		day := now.In(p.opts.Location).Format(dateLayout)
+		return start <= day && (day < end || end == "")
+	}
+	return !now.Before(it.Start) && now.Before(it.End)
+}
+
+// onDay is a helper to determine whether an event takes place on the day of a time, at all.
+func (p *Policy) onDay(it *item.Item, now time.Time) bool {
+	if it.AllDay {
+		return p.covers(it, now)
+	}
+	now = now.In(p.opts.Location)
+	y, m, d := now.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	return it.Start.Before(midnight.AddDate(0, 0, 1)) && it.End.After(midnight)
+}
+
+// eventDates is a helper to get the dates of an all-day event. The end date is exclusive.
+func eventDates(it *item.Item) (string, string) {
+	start, end := "", ""
+	if it.Event.Start != nil {
+		start = it.Event.Start.Date
+	}
+	if it.Event.End != nil {
+		end = it.Event.End.Date
+	}
+	return start, end
+}
+
+// Pause records in a file that notifications are paused until a time. A zero time resumes them.
+func Pause(path string, until time.Time) error {
+	if until.IsZero() {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("cannot resume notifications: %v", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(until.Format(time.RFC3339)+"\n"), 0600); err != nil {
+		return fmt.Errorf("cannot pause notifications: %v", err)
+	}
+	return nil
+}
+
+// PausedUntil returns until when notifications are paused, according to a file. The time is zero when
+// they aren't paused.
+func PausedUntil(path string) (time.Time, error) {
+	if path == "" {
+		return time.Time{}, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot read %q: %v", path, err)
+	}
+	until, err := time.Parse(time.RFC3339, strings.TrimSpace(string(b)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse %q: %v", path, err)
+	}
+	return until, nil
+}
@@ -0,0 +1,189 @@
+package quiet
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// timedEvent is a helper to create an item of an event type between two times.
+func timedEvent(title, eventType string, start, end time.Time) *item.Item {
+	return &item.Item{
+		Title: title,
+		Start: start,
+		End:   end,
+		Event: &calendar.Event{EventType: eventType},
+	}
+}
+
+// allDayEvent is a helper to create an item of an all-day event type, on dates from up to end.
+func allDayEvent(title, eventType, start, end string) *item.Item {
+	return &item.Item{
+		Title:  title,
+		AllDay: true,
+		Event: &calendar.Event{
+			EventType: eventType,
+			Start:     &calendar.EventDateTime{Date: start},
+			End:       &calendar.EventDateTime{Date: end},
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(&Opts{}); err != nil {
+		t.Errorf("New() without configuration = _,%v, want nil error", err)
+	}
+	_, err := New(&Opts{Config: &config.Quiet{WorkingHours: []*config.WorkingHours{{From: "9", To: "17:00"}}}})
+	if err == nil {
+		t.Errorf("New() with bad working hours = _,nil, want error")
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	loc := time.UTC
+	// Monday November 1st, 2021.
+	monday := func(h, m int) time.Time { return time.Date(2021, 11, 1, h, m, 0, 0, loc) }
+	hours := []*config.WorkingHours{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, From: "09:00", To: "17:30"}}
+
+	for _, test := range []struct {
+		name  string
+		quiet *config.Quiet
+		items []*item.Item
+		now   time.Time
+		want  string // part of the reason, "" when not quiet
+	}{
+		{
+			name: "no configuration",
+			now:  monday(3, 0),
+		},
+		{
+			name:  "within working hours",
+			quiet: &config.Quiet{WorkingHours: hours},
+			now:   monday(9, 0),
+		},
+		{
+			name:  "after working hours",
+			quiet: &config.Quiet{WorkingHours: hours},
+			now:   monday(17, 30),
+			want:  "outside working hours",
+		},
+		{
+			name:  "weekend",
+			quiet: &config.Quiet{WorkingHours: hours},
+			now:   monday(12, 0).AddDate(0, 0, -1),
+			want:  "outside working hours",
+		},
+		{
+			name:  "out of office",
+			quiet: &config.Quiet{EventTypes: config.DefaultQuietEventTypes},
+			items: []*item.Item{timedEvent("dentist", "outOfOffice", monday(11, 0), monday(13, 0))},
+			now:   monday(12, 0),
+			want:  `outOfOffice event "dentist"`,
+		},
+		{
+			name:  "out of office is over",
+			quiet: &config.Quiet{EventTypes: config.DefaultQuietEventTypes},
+			items: []*item.Item{timedEvent("dentist", "outOfOffice", monday(11, 0), monday(13, 0))},
+			now:   monday(13, 0),
+		},
+		{
+			name:  "all-day focus time",
+			quiet: &config.Quiet{EventTypes: config.DefaultQuietEventTypes},
+			items: []*item.Item{allDayEvent("deep work", "focusTime", "2021-11-01", "2021-11-02")},
+			now:   monday(23, 59),
+			want:  "focusTime",
+		},
+		{
+			name:  "event types that aren't configured",
+			quiet: &config.Quiet{EventTypes: []string{}},
+			items: []*item.Item{timedEvent("dentist", "outOfOffice", monday(11, 0), monday(13, 0))},
+			now:   monday(12, 0),
+		},
+		{
+			name:  "timed working location",
+			quiet: &config.Quiet{WorkingHours: hours, WorkingLocation: true},
+			items: []*item.Item{timedEvent("office", workingLocation, monday(7, 0), monday(12, 0))},
+			now:   monday(8, 0),
+		},
+		{
+			name:  "outside timed working location",
+			quiet: &config.Quiet{WorkingHours: hours, WorkingLocation: true},
+			items: []*item.Item{timedEvent("office", workingLocation, monday(7, 0), monday(12, 0))},
+			now:   monday(13, 0),
+			want:  "outside working hours",
+		},
+		{
+			name:  "all-day working location uses the working hours",
+			quiet: &config.Quiet{WorkingHours: hours, WorkingLocation: true},
+			items: []*item.Item{allDayEvent("home", workingLocation, "2021-11-01", "2021-11-02")},
+			now:   monday(20, 0),
+			want:  "outside working hours",
+		},
+		{
+			name:  "all-day working location on a weekend",
+			quiet: &config.Quiet{WorkingHours: hours, WorkingLocation: true},
+			items: []*item.Item{allDayEvent("home", workingLocation, "2021-10-31", "2021-11-01")},
+			now:   monday(20, 0).AddDate(0, 0, -1),
+		},
+		{
+			name:  "working location on another day",
+			quiet: &config.Quiet{WorkingHours: hours, WorkingLocation: true},
+			items: []*item.Item{timedEvent("office", workingLocation, monday(7, 0).AddDate(0, 0, 1), monday(12, 0).AddDate(0, 0, 1))},
+			now:   monday(16, 0),
+		},
+	} {
+		p, err := New(&Opts{Config: test.quiet, Location: loc})
+		if err != nil {
+			t.Fatalf("%v: New() = _,%v, require nil error", test.name, err)
+		}
+		p.Update(test.items)
+		got := p.Quiet(test.now)
+		switch {
+		case test.want == "" && got != "":
+			t.Errorf("%v: Quiet(%v) = %q, want not quiet", test.name, test.now, got)
+		case test.want != "" && !strings.Contains(got, test.want):
+			t.Errorf("%v: Quiet(%v) = %q, want %q", test.name, test.now, got, test.want)
+		}
+	}
+}
+
+func TestPause(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pause")
+	now := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	p, err := New(&Opts{PauseFile: path, Location: time.UTC})
+	if err != nil {
+		t.Fatalf("New() = _,%v, require nil error", err)
+	}
+	if got := p.Quiet(now); got != "" {
+		t.Errorf("Quiet() without a pause = %q, want not quiet", got)
+	}
+
+	if err := Pause(path, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Pause() = %v, require nil error", err)
+	}
+	if until, err := PausedUntil(path); err != nil || !until.Equal(now.Add(time.Hour)) {
+		t.Errorf("PausedUntil() = %v,%v, want %v", until, err, now.Add(time.Hour))
+	}
+	if got := p.Quiet(now); !strings.Contains(got, "paused until Mon 11:00") {
+		t.Errorf("Quiet() during a pause = %q, want paused", got)
+	}
+	if got := p.Quiet(now.Add(time.Hour)); got != "" {
+		t.Errorf("Quiet() after a pause = %q, want not quiet", got)
+	}
+
+	if err := Pause(path, time.Time{}); err != nil {
+		t.Fatalf("Pause() to resume = %v, require nil error", err)
+	}
+	if got := p.Quiet(now); got != "" {
+		t.Errorf("Quiet() after resuming = %q, want not quiet", got)
+	}
+	if err := Pause(path, time.Time{}); err != nil {
+		t.Errorf("Pause() to resume twice = %v, want nil error", err)
+	}
+}
@@ -0,0 +1,62 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a Clock that only moves when advanced. It is meant for tests.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter is a channel that receives the time when the fake clock reaches a stamp.
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFakeClock creates a FakeClock that starts at a given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now implements Clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// WaitUntil implements Clock. A wait for a time that the clock already reached ends at once.
+func (c *FakeClock) WaitUntil(t time.Time) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &waiter{at: t, ch: make(chan time.Time, 1)}
+	if !t.After(c.now) {
+		w.ch <- c.now
+		return w.ch
+	}
+	c.waiters = append(c.waiters, w)
+	return w.ch
+}
+
+// Advance moves the clock forward, as when time passes or when a machine wakes up from sleep. Waits
+// until the new time end. As waits are until a time, a wait that is set up concurrently with advancing
+// ends when its time has come, whichever happens first.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	waiting := []*waiter{}
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiting = append(waiting, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = waiting
+}
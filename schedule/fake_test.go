@@ -0,0 +1,50 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	now := c.WaitUntil(start)
+	soon := c.WaitUntil(start.Add(time.Minute))
+	later := c.WaitUntil(start.Add(time.Hour))
+	if got := <-now; !got.Equal(start) {
+		t.Errorf("WaitUntil(now) fires at %v, want %v", got, start)
+	}
+
+	c.Advance(time.Minute * 2)
+	if !c.Now().Equal(start.Add(time.Minute * 2)) {
+		t.Errorf("Now() after advancing = %v, want %v", c.Now(), start.Add(time.Minute*2))
+	}
+	select {
+	case got := <-soon:
+		if !got.Equal(start.Add(time.Minute * 2)) {
+			t.Errorf("WaitUntil(+1m) fires at %v, want the time it was advanced to", got)
+		}
+	default:
+		t.Errorf("WaitUntil(+1m) didn't fire after advancing 2m")
+	}
+	select {
+	case <-later:
+		t.Errorf("WaitUntil(+1h) fired after advancing 2m")
+	default:
+	}
+}
+
+func TestFakeClockLateWait(t *testing.T) {
+	// A wait that is set up after the clock passed its time ends at once.
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	c.Advance(time.Hour)
+	select {
+	case got := <-c.WaitUntil(start.Add(time.Minute)):
+		if !got.Equal(start.Add(time.Hour)) {
+			t.Errorf("WaitUntil() of a passed time fires at %v, want the time of the clock", got)
+		}
+	default:
+		t.Errorf("WaitUntil() of a passed time didn't fire")
+	}
+}
@@ -0,0 +1,215 @@
+// Package schedule runs jobs at wall clock times, also when the machine sleeps in the meantime.
+package schedule
+
+import (
+	"container/heap"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/l"
+)
+
+const (
+	// Interval between re-evaluations of the schedule against the wall clock
+	tickInterval = time.Second * 10
+)
+
+// Clock tells the time and waits. It is replaced by a FakeClock in tests. Waits are until a time
+// rather than for a duration, so that a clock that moves while a wait is set up doesn't delay it.
+type Clock interface {
+	Now() time.Time
+	WaitUntil(t time.Time) <-chan time.Time
+}
+
+// RealClock is the clock of the system.
+type RealClock struct{}
+
+// Now implements Clock.
+func (RealClock) Now() time.Time { return time.Now() }
+
+// WaitUntil implements Clock.
+func (RealClock) WaitUntil(t time.Time) <-chan time.Time { return time.After(time.Until(t)) }
+
+// Scheduler runs jobs when they are due. Jobs are identified by a key, so that they can be replaced or
+// canceled. Due times are compared against the wall clock, so that a machine that wakes up from
+// sleep runs the jobs that became due in the meantime, and lets them decide whether they are too late.
+type Scheduler struct {
+	clock Clock
+	tick  time.Duration // max time between evaluations
+	mu    sync.Mutex
+	jobs  jobQueue
+	byKey map[string]*job
+	wake  chan struct{} // signals that the schedule changed
+	done  chan struct{} // closed to stop the scheduler
+	once  sync.Once     // closes done
+}
+
+// job is a function that runs at a due time, in its own goroutine. It receives the time at which it
+// runs.
+type job struct {
+	key   string
+	due   time.Time
+	run   func(now time.Time)
+	index int // index in the queue, -1 when not queued
+}
+
+// New creates and starts a scheduler. A nil clock is the clock of the system.
+func New(c Clock) *Scheduler {
+	if c == nil {
+		c = RealClock{}
+	}
+	s := &Scheduler{
+		clock: c,
+		tick:  tickInterval,
+		byKey: map[string]*job{},
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// Now returns the wall clock time of the scheduler.
+func (s *Scheduler) Now() time.Time {
+	// Stripping the monotonic clock reading makes comparisons use the wall clock, which, unlike the
+	// monotonic clock, advances while the machine sleeps.
+	return s.clock.Now().Round(0)
+}
+
+// At schedules a job to run at a due time. A job with the same key is replaced.
+func (s *Scheduler) At(key string, due time.Time, run func(now time.Time)) {
+	s.mu.Lock()
+	if j, ok := s.byKey[key]; ok {
+		j.due, j.run = due.Round(0), run
+		heap.Fix(&s.jobs, j.index)
+	} else {
+		j := &job{key: key, due: due.Round(0), run: run}
+		s.byKey[key] = j
+		heap.Push(&s.jobs, j)
+	}
+	s.mu.Unlock()
+	s.signal()
+}
+
+// Cancel removes the job with a key. It returns false when there is no such job, e.g. because it
+// already ran.
+func (s *Scheduler) Cancel(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.byKey[key]
+	if !ok {
+		return false
+	}
+	heap.Remove(&s.jobs, j.index)
+	delete(s.byKey, key)
+	return true
+}
+
+// CancelPrefix removes the jobs with keys that start with a prefix, and returns how many were removed.
+func (s *Scheduler) CancelPrefix(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key, j := range s.byKey {
+		if strings.HasPrefix(key, prefix) {
+			heap.Remove(&s.jobs, j.index)
+			delete(s.byKey, key)
+			n++
+		}
+	}
+	return n
+}
+
+// Due returns when the job with a key is due, and false when there is no such job.
+func (s *Scheduler) Due(key string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.byKey[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	return j.due, true
+}
+
+// Close stops the scheduler. Jobs that didn't run yet are dropped. Closing it again does nothing.
+func (s *Scheduler) Close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// signal is a helper to wake up the loop, when it isn't signaled already.
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// loop is a helper to run due jobs until the scheduler is closed.
+func (s *Scheduler) loop() {
+	for {
+		next := s.runDue()
+		select {
+		case <-s.clock.WaitUntil(next):
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// runDue is a helper to start the jobs that are due. It returns when to look again: when the next job
+// is due, but at most a tick later, as the wall clock may jump ahead, e.g. when the machine wakes up.
+func (s *Scheduler) runDue() time.Time {
+	s.mu.Lock()
+	now := s.Now()
+	due := []*job{}
+	for len(s.jobs) > 0 && !s.jobs[0].due.After(now) {
+		j := heap.Pop(&s.jobs).(*job)
+		delete(s.byKey, j.key)
+		due = append(due, j)
+	}
+	wait := s.tick
+	if len(s.jobs) > 0 {
+		if d := s.jobs[0].due.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	s.mu.Unlock()
+
+	for _, j := range due {
+		if late := now.Sub(j.due); late > s.tick+time.Second {
+			l.Infof("time skew detected, %v runs %v late", j.key, late)
+		}
+		go j.run(now)
+	}
+	return now.Add(wait)
+}
+
+// jobQueue is a priority queue of jobs, ordered by due time. It implements heap.Interface.
+type jobQueue []*job
+
+func (q jobQueue) Len() int { return len(q) }
+
+func (q jobQueue) Less(i, j int) bool { return q[i].due.Before(q[j].due) }
+
+func (q jobQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *jobQueue) Push(x interface{}) {
+	j := x.(*job)
+	j.index = len(*q)
+	*q = append(*q, j)
+}
+
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	j := old[len(old)-1]
+	old[len(old)-1] = nil
+	j.index = -1
+	*q = old[:len(old)-1]
+	return j
+}
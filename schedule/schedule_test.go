@@ -0,0 +1,104 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// ran is a helper to wait for the name of a job that ran, or "" when none runs for a while.
+func ran(ch chan string) string {
+	select {
+	case name := <-ch:
+		return name
+	case <-time.After(time.Millisecond * 200):
+		return ""
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	c := NewFakeClock(time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC))
+	s := New(c)
+	defer s.Close()
+	start := c.Now()
+
+	ch := make(chan string, 10)
+	job := func(name string) func(time.Time) {
+		return func(time.Time) { ch <- name }
+	}
+	s.At("late", start.Add(time.Minute*3), job("late"))
+	s.At("early", start.Add(time.Minute), job("early"))
+	s.At("canceled", start.Add(time.Minute*2), job("canceled"))
+	s.At("replaced", start.Add(time.Second), job("old"))
+	s.At("replaced", start.Add(time.Minute*4), job("new"))
+	s.At("prefix::a", start.Add(time.Minute), job("prefix"))
+	s.At("prefix::b", start.Add(time.Minute), job("prefix"))
+
+	if !s.Cancel("canceled") {
+		t.Errorf("Cancel() of a scheduled job = false, want true")
+	}
+	if s.Cancel("canceled") {
+		t.Errorf("Cancel() of a canceled job = true, want false")
+	}
+	if n := s.CancelPrefix("prefix::"); n != 2 {
+		t.Errorf("CancelPrefix() = %v, want 2", n)
+	}
+	if due, ok := s.Due("replaced"); !ok || !due.Equal(start.Add(time.Minute*4)) {
+		t.Errorf("Due() of a replaced job = %v,%v, want %v", due, ok, start.Add(time.Minute*4))
+	}
+
+	if got := ran(ch); got != "" {
+		t.Errorf("job %q ran before it was due", got)
+	}
+	for _, test := range []struct {
+		advance time.Duration
+		want    string
+	}{
+		{advance: time.Minute, want: "early"},
+		{advance: time.Minute, want: ""},
+		{advance: time.Minute, want: "late"},
+		{advance: time.Minute, want: "new"},
+	} {
+		c.Advance(test.advance)
+		if got := ran(ch); got != test.want {
+			t.Errorf("after advancing the clock to %v, job %q ran, want %q", c.Now().Sub(start), got, test.want)
+		}
+	}
+	c.Advance(time.Hour)
+	if got := ran(ch); got != "" {
+		t.Errorf("job %q ran, want none", got)
+	}
+	if _, ok := s.Due("new"); ok {
+		t.Errorf("Due() of a job that ran = _,true, want false")
+	}
+	s.Close()
+	s.Close()
+}
+
+func TestSchedulerWake(t *testing.T) {
+	c := NewFakeClock(time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC))
+	s := New(c)
+	defer s.Close()
+	start := c.Now()
+
+	// Jobs that became due while the machine slept all run when it wakes up, in order, with the time of
+	// waking up.
+	ch := make(chan string, 10)
+	for _, name := range []string{"second", "first"} {
+		due := start.Add(time.Hour)
+		if name == "first" {
+			due = start.Add(time.Minute)
+		}
+		name := name
+		s.At(name, due, func(now time.Time) {
+			if !now.Equal(start.Add(time.Hour * 8)) {
+				t.Errorf("job %q runs at %v, want the time of waking up", name, now)
+			}
+			ch <- name
+		})
+	}
+	c.Advance(time.Hour * 8)
+	got := map[string]bool{ran(ch): true, ran(ch): true}
+	if !got["first"] || !got["second"] {
+		t.Errorf("after waking up, jobs %v ran, want first and second", got)
+	}
+}
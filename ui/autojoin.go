@@ -0,0 +1,185 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/l"
+)
+
+// autoJoin is a rule for meetings that are joined without asking.
+type autoJoin struct {
+	cfg   *config.AutoJoin
+	title *regexp.Regexp
+}
+
+// newAutoJoins is a helper to compile the auto-join rules of a configuration.
+func newAutoJoins(cfg *config.Config) ([]*autoJoin, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	out := []*autoJoin{}
+	for _, a := range cfg.AutoJoin {
+		re, err := regexp.Compile(a.Title)
+		if err != nil {
+			return nil, fmt.Errorf("cannot compile auto-join title %q: %v", a.Title, err)
+		}
+		out = append(out, &autoJoin{cfg: a, title: re})
+	}
+	return out, nil
+}
+
+// matches returns true when a rule applies to an item.
+func (a *autoJoin) matches(it *item.Item) bool {
+	if !a.title.MatchString(it.Title) {
+		return false
+	}
+	return len(a.cfg.Calendars) == 0 || contains(a.cfg.Calendars, it.CalendarID)
+}
+
+// autoJoinFor is a helper to find the first auto-join rule that applies to an item, or nil.
+func (n *Notifier) autoJoinFor(it *item.Item) *autoJoin {
+	for _, a := range n.autoJoins {
+		if a.matches(it) {
+			return a
+		}
+	}
+	return nil
+}
+
+// autoJoinKey is a helper to derive the keys in the scheduler of the countdown and of the joining of an
+// event. They start with the prefix of the event's reminders, so that they are canceled along with
+// these when the event is modified.
+func autoJoinKey(it *item.Item, what string) string {
+	return reminderKey(it, nil) + "autojoin::" + what
+}
+
+// scheduleAutoJoin is a helper to arrange for joining a meeting without asking. The countdown starts
+// ahead of joining it.
+func (n *Notifier) scheduleAutoJoin(it *item.Item, a *autoJoin) {
+	at := it.Start.Add(-time.Duration(a.cfg.Before)).Add(-time.Duration(a.cfg.Countdown))
+	l.Infof("auto-join countdown at %v for event %v", at, it)
+	n.sched.At(autoJoinKey(it, "countdown"), at, func(now time.Time) {
+		n.countdown(it, a, now)
+	})
+}
+
+// countdown is a helper to tell the user that a meeting is about to be joined, and to join it unless the
+// user cancels. Skipping or snoozing the notification cancels, choosing to join joins right away.
+func (n *Notifier) countdown(it *item.Item, a *autoJoin, now time.Time) {
+	switch {
+	case now.After(deadline(it, n.opts.Grace).Add(time.Second)):
+		l.Infof("skipping auto-join of %v, it's too much in the past", it)
+		return
+	case n.processed.Superseded(it):
+		l.Infof("skipping auto-join of %v, it was modified in the meantime", it)
+		return
+	case n.quiet(it, now):
+		return
+	case !n.processed.FireAutoJoin(it):
+		l.Infof("skipping auto-join of %v, it was already joined", it)
+		return
+	}
+
+	// When the countdown starts late, e.g. after waking up, the user still gets all of it.
+	countdown := time.Duration(a.cfg.Countdown)
+	joinAt := it.Start.Add(-time.Duration(a.cfg.Before))
+	if joinAt.Before(now.Add(countdown)) {
+		joinAt = now.Add(countdown)
+	}
+	joinKey := autoJoinKey(it, "join")
+	n.sched.At(joinKey, joinAt, func(time.Time) {
+		n.autoJoin(it)
+	})
+
+	notification := newNotification(it, int(countdown.Round(time.Second).Seconds()))
+	notification.AutoJoin = true
+	notification.Series = ""
+	notification.Title = joiningTitle(it.Title, joinAt.Sub(now))
+	notification.stale = func() bool {
+		return n.stale([]*item.Item{it})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), joinAt.Sub(now))
+	defer cancel()
+	// Meetings get one notification, the last stage is closest to the start.
+	st := n.stages[len(n.stages)-1]
+	if st.sound.playsFor(config.AlertAutoJoin) {
+		st.sound.play(notification)
+	}
+	action := showAll(ctx, st.backends, notification)
+	l.Infof("auto-join countdown for %v: user chose %v", it, action)
+	switch action.Kind {
+	case KindSkip, KindSnooze:
+		if n.sched.Cancel(joinKey) {
+			l.Infof("auto-join of %v canceled", it)
+		} else {
+			l.Infof("cannot cancel auto-join of %v, it was already joined", it)
+		}
+	case KindJoin:
+		if n.sched.Cancel(joinKey) {
+			n.autoJoin(it)
+		}
+	case KindCalendar:
+		if err := openItem(n.opts, it, config.LinkCalendar); err != nil {
+			l.Warnf("cannot perform %v for %v: %v", action, it, err)
+		}
+	case KindNotes:
+		if err := openNotes(n.opts, it); err != nil {
+			l.Warnf("cannot perform %v for %v: %v", action, it, err)
+		}
+	}
+}
+
+// autoJoin is a helper to join a meeting without asking, and to record that it happened.
+func (n *Notifier) autoJoin(it *item.Item) {
+	if n.processed.Superseded(it) {
+		l.Infof("skipping auto-join of %v, it was modified in the meantime", it)
+		return
+	}
+	err := join(n.opts, it)
+	if err != nil {
+		l.Warnf("cannot auto-join %v: %v", it, err)
+	} else {
+		l.Infof("auto-joined %v", it)
+	}
+	if rerr := recordJoin(n.opts.JoinRecord, it, time.Now(), err); rerr != nil {
+		l.Warnf("cannot record auto-join of %v: %v", it, rerr)
+	}
+}
+
+// recordJoin is a helper to append a line about an auto-join to a file. An empty path records nothing.
+func recordJoin(path string, it *item.Item, now time.Time, joinErr error) error {
+	if path == "" {
+		return nil
+	}
+	outcome := "joined"
+	if joinErr != nil {
+		outcome = "failed: " + joinErr.Error()
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot open %q: %v", path, err)
+	}
+	_, err = fmt.Fprintf(f, "%s\t%s\t%s\t%s\t%q\n", now.Format(time.RFC3339), outcome, it.CalendarID, it.JoinLink, it.Title)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write %q: %v", path, err)
+	}
+	return nil
+}
+
+// joiningTitle is a helper to tell in a title that a meeting is about to be joined.
+func joiningTitle(title string, in time.Duration) string {
+	seconds := "1 second"
+	if s := int(in.Round(time.Second).Seconds()); s != 1 {
+		seconds = fmt.Sprintf("%d seconds", s)
+	}
+	return fmt.Sprintf("Joining %s in %s — skip to cancel", title, seconds)
+}
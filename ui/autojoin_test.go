@@ -0,0 +1,164 @@
+package ui
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/cache"
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/schedule"
+)
+
+func TestAutoJoinMatches(t *testing.T) {
+	rules, err := newAutoJoins(&config.Config{AutoJoin: []*config.AutoJoin{
+		{Title: "^standup$"},
+		{Title: "sync", Calendars: []string{"team"}},
+	}})
+	if err != nil {
+		t.Fatalf("newAutoJoins() = _,%v, require nil error", err)
+	}
+	n := &Notifier{autoJoins: rules}
+	for _, test := range []struct {
+		title    string
+		calendar string
+		want     bool
+	}{
+		{title: "standup", calendar: "primary", want: true},
+		{title: "standup with guests", calendar: "primary", want: false},
+		{title: "weekly sync", calendar: "team", want: true},
+		{title: "weekly sync", calendar: "primary", want: false},
+	} {
+		it := &item.Item{Title: test.title, CalendarID: test.calendar}
+		if got := n.autoJoinFor(it) != nil; got != test.want {
+			t.Errorf("autoJoinFor(%q in %q) = %v, want %v", test.title, test.calendar, got, test.want)
+		}
+	}
+}
+
+func TestJoiningTitle(t *testing.T) {
+	for _, test := range []struct {
+		in   time.Duration
+		want string
+	}{
+		{in: time.Second * 10, want: "Joining standup in 10 seconds — skip to cancel"},
+		{in: time.Second, want: "Joining standup in 1 second — skip to cancel"},
+		{in: time.Millisecond * 400, want: "Joining standup in 0 seconds — skip to cancel"},
+	} {
+		if got := joiningTitle("standup", test.in); got != test.want {
+			t.Errorf("joiningTitle(_, %v) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestRecordJoin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "joined.log")
+	now := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	it := &item.Item{Title: "standup", CalendarID: "primary", JoinLink: "https://meet"}
+	if err := recordJoin(path, it, now, nil); err != nil {
+		t.Fatalf("recordJoin() = %v, require nil error", err)
+	}
+	if err := recordJoin(path, it, now, errors.New("no browser")); err != nil {
+		t.Fatalf("recordJoin() = %v, require nil error", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("os.ReadFile(%q) = _,%v, require nil error", path, err)
+	}
+	want := "2021-11-01T10:00:00Z\tjoined\tprimary\thttps://meet\t\"standup\"\n" +
+		"2021-11-01T10:00:00Z\tfailed: no browser\tprimary\thttps://meet\t\"standup\"\n"
+	if string(b) != want {
+		t.Errorf("recordJoin() wrote %q, want %q", b, want)
+	}
+	if err := recordJoin("", it, now, nil); err != nil {
+		t.Errorf("recordJoin() without a path = %v, want nil error", err)
+	}
+}
+
+func TestAutoJoin(t *testing.T) {
+	c := newFakeClock()
+	fb := &fakeBackend{action: ActionNone}
+	record := filepath.Join(t.TempDir(), "joined.log")
+	rules, err := newAutoJoins(&config.Config{AutoJoin: []*config.AutoJoin{
+		{Title: "standup|retro", Countdown: config.Duration(time.Second * 10)},
+	}})
+	if err != nil {
+		t.Fatalf("newAutoJoins() = _,%v, require nil error", err)
+	}
+	// The browser "true" opens nothing on Linux, elsewhere opening fails; either way the join is recorded.
+	n := &Notifier{
+		opts:      &Opts{Browser: "true", JoinRecord: record},
+		stages:    []*stage{{before: time.Minute, backends: []Backend{fb}}},
+		processed: cache.New(),
+		sched:     schedule.New(c),
+		autoJoins: rules,
+	}
+	defer n.Close()
+	joined := func() []string {
+		time.Sleep(time.Millisecond * 100)
+		b, err := os.ReadFile(record)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatalf("os.ReadFile(%q) = _,%v, require nil error", record, err)
+		}
+		return strings.Split(strings.TrimSpace(string(b)), "\n")
+	}
+
+	start := c.Now().Add(time.Minute * 10)
+	standup := &item.Item{
+		EventID:  "standup",
+		Title:    "standup",
+		JoinLink: "https://meet/standup",
+		Version:  "1",
+		StartsIn: time.Minute * 10,
+		Start:    start,
+		End:      start.Add(time.Minute * 15),
+	}
+	n.Schedule(standup)
+	if due, ok := n.sched.Due(autoJoinKey(standup, "countdown")); !ok || !due.Equal(start.Add(-time.Second*10)) {
+		t.Fatalf("Schedule() countdown due = %v,%v, want 10s before the start", due, ok)
+	}
+	if _, ok := n.sched.Due(reminderKey(standup, n.stages[0])); ok {
+		t.Errorf("Schedule() of a meeting that is joined without asking scheduled a reminder")
+	}
+
+	c.Advance(time.Minute*10 - time.Second*10)
+	if got := joined(); len(got) != 0 {
+		t.Errorf("after the countdown started, joined %q, want none", got)
+	}
+	fb.mu.Lock()
+	if len(fb.shown) != 1 || !fb.shown[0].AutoJoin || fb.shown[0].Title != "Joining standup in 10 seconds — skip to cancel" {
+		t.Errorf("countdown notifications = %+v, want one", fb.shown)
+	}
+	fb.mu.Unlock()
+	c.Advance(time.Second * 10)
+	if got := joined(); len(got) != 1 || !strings.Contains(got[0], "standup") {
+		t.Errorf("after the countdown, recorded %q, want standup joined", got)
+	}
+
+	// Skipping the countdown cancels joining.
+	fb.mu.Lock()
+	fb.action = ActionSkip
+	fb.mu.Unlock()
+	retro := &item.Item{
+		EventID:  "retro",
+		Title:    "retro",
+		JoinLink: "https://meet/retro",
+		Version:  "1",
+		StartsIn: time.Minute * 10,
+		Start:    c.Now().Add(time.Minute * 10),
+		End:      c.Now().Add(time.Hour),
+	}
+	n.Schedule(retro)
+	c.Advance(time.Minute * 10)
+	c.Advance(time.Minute)
+	if got := joined(); len(got) != 1 {
+		t.Errorf("after skipping the countdown, recorded %q, want only standup", got)
+	}
+}
@@ -0,0 +1,238 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/item"
+)
+
+// Kind is the kind of thing that the user chose to do with a notification.
+type Kind int
+
+const (
+	// KindNone means that the notification was dismissed or timed out.
+	KindNone Kind = iota
+	// KindJoin means that the user wants to join the meeting.
+	KindJoin
+	// KindCalendar means that the user wants to see the event in the calendar.
+	KindCalendar
+	// KindSkip means that the user is not interested.
+	KindSkip
+	// KindSnooze means that the user wants to be notified again a bit later.
+	KindSnooze
+	// KindMute means that the user doesn't want to be notified of the meeting's series anymore.
+	KindMute
+	// KindNotes means that the user wants to see the attachments and documents of the meeting.
+	KindNotes
+)
+
+// Action is what the user chose to do with a notification.
+type Action struct {
+	Kind   Kind
+	Snooze time.Duration // for snoozes: how long, SnoozeUntilStart, or 0 for the first snooze choice
+	Item   int           // for joins, calendars, mutes and notes: the index of the meeting in Notification.Items
+}
+
+// The actions that a user can choose. Snoozes for a specific duration are created by snoozeFor.
+var (
+	ActionNone     = Action{Kind: KindNone}
+	ActionJoin     = Action{Kind: KindJoin}
+	ActionCalendar = Action{Kind: KindCalendar}
+	ActionSkip     = Action{Kind: KindSkip}
+	ActionSnooze   = Action{Kind: KindSnooze}
+	ActionMute     = Action{Kind: KindMute}
+	ActionNotes    = Action{Kind: KindNotes}
+)
+
+// snoozeFor returns the action to snooze for a duration, or until the event starts.
+func snoozeFor(d time.Duration) Action {
+	return Action{Kind: KindSnooze, Snooze: d}
+}
+
+// String returns a readable representation of an action, such as "join" or "snooze 5m".
+func (a Action) String() string {
+	switch a.Kind {
+	case KindNone:
+		return "none"
+	case KindJoin:
+		return "join" + itemSuffix(a.Item)
+	case KindCalendar:
+		return "calendar" + itemSuffix(a.Item)
+	case KindSkip:
+		return "skip"
+	case KindSnooze:
+		if a.Snooze == 0 {
+			return "snooze"
+		}
+		return "snooze " + snoozeName(a.Snooze)
+	case KindMute:
+		return "mute" + itemSuffix(a.Item)
+	case KindNotes:
+		return "notes" + itemSuffix(a.Item)
+	}
+	return fmt.Sprintf("action(%d)", int(a.Kind))
+}
+
+// itemSuffix is a helper to name the meeting of an action, when it's not the first one.
+func itemSuffix(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" meeting %d", i+1)
+}
+
+// parseAction is a helper to convert the name of an action, as returned by String(), to an Action.
+func parseAction(s string) (Action, error) {
+	if rest := strings.TrimPrefix(s, "snooze "); rest != s {
+		d, err := parseSnoozeName(rest)
+		if err != nil {
+			return ActionNone, fmt.Errorf("no such action %q: %v", s, err)
+		}
+		return snoozeFor(d), nil
+	}
+	for _, a := range []Action{ActionNone, ActionJoin, ActionCalendar, ActionSkip, ActionSnooze, ActionMute, ActionNotes} {
+		if a.String() == s {
+			return a, nil
+		}
+	}
+	return ActionNone, fmt.Errorf("no such action %q", s)
+}
+
+// Notification is what a backend presents to the user. Its fields are available in templates, as in
+// {{.Title}}.
+type Notification struct {
+	Item          *item.Item    // the event to notify about
+	Title         string        // event title, or the titles of grouped meetings
+	JoinLink      string        // link to join the meet
+	CalendarLink  string        // link to see the event on the calendar
+	Notes         []string      // links to the attachments and documents of the event, such as agendas
+	Calendar      string        // calendar that holds the event
+	Attendees     []string      // email addresses of the attendees
+	Start         time.Time     // event start stamp
+	VisibilitySec int           // # secs on screen
+	Snooze        []string      // snooze choices, as in "5m" or "start"
+	Items         []*item.Item  // the meetings, more than one when grouped; the first one is Item
+	Conflict      bool          // grouped meetings overlap in time
+	Started       time.Duration // how long ago the meeting started, in whole minutes; 0 when it didn't start yet
+	End           time.Time     // event end stamp
+	Ending        bool          // the meeting ends soon, this is not a reminder of its start
+	Next          *item.Item    // for meetings that end soon: the meeting that starts right after, or nil
+	AutoJoin      bool          // the meeting is joined when the notification times out, unless the user skips
+	Series        string        // ID of the recurring event that the meeting is part of, or ""
+
+	stale func() bool // tells whether showing the notification became pointless, may be nil
+}
+
+// newNotification is a helper to create a Notification for an item.
+func newNotification(it *item.Item, visibilitySec int) Notification {
+	n := Notification{
+		Item:          it,
+		Title:         it.Title,
+		JoinLink:      it.JoinLink,
+		CalendarLink:  it.CalendarLink,
+		Calendar:      it.CalendarID,
+		Attendees:     []string{},
+		Notes:         append([]string{}, it.Notes...),
+		Start:         it.Start,
+		End:           it.End,
+		VisibilitySec: visibilitySec,
+		Items:         []*item.Item{it},
+	}
+	if it.Event != nil {
+		n.Series = it.Event.RecurringEventId
+		for _, a := range it.Event.Attendees {
+			n.Attendees = append(n.Attendees, a.Email)
+		}
+	}
+	return n
+}
+
+// Backend is the interface that notification renderers implement.
+type Backend interface {
+	// Show presents a notification to the user and returns the chosen action. It returns ActionNone
+	// when the user didn't respond, or when the backend has no way of asking.
+	Show(ctx context.Context, n Notification) (Action, error)
+}
+
+// detachedBackend is implemented by backends that deliver notifications elsewhere, such as webhooks and
+// emails. Nobody answers them there, so their deliveries aren't withdrawn when the user answers another
+// backend.
+type detachedBackend interface {
+	detached() bool
+}
+
+// agendaBackend is implemented by backends that also show the upcoming meetings.
+type agendaBackend interface {
+	// Agenda passes the items of the last calendar poll.
+	Agenda(items []*item.Item)
+}
+
+// backendType binds the name of a backend, as in --notification=NAME, to its constructor.
+type backendType struct {
+	name   string
+	create func(opts *Opts) (Backend, error)
+}
+
+// backendTypes are the known backends.
+var backendTypes = []*backendType{
+	{
+		name:   "macos_osascript",
+		create: newOsascript,
+	},
+	{
+		name:   "linux_dbus",
+		create: newDbus,
+	},
+	{
+		name:   "zenity",
+		create: newZenity,
+	},
+	{
+		name:   "kdialog",
+		create: newKdialog,
+	},
+	{
+		name:   "yad",
+		create: newYad,
+	},
+	{
+		name:   "terminal",
+		create: newTerminal,
+	},
+}
+
+// newBackend creates the backend with the given name. Notifiers and webhooks from the configuration
+// file take precedence over the built-in types.
+func newBackend(name string, opts *Opts) (Backend, error) {
+	available := []string{}
+	if opts.Config != nil {
+		for _, n := range opts.Config.Notifiers {
+			if n.Name == name {
+				return newUserCommand(n)
+			}
+			available = append(available, n.Name)
+		}
+		for _, w := range opts.Config.Webhooks {
+			if w.Name == name {
+				return newWebhook(w)
+			}
+			available = append(available, w.Name)
+		}
+		for _, e := range opts.Config.Emails {
+			if e.Name == name {
+				return newEmail(e)
+			}
+			available = append(available, e.Name)
+		}
+	}
+	for _, bt := range backendTypes {
+		if bt.name == name {
+			return bt.create(opts)
+		}
+		available = append(available, bt.name)
+	}
+	return nil, fmt.Errorf("no such notification type %q, choose one of %v", name, available)
+}
@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActionString(t *testing.T) {
+	for _, test := range []struct {
+		action Action
+		want   string
+	}{
+		{action: ActionNone, want: "none"},
+		{action: ActionJoin, want: "join"},
+		{action: ActionCalendar, want: "calendar"},
+		{action: ActionSkip, want: "skip"},
+		{action: ActionSnooze, want: "snooze"},
+		{action: snoozeFor(time.Minute * 5), want: "snooze 5m"},
+		{action: snoozeFor(SnoozeUntilStart), want: "snooze start"},
+		{action: ActionMute, want: "mute"},
+		{action: ActionNotes, want: "notes"},
+		{action: Action{Kind: 99}, want: "action(99)"},
+	} {
+		if got := test.action.String(); got != test.want {
+			t.Errorf("%+v.String() = %q, want %q", test.action, got, test.want)
+		}
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	for _, test := range []struct {
+		s          string
+		wantAction Action
+		wantError  bool
+	}{
+		{s: "join", wantAction: ActionJoin},
+		{s: "snooze", wantAction: ActionSnooze},
+		{s: "snooze 2m", wantAction: snoozeFor(time.Minute * 2)},
+		{s: "mute", wantAction: ActionMute},
+		{s: "notes", wantAction: ActionNotes},
+		{s: "snooze until start", wantAction: snoozeFor(SnoozeUntilStart)},
+		{s: "snooze forever", wantError: true},
+		{s: "nonsense", wantError: true},
+	} {
+		action, err := parseAction(test.s)
+		if (err != nil) != test.wantError {
+			t.Errorf("parseAction(%q) = _,%v, want error: %v", test.s, err, test.wantError)
+		}
+		if action != test.wantAction {
+			t.Errorf("parseAction(%q) = %v,_, want %v", test.s, action, test.wantAction)
+		}
+	}
+}
+
+func TestNewBackend(t *testing.T) {
+	for _, bt := range backendTypes {
+		// The terminal backend takes over the terminal of the test, see terminal_test.go instead.
+		if bt.name == "terminal" {
+			continue
+		}
+		b, err := newBackend(bt.name, &Opts{})
+		if err != nil {
+			t.Errorf("newBackend(%q) = _,%v, want nil error", bt.name, err)
+		}
+		if b == nil {
+			t.Errorf("newBackend(%q) = nil,_, want a backend", bt.name)
+		}
+	}
+	_, err := newBackend("nonsense", &Opts{})
+	if err == nil || !strings.Contains(err.Error(), "macos_osascript") {
+		t.Errorf("newBackend(nonsense) = _,%v, want error listing the available types", err)
+	}
+}
@@ -0,0 +1,119 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"text/template"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/l"
+)
+
+// commandGraceTime is the extra time that a command gets to terminate after its notification should
+// have disappeared. Commands that take longer are killed.
+const commandGraceTime = time.Second * 10
+
+// command is a Backend that runs an external program. The program is started using the expanded
+// `args`, followed by the literal `extra` ones, and receives the expanded `stdin`, if any. Its output
+// is interpreted by `parse`. Arguments that expand to an empty string are dropped, so that optional
+// flags can be templated as in {{if .VisibilitySec}}--timeout={{.VisibilitySec}}{{end}}.
+type command struct {
+	args    []*template.Template
+	extra   []string
+	stdin   *template.Template
+	parse   func(out []byte, err error) (Action, error)
+	isModal bool // the program shows a dialog that waits for the user
+}
+
+// modal implements modalBackend.
+func (c *command) modal() bool {
+	return c.isModal
+}
+
+// templates is a helper to parse strings into templates for an escaping context, e.g. to create the
+// args of a command.
+func templates(name, context string, strs ...string) ([]*template.Template, error) {
+	out := []*template.Template{}
+	for i, s := range strs {
+		tpl, err := newTemplate(fmt.Sprintf("%s[%d]", name, i), context, s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, tpl)
+	}
+	return out, nil
+}
+
+// mustTemplates is like templates, but panics upon errors. It's meant for built-in templates.
+func mustTemplates(name, context string, strs ...string) []*template.Template {
+	out, err := templates(name, context, strs...)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// Show implements Backend.
+func (c *command) Show(ctx context.Context, n Notification) (Action, error) {
+	args := []string{}
+	for _, tpl := range c.args {
+		buf := new(bytes.Buffer)
+		if err := tpl.Execute(buf, n); err != nil {
+			return ActionNone, fmt.Errorf("cannot execute template: %v", err)
+		}
+		if buf.Len() > 0 {
+			args = append(args, buf.String())
+		}
+	}
+	args = append(args, c.extra...)
+	if len(args) == 0 {
+		return ActionNone, errors.New("command is empty")
+	}
+	l.Infof("command: %q", args)
+
+	if n.VisibilitySec > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(n.VisibilitySec)*time.Second+commandGraceTime)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if c.stdin != nil {
+		buf := new(bytes.Buffer)
+		if err := c.stdin.Execute(buf, n); err != nil {
+			return ActionNone, fmt.Errorf("cannot execute template: %v", err)
+		}
+		l.Infof("template: %v", buf.String())
+		cmd.Stdin = buf
+	}
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if stderr.Len() > 0 {
+		l.Warnf("%v: %v", args[0], stderr.String())
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		l.Warnf("%v: killed, it didn't terminate in time", args[0])
+		return ActionNone, nil
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// Another backend answered first.
+		return ActionNone, ctx.Err()
+	}
+	return c.parse(out, err)
+}
+
+// exitCode is a helper to extract the exit code of a command from the error that running it
+// returned. It returns false when the command didn't run or didn't exit normally.
+func exitCode(err error) (int, bool) {
+	if err == nil {
+		return 0, true
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode(), true
+	}
+	return 0, false
+}
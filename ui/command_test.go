@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"text/template"
+)
+
+func TestCommandShow(t *testing.T) {
+	stdinFile := filepath.Join(t.TempDir(), "stdin")
+	c := &command{
+		// Save stdin for inspection and echo a button.
+		args:  mustTemplates("test", EscapeNone, "sh", "-c", "cat > "+stdinFile+"; echo Calendar"),
+		stdin: template.Must(template.New("test").Parse("title={{.Title}} sec={{.VisibilitySec}}")),
+		parse: parseButton,
+	}
+	action, err := c.Show(context.Background(), Notification{
+		Title:         "standup",
+		VisibilitySec: 10,
+	})
+	if err != nil {
+		t.Fatalf("Show() = _,%v, require nil error", err)
+	}
+	if action != ActionCalendar {
+		t.Errorf("Show() = %v,_, want %v", action, ActionCalendar)
+	}
+	b, err := os.ReadFile(stdinFile)
+	if err != nil {
+		t.Fatalf("cannot read back stdin: %v", err)
+	}
+	if want := "title=standup sec=10"; string(b) != want {
+		t.Errorf("Show() piped %q to the command, want %q", string(b), want)
+	}
+}
+
+func TestCommandShowFailure(t *testing.T) {
+	c := &command{
+		args:  mustTemplates("test", EscapeNone, "sh", "-c", "exit 1"),
+		parse: parseButton,
+	}
+	if _, err := c.Show(context.Background(), Notification{}); err == nil {
+		t.Errorf("Show() = _,nil for a failing command, want error")
+	}
+}
+
+func TestCommandShowEmpty(t *testing.T) {
+	c := &command{
+		args:  mustTemplates("test", EscapeNone, "{{if .Calendar}}notify-send{{end}}"),
+		parse: parseButton,
+	}
+	if _, err := c.Show(context.Background(), Notification{}); err == nil {
+		t.Errorf("Show() = _,nil for a command that expands to nothing, want error")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	for _, test := range []struct {
+		args     []string
+		wantCode int
+		wantOK   bool
+	}{
+		{args: []string{"sh", "-c", "exit 0"}, wantCode: 0, wantOK: true},
+		{args: []string{"sh", "-c", "exit 3"}, wantCode: 3, wantOK: true},
+		{args: []string{"/non/existing"}, wantOK: false},
+	} {
+		err := exec.Command(test.args[0], test.args[1:]...).Run()
+		code, ok := exitCode(err)
+		if code != test.wantCode || ok != test.wantOK {
+			t.Errorf("exitCode(%v) = %v,%v, want %v,%v", err, code, ok, test.wantCode, test.wantOK)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/l"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	// Well-known name, object path and interface of freedesktop notification servers.
+	dbusNotificationsName  = "org.freedesktop.Notifications"
+	dbusNotificationsPath  = dbus.ObjectPath("/org/freedesktop/Notifications")
+	dbusNotificationsIface = "org.freedesktop.Notifications"
+
+	// Urgency levels, see the Desktop Notifications Specification.
+	dbusUrgencyNormal   = byte(1)
+	dbusUrgencyCritical = byte(2)
+
+	// Extra time to wait for a response after a notification should have expired, in case the
+	// notification server never signals that it's closed.
+	dbusGraceTime = time.Second * 5
+)
+
+// dbusActions are the buttons on a notification: pairs of action keys and labels.
+var dbusActions = []string{
+	"join", "Join",
+	"calendar", "Calendar",
+	"snooze", "Snooze",
+	"skip", "Skip",
+}
+
+// dbusJoinPrefix starts the action keys to join one of grouped meetings, as in "join:1".
+const dbusJoinPrefix = "join:"
+
+// dbusActionsFor is a helper to determine the buttons on a notification. Grouped meetings each get
+// their own join button instead of "Join". Meetings with notes get a button to open them, recurring
+// meetings get a button to mute the series. End alerts can't be snoozed.
+func dbusActionsFor(n Notification) []string {
+	if len(n.Items) < 2 {
+		out := []string{}
+		for i := 0; i < len(dbusActions); i += 2 {
+			if n.Ending && dbusActions[i] == "snooze" {
+				continue
+			}
+			out = append(out, dbusActions[i:i+2]...)
+		}
+		if len(n.Notes) > 0 {
+			out = append(out, "notes", "Notes")
+		}
+		if n.Series != "" {
+			out = append(out, "mute", "Mute series")
+		}
+		return out
+	}
+	out := []string{}
+	for i, it := range n.Items {
+		out = append(out, fmt.Sprintf("%s%d", dbusJoinPrefix, i), "Join "+truncate(30, it.Title))
+	}
+	return append(out, dbusActions[2:]...)
+}
+
+// dbusBody is a helper to describe a notification. Grouped meetings are listed.
+func dbusBody(n Notification) string {
+	if len(n.Items) < 2 {
+		switch {
+		case n.Ending && n.Next != nil:
+			return fmt.Sprintf("Ends at %s\nNext: %s %s", n.End.Format("15:04"), n.Next.Start.Format("15:04"), n.Next.Title)
+		case n.Ending:
+			return fmt.Sprintf("Ends at %s", n.End.Format("15:04"))
+		case n.Started > 0:
+			return fmt.Sprintf("Started at %s", n.Start.Format("15:04"))
+		}
+		return fmt.Sprintf("Starts at %s", n.Start.Format("15:04"))
+	}
+	lines := []string{}
+	for _, it := range n.Items {
+		lines = append(lines, fmt.Sprintf("%s %s", it.Start.Format("15:04"), it.Title))
+	}
+	if n.Conflict {
+		lines = append(lines, "These meetings overlap.")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dbusNotifier is a Backend that talks to a freedesktop notification server over the session bus.
+type dbusNotifier struct {
+	address string // bus address, "" for the default session bus
+}
+
+// newDbus creates a backend for Linux desktop notifications.
+func newDbus(opts *Opts) (Backend, error) {
+	return &dbusNotifier{}, nil
+}
+
+// Show implements Backend.
+func (d *dbusNotifier) Show(ctx context.Context, n Notification) (Action, error) {
+	conn, err := d.connect()
+	if err != nil {
+		return ActionNone, fmt.Errorf("cannot connect to the session bus: %v", err)
+	}
+	defer conn.Close()
+
+	// Subscribe to signals before the notification appears, so that no response is missed.
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(dbusNotificationsPath),
+		dbus.WithMatchInterface(dbusNotificationsIface),
+	); err != nil {
+		return ActionNone, fmt.Errorf("cannot subscribe to notification signals: %v", err)
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+
+	urgency, expireMs := dbusUrgency(n.VisibilitySec)
+	obj := conn.Object(dbusNotificationsName, dbusNotificationsPath)
+	var id uint32
+	hints := map[string]dbus.Variant{
+		"urgency": dbus.MakeVariant(urgency),
+	}
+	// The body may contain markup, the summary is plain text.
+	body := escapePango(dbusBody(n))
+	if err := obj.CallWithContext(ctx, dbusNotificationsIface+".Notify", 0,
+		"goto-meet", uint32(0), "", n.Title, body, dbusActionsFor(n), hints, expireMs,
+	).Store(&id); err != nil {
+		return ActionNone, fmt.Errorf("cannot send notification: %v", err)
+	}
+	l.Infof("notification %v sent over the session bus", id)
+
+	// Wait for the user to respond, for the notification to close, or for it to be outdated.
+	var timeout <-chan time.Time
+	if expireMs > 0 {
+		timeout = time.After(time.Duration(expireMs)*time.Millisecond + dbusGraceTime)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			obj.Call(dbusNotificationsIface+".CloseNotification", 0, id)
+			return ActionNone, ctx.Err()
+		case <-timeout:
+			obj.Call(dbusNotificationsIface+".CloseNotification", 0, id)
+			return ActionNone, nil
+		case sig, ok := <-signals:
+			if !ok {
+				return ActionNone, errors.New("connection to the session bus lost")
+			}
+			if action, done := dbusResponse(sig, id); done {
+				return action, nil
+			}
+		}
+	}
+}
+
+// connect is a helper to connect to the configured bus.
+func (d *dbusNotifier) connect() (*dbus.Conn, error) {
+	if d.address != "" {
+		return dbus.Connect(d.address)
+	}
+	return dbus.ConnectSessionBus()
+}
+
+// dbusUrgency is a helper to derive the urgency and expire timeout (in ms) of a notification from
+// the number of seconds that it should be visible. Notifications that should be visible
+// indefinitely are critical and don't expire.
+func dbusUrgency(visibilitySec int) (byte, int32) {
+	if visibilitySec <= 0 {
+		return dbusUrgencyCritical, 0
+	}
+	return dbusUrgencyNormal, int32(visibilitySec * 1000)
+}
+
+// dbusResponse is a helper to interpret a signal from the notification server. It returns the chosen
+// action and true when the signal concerns notification `id` and ends it.
+func dbusResponse(sig *dbus.Signal, id uint32) (Action, bool) {
+	if len(sig.Body) < 2 {
+		return ActionNone, false
+	}
+	if sigID, ok := sig.Body[0].(uint32); !ok || sigID != id {
+		return ActionNone, false
+	}
+	switch sig.Name {
+	case dbusNotificationsIface + ".ActionInvoked":
+		switch sig.Body[1] {
+		case "join":
+			return ActionJoin, true
+		case "calendar":
+			return ActionCalendar, true
+		case "snooze":
+			return ActionSnooze, true
+		case "skip":
+			return ActionSkip, true
+		case "notes":
+			return ActionNotes, true
+		case "mute":
+			return ActionMute, true
+		}
+		if key, ok := sig.Body[1].(string); ok && strings.HasPrefix(key, dbusJoinPrefix) {
+			if i, err := strconv.Atoi(strings.TrimPrefix(key, dbusJoinPrefix)); err == nil && i >= 0 {
+				return Action{Kind: KindJoin, Item: i}, true
+			}
+		}
+		// Typically "default", when the notification body was clicked.
+		return ActionNone, true
+	case dbusNotificationsIface + ".NotificationClosed":
+		return ActionNone, true
+	}
+	return ActionNone, false
+}
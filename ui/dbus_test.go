@@ -0,0 +1,316 @@
+package ui
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/item"
+
+	"github.com/godbus/dbus/v5"
+	"google.golang.org/api/calendar/v3"
+)
+
+// busConfig configures a private dbus-daemon that allows everything.
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%DIR%</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// privateBus starts a dbus-daemon for the duration of a test and returns its address.
+func privateBus(t *testing.T) string {
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not found, skipping")
+	}
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(conf, []byte(strings.Replace(busConfig, "%DIR%", dir, 1)), 0600); err != nil {
+		t.Fatalf("cannot write bus config: %v", err)
+	}
+	cmd := exec.Command("dbus-daemon", "--config-file="+conf, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("cannot pipe from dbus-daemon: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("cannot start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("cannot read dbus-daemon address: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// stubServer is a minimal org.freedesktop.Notifications implementation. It responds to each
+// notification by emitting a signal for `respond`: an action key, or "" to close it.
+type stubServer struct {
+	conn    *dbus.Conn
+	respond string
+	summary string
+	actions []string
+	hints   map[string]dbus.Variant
+	expire  int32
+	closed  []uint32
+	mu      sync.Mutex
+}
+
+func (s *stubServer) Notify(appName string, replacesID uint32, appIcon, summary, body string,
+	actions []string, hints map[string]dbus.Variant, expire int32) (uint32, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summary = summary
+	s.actions = actions
+	s.hints = hints
+	s.expire = expire
+
+	const id = uint32(42)
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		// Signals for other notifications must be ignored.
+		s.conn.Emit(dbusNotificationsPath, dbusNotificationsIface+".ActionInvoked", id+1, "join")
+		if s.respond == "" {
+			s.conn.Emit(dbusNotificationsPath, dbusNotificationsIface+".NotificationClosed", id, uint32(2))
+			return
+		}
+		s.conn.Emit(dbusNotificationsPath, dbusNotificationsIface+".ActionInvoked", id, s.respond)
+	}()
+	return id, nil
+}
+
+func (s *stubServer) CloseNotification(id uint32) *dbus.Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = append(s.closed, id)
+	return nil
+}
+
+func startStubServer(t *testing.T, address, respond string) *stubServer {
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("cannot connect stub server to %q: %v", address, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	s := &stubServer{
+		conn:    conn,
+		respond: respond,
+	}
+	if err := conn.Export(s, dbusNotificationsPath, dbusNotificationsIface); err != nil {
+		t.Fatalf("cannot export stub server: %v", err)
+	}
+	reply, err := conn.RequestName(dbusNotificationsName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("cannot own %q: %v, %v", dbusNotificationsName, reply, err)
+	}
+	return s
+}
+
+func TestDbusShow(t *testing.T) {
+	address := privateBus(t)
+	for _, test := range []struct {
+		respond    string
+		wantAction Action
+	}{
+		{respond: "join", wantAction: ActionJoin},
+		{respond: "calendar", wantAction: ActionCalendar},
+		{respond: "snooze", wantAction: ActionSnooze},
+		{respond: "skip", wantAction: ActionSkip},
+		{respond: "default", wantAction: ActionNone},
+		{respond: "", wantAction: ActionNone},
+	} {
+		srv := startStubServer(t, address, test.respond)
+		d := &dbusNotifier{address: address}
+		action, err := d.Show(context.Background(), Notification{
+			Title:         "standup",
+			VisibilitySec: 10,
+		})
+		srv.conn.ReleaseName(dbusNotificationsName)
+		if err != nil {
+			t.Fatalf("Show() with response %q = _,%v, require nil error", test.respond, err)
+		}
+		if action != test.wantAction {
+			t.Errorf("Show() with response %q = %v,_, want %v", test.respond, action, test.wantAction)
+		}
+
+		srv.mu.Lock()
+		if srv.summary != "standup" {
+			t.Errorf("Show() sent summary %q, want %q", srv.summary, "standup")
+		}
+		if strings.Join(srv.actions, ",") != "join,Join,calendar,Calendar,snooze,Snooze,skip,Skip" {
+			t.Errorf("Show() sent actions %v, want Join, Calendar, Snooze and Skip", srv.actions)
+		}
+		if srv.expire != 10000 {
+			t.Errorf("Show() sent expire timeout %v, want 10000", srv.expire)
+		}
+		if u, ok := srv.hints["urgency"]; !ok || u.Value() != dbusUrgencyNormal {
+			t.Errorf("Show() sent hints %v, want normal urgency", srv.hints)
+		}
+		srv.mu.Unlock()
+	}
+}
+
+func TestDbusShowTimeout(t *testing.T) {
+	address := privateBus(t)
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("cannot connect silent server to %q: %v", address, err)
+	}
+	defer conn.Close()
+	silent := &silentServer{}
+	if err := conn.Export(silent, dbusNotificationsPath, dbusNotificationsIface); err != nil {
+		t.Fatalf("cannot export silent server: %v", err)
+	}
+	if _, err := conn.RequestName(dbusNotificationsName, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatalf("cannot own %q: %v", dbusNotificationsName, err)
+	}
+
+	// Notifications that aren't answered are closed when the context expires.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	d := &dbusNotifier{address: address}
+	if action, err := d.Show(ctx, Notification{Title: "standup"}); err == nil || action != ActionNone {
+		t.Errorf("Show() = %v,%v for an unanswered notification, want none and error", action, err)
+	}
+	silent.mu.Lock()
+	defer silent.mu.Unlock()
+	if len(silent.closed) != 1 {
+		t.Errorf("Show() closed %v notifications after timing out, want 1", len(silent.closed))
+	}
+}
+
+// silentServer never responds to notifications.
+type silentServer struct {
+	closed []uint32
+	mu     sync.Mutex
+}
+
+func (s *silentServer) Notify(appName string, replacesID uint32, appIcon, summary, body string,
+	actions []string, hints map[string]dbus.Variant, expire int32) (uint32, *dbus.Error) {
+	return 7, nil
+}
+
+func (s *silentServer) CloseNotification(id uint32) *dbus.Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = append(s.closed, id)
+	return nil
+}
+
+func TestDbusUrgency(t *testing.T) {
+	for _, test := range []struct {
+		visibilitySec int
+		wantUrgency   byte
+		wantExpire    int32
+	}{
+		{visibilitySec: 0, wantUrgency: dbusUrgencyCritical, wantExpire: 0},
+		{visibilitySec: 120, wantUrgency: dbusUrgencyNormal, wantExpire: 120000},
+	} {
+		urgency, expire := dbusUrgency(test.visibilitySec)
+		if urgency != test.wantUrgency || expire != test.wantExpire {
+			t.Errorf("dbusUrgency(%v) = %v,%v, want %v,%v", test.visibilitySec, urgency, expire, test.wantUrgency, test.wantExpire)
+		}
+	}
+}
+
+func TestDbusBody(t *testing.T) {
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.Local)
+	n := newNotification(&item.Item{Title: "standup", Start: start}, 0)
+	if got, want := dbusBody(n), "Starts at 10:00"; got != want {
+		t.Errorf("dbusBody() = %q, want %q", got, want)
+	}
+	n.Started = time.Minute * 3
+	if got, want := dbusBody(n), "Started at 10:00"; got != want {
+		t.Errorf("dbusBody() of a meeting that started = %q, want %q", got, want)
+	}
+	n = newNotification(&item.Item{Title: "standup", Start: start, End: start.Add(time.Minute * 15)}, 0)
+	n.Ending = true
+	if got, want := dbusBody(n), "Ends at 10:15"; got != want {
+		t.Errorf("dbusBody() of a meeting that ends = %q, want %q", got, want)
+	}
+	if got, want := strings.Join(dbusActionsFor(n), ","), "join,Join,calendar,Calendar,skip,Skip"; got != want {
+		t.Errorf("dbusActionsFor() of a meeting that ends = %q, want %q", got, want)
+	}
+	n.Next = &item.Item{Title: "retro", Start: start.Add(time.Minute * 15)}
+	if got, want := dbusBody(n), "Ends at 10:15\nNext: 10:15 retro"; got != want {
+		t.Errorf("dbusBody() of a meeting that ends with one right after = %q, want %q", got, want)
+	}
+}
+
+func TestDbusGroup(t *testing.T) {
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.Local)
+	n := newGroupNotification([]*item.Item{
+		{Title: "retro", Start: start, End: start.Add(time.Hour)},
+		{Title: "standup <b>", Start: start, End: start.Add(time.Minute * 15)},
+	}, 0)
+	if got, want := strings.Join(dbusActionsFor(n), ","),
+		"join:0,Join retro,join:1,Join standup <b>,calendar,Calendar,snooze,Snooze,skip,Skip"; got != want {
+		t.Errorf("dbusActionsFor() = %q, want %q", got, want)
+	}
+	if got, want := dbusBody(n), "10:00 retro\n10:00 standup <b>\nThese meetings overlap."; got != want {
+		t.Errorf("dbusBody() = %q, want %q", got, want)
+	}
+	sig := &dbus.Signal{
+		Name: dbusNotificationsIface + ".ActionInvoked",
+		Body: []interface{}{uint32(7), "join:1"},
+	}
+	if action, done := dbusResponse(sig, 7); !done || action != (Action{Kind: KindJoin, Item: 1}) {
+		t.Errorf("dbusResponse(join:1) = %v,%v, want join of the second meeting", action, done)
+	}
+}
+
+func TestDbusMute(t *testing.T) {
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.Local)
+	standup := &item.Item{Title: "standup", Start: start, End: start.Add(time.Minute * 15),
+		Event: &calendar.Event{RecurringEventId: "abc"}}
+	if got, want := strings.Join(dbusActionsFor(newNotification(standup, 0)), ","),
+		"join,Join,calendar,Calendar,snooze,Snooze,skip,Skip,mute,Mute series"; got != want {
+		t.Errorf("dbusActionsFor() of a recurring meeting = %q, want %q", got, want)
+	}
+	// It would be unclear which series of grouped meetings to mute.
+	retro := &item.Item{Title: "retro", Start: start, End: start.Add(time.Hour),
+		Event: &calendar.Event{RecurringEventId: "def"}}
+	if got := strings.Join(dbusActionsFor(newGroupNotification([]*item.Item{standup, retro}, 0)), ","); strings.Contains(got, "mute") {
+		t.Errorf("dbusActionsFor() of grouped meetings = %q, want no mute", got)
+	}
+	sig := &dbus.Signal{
+		Name: dbusNotificationsIface + ".ActionInvoked",
+		Body: []interface{}{uint32(7), "mute"},
+	}
+	if action, done := dbusResponse(sig, 7); !done || action != ActionMute {
+		t.Errorf("dbusResponse(mute) = %v,%v, want mute", action, done)
+	}
+}
+
+func TestDbusNotes(t *testing.T) {
+	n := Notification{Title: "standup", Items: []*item.Item{{}}, Notes: []string{"https://docs/agenda"}}
+	if got, want := strings.Join(dbusActionsFor(n), ","),
+		"join,Join,calendar,Calendar,snooze,Snooze,skip,Skip,notes,Notes"; got != want {
+		t.Errorf("dbusActionsFor() of a meeting with notes = %q, want %q", got, want)
+	}
+	sig := &dbus.Signal{
+		Name: dbusNotificationsIface + ".ActionInvoked",
+		Body: []interface{}{uint32(7), "notes"},
+	}
+	if action, done := dbusResponse(sig, 7); !done || action != ActionNotes {
+		t.Errorf("dbusResponse(notes) = %v,%v, want notes", action, done)
+	}
+}
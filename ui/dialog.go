@@ -0,0 +1,188 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Exit codes of dialog programs that don't map to a button.
+const (
+	zenityTimeout = 5   // zenity --timeout expired
+	yadTimeout    = 70  // yad --timeout expired
+	yadEscape     = 252 // yad window closed or ESC pressed
+)
+
+// newZenity creates a backend that shows GTK dialogs using `zenity`. Join is the OK button, Skip is
+// the cancel button, Calendar and Snooze are extra buttons, as are Notes and Mute series for meetings that
+// have notes or that recur. End alerts can't be snoozed and lack Snooze. Dialog texts are markup, so
+// values are escaped as such; the same goes for yad.
+func newZenity(opts *Opts) (Backend, error) {
+	return &command{
+		isModal: true,
+		args: mustTemplates("zenity", EscapePango,
+			"zenity", "--question", "--title=goto-meet",
+			"--text={{.Title}}",
+			"--ok-label=Join", "--cancel-label=Skip", "--extra-button=Calendar", "{{if not .Ending}}--extra-button=Snooze{{end}}",
+			"{{if .Notes}}--extra-button=Notes{{end}}", "{{if .Series}}--extra-button=Mute series{{end}}",
+			"{{if .VisibilitySec}}--timeout={{.VisibilitySec}}{{end}}"),
+		parse: parseZenity,
+	}, nil
+}
+
+// parseZenity is a helper to map the outcome of `zenity --question` to an action. Extra buttons
+// exit with 1, just as the cancel button, but print their label.
+func parseZenity(out []byte, err error) (Action, error) {
+	code, ok := exitCode(err)
+	switch {
+	case !ok:
+		return ActionNone, fmt.Errorf("zenity failed, output: %v, error: %v", string(out), err)
+	case code == 0:
+		return ActionJoin, nil
+	case code == 1 && strings.TrimSpace(string(out)) == "Calendar":
+		return ActionCalendar, nil
+	case code == 1 && strings.TrimSpace(string(out)) == "Snooze":
+		return ActionSnooze, nil
+	case code == 1 && strings.TrimSpace(string(out)) == "Notes":
+		return ActionNotes, nil
+	case code == 1 && strings.TrimSpace(string(out)) == "Mute series":
+		return ActionMute, nil
+	case code == 1:
+		return ActionSkip, nil
+	case code == zenityTimeout:
+		return ActionNone, nil
+	}
+	return ActionNone, fmt.Errorf("unexpected zenity exit code %v, output: %v", code, string(out))
+}
+
+// kindMore is the kind of the More… button of kdialog, which the backend follows up with a menu.
+const kindMore Kind = -1
+
+// kdialog is a Backend that shows KDE dialogs using `kdialog`. The yes, no and cancel buttons of a
+// question are relabeled as Join, More… and Skip. As with osascript, More… offers a menu of the snooze
+// choices, the calendar, the notes of meetings that have them, and muting the series of recurring
+// meetings. kdialog has no timeout; it's killed when the notification should have disappeared. Unlike
+// zenity and yad, kdialog shows plain text, so values aren't escaped: they are passed as arguments
+// without a shell.
+type kdialog struct {
+	question *command
+	menu     []*template.Template // the menu of More…, without its entries
+}
+
+// newKdialog creates a backend that shows KDE dialogs using `kdialog`.
+func newKdialog(opts *Opts) (Backend, error) {
+	return &kdialog{
+		question: &command{
+			args: mustTemplates("kdialog", EscapeNone,
+				"kdialog", "--title", "goto-meet",
+				"--yesnocancel", "{{.Title}}",
+				"--yes-label", "Join", "--no-label", "More…", "--cancel-label", "Skip"),
+			parse: parseKdialog,
+		},
+		menu: mustTemplates("kdialog menu", EscapeNone,
+			"kdialog", "--title", "goto-meet", "--menu", "{{.Title}}"),
+	}, nil
+}
+
+// modal implements modalBackend.
+func (k *kdialog) modal() bool {
+	return true
+}
+
+// Show implements Backend.
+func (k *kdialog) Show(ctx context.Context, n Notification) (Action, error) {
+	action, err := k.question.Show(ctx, n)
+	if err != nil || action.Kind != kindMore {
+		return action, err
+	}
+	entries := []string{}
+	entry := func(label string) {
+		// Each entry is a tag, which kdialog prints when it's chosen, and a label.
+		entries = append(entries, label, label)
+	}
+	for _, s := range n.Snooze {
+		entry("Snooze " + s)
+	}
+	entry("Calendar")
+	if len(n.Notes) > 0 {
+		entry("Notes")
+	}
+	if n.Series != "" {
+		entry("Mute series")
+	}
+	menu := &command{args: k.menu, extra: entries, parse: parseKdialogMenu}
+	return menu.Show(ctx, n)
+}
+
+// parseKdialog is a helper to map the outcome of `kdialog --yesnocancel` to an action.
+func parseKdialog(out []byte, err error) (Action, error) {
+	code, ok := exitCode(err)
+	switch {
+	case !ok:
+		return ActionNone, fmt.Errorf("kdialog failed, output: %v, error: %v", string(out), err)
+	case code == 0:
+		return ActionJoin, nil
+	case code == 1:
+		return Action{Kind: kindMore}, nil
+	case code == 2:
+		return ActionSkip, nil
+	}
+	return ActionNone, fmt.Errorf("unexpected kdialog exit code %v, output: %v", code, string(out))
+}
+
+// parseKdialogMenu is a helper to map the outcome of `kdialog --menu`, which prints the chosen tag, to
+// an action.
+func parseKdialogMenu(out []byte, err error) (Action, error) {
+	code, ok := exitCode(err)
+	switch {
+	case !ok:
+		return ActionNone, fmt.Errorf("kdialog failed, output: %v, error: %v", string(out), err)
+	case code == 0:
+		return parseButton(out, nil)
+	case code == 1:
+		// The menu was cancelled.
+		return ActionNone, nil
+	}
+	return ActionNone, fmt.Errorf("unexpected kdialog exit code %v, output: %v", code, string(out))
+}
+
+// newYad creates a backend that shows GTK dialogs using `yad`. Each button has its own exit code. Meetings
+// with notes get a button to open them, recurring meetings get a button to mute the series. End alerts
+// lack the Snooze button.
+func newYad(opts *Opts) (Backend, error) {
+	return &command{
+		isModal: true,
+		args: mustTemplates("yad", EscapePango,
+			"yad", "--title=goto-meet", "--center", "--on-top",
+			"--text={{.Title}}",
+			"--button=Join:0", "--button=Calendar:2", "{{if not .Ending}}--button=Snooze:3{{end}}",
+			"{{if .Notes}}--button=Notes:5{{end}}", "{{if .Series}}--button=Mute series:4{{end}}", "--button=Skip:1",
+			"{{if .VisibilitySec}}--timeout={{.VisibilitySec}}{{end}}"),
+		parse: parseYad,
+	}, nil
+}
+
+// parseYad is a helper to map the exit code of `yad` to an action.
+func parseYad(out []byte, err error) (Action, error) {
+	code, ok := exitCode(err)
+	switch {
+	case !ok:
+		return ActionNone, fmt.Errorf("yad failed, output: %v, error: %v", string(out), err)
+	case code == 0:
+		return ActionJoin, nil
+	case code == 1:
+		return ActionSkip, nil
+	case code == 2:
+		return ActionCalendar, nil
+	case code == 3:
+		return ActionSnooze, nil
+	case code == 4:
+		return ActionMute, nil
+	case code == 5:
+		return ActionNotes, nil
+	case code == yadTimeout || code == yadEscape:
+		return ActionNone, nil
+	}
+	return ActionNone, fmt.Errorf("unexpected yad exit code %v, output: %v", code, string(out))
+}
@@ -0,0 +1,168 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDialog installs an executable `name` in front of the $PATH. It saves its arguments, one per
+// line, prints `output` and exits with `code`. The returned path is where the arguments are saved.
+func fakeDialog(t *testing.T, name, output string, code int) string {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := fmt.Sprintf("#!/bin/sh\nfor a in \"$@\"; do echo \"$a\"; done > %s\nprintf '%s'\nexit %d\n",
+		argsFile, output, code)
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatalf("cannot create fake %v: %v", name, err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return argsFile
+}
+
+func TestDialogs(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		output     string
+		code       int
+		title      string // "standup" when empty
+		series     string
+		notes      []string
+		ending     bool
+		wantAction Action
+		wantError  bool
+		wantArgs   []string // must occur in the arguments
+		skipArgs   []string // must not occur in the arguments
+	}{
+		// zenity
+		{name: "zenity", code: 0, wantAction: ActionJoin, wantArgs: []string{"--question", "--text=standup", "--timeout=30"}},
+		{name: "zenity", code: 1, output: "Calendar\n", wantAction: ActionCalendar},
+		{name: "zenity", code: 1, output: "Snooze\n", wantAction: ActionSnooze},
+		{name: "zenity", code: 1, wantAction: ActionSkip},
+		{name: "zenity", code: 1, output: "Notes\n", notes: []string{"https://docs/agenda"}, wantAction: ActionNotes, wantArgs: []string{"--extra-button=Notes"}},
+		{name: "zenity", code: 1, output: "Mute series\n", series: "abc", wantAction: ActionMute, wantArgs: []string{"--extra-button=Mute series"}},
+		{name: "zenity", code: 0, ending: true, wantAction: ActionJoin, skipArgs: []string{"--extra-button=Snooze"}},
+		{name: "zenity", code: 5, wantAction: ActionNone},
+		{name: "zenity", code: 99, wantError: true},
+
+		// kdialog
+		{name: "kdialog", code: 0, wantAction: ActionJoin, wantArgs: []string{"--yesnocancel", "standup", "More…"}},
+		{name: "kdialog", code: 0, title: "R&D <sync>", wantAction: ActionJoin, wantArgs: []string{"R&D <sync>"}},
+		{name: "kdialog", code: 1, wantAction: ActionNone}, // the menu of More… is cancelled
+		{name: "kdialog", code: 2, wantAction: ActionSkip},
+		{name: "kdialog", code: 99, wantError: true},
+
+		// yad
+		{name: "yad", code: 0, wantAction: ActionJoin, wantArgs: []string{"--text=standup", "--button=Calendar:2", "--timeout=30"}},
+		{name: "yad", code: 1, wantAction: ActionSkip},
+		{name: "yad", code: 2, wantAction: ActionCalendar},
+		{name: "yad", code: 3, wantAction: ActionSnooze},
+		{name: "yad", code: 5, notes: []string{"https://docs/agenda"}, wantAction: ActionNotes, wantArgs: []string{"--button=Notes:5"}},
+		{name: "yad", code: 4, series: "abc", wantAction: ActionMute, wantArgs: []string{"--button=Mute series:4"}},
+		{name: "yad", code: 0, ending: true, wantAction: ActionJoin, skipArgs: []string{"--button=Snooze:3"}},
+		{name: "yad", code: 70, wantAction: ActionNone},
+		{name: "yad", code: 252, wantAction: ActionNone},
+		{name: "yad", code: 99, wantError: true},
+	} {
+		argsFile := fakeDialog(t, test.name, test.output, test.code)
+		b, err := newBackend(test.name, &Opts{})
+		if err != nil {
+			t.Fatalf("newBackend(%q) = _,%v, require nil error", test.name, err)
+		}
+		title := test.title
+		if title == "" {
+			title = "standup"
+		}
+		action, err := b.Show(context.Background(), Notification{
+			Title:         title,
+			VisibilitySec: 30,
+			Series:        test.series,
+			Notes:         test.notes,
+			Ending:        test.ending,
+		})
+		if (err != nil) != test.wantError {
+			t.Errorf("%v exiting with %v: Show() = _,%v, want error: %v", test.name, test.code, err, test.wantError)
+		}
+		if action != test.wantAction {
+			t.Errorf("%v exiting with %v: Show() = %v,_, want %v", test.name, test.code, action, test.wantAction)
+		}
+		args, err := os.ReadFile(argsFile)
+		if err != nil {
+			t.Fatalf("cannot read back arguments of %v: %v", test.name, err)
+		}
+		for _, want := range test.wantArgs {
+			if !strings.Contains("\n"+string(args), "\n"+want+"\n") {
+				t.Errorf("%v was called with arguments %q, want %q among them", test.name, args, want)
+			}
+		}
+		for _, skip := range test.skipArgs {
+			if strings.Contains("\n"+string(args), "\n"+skip+"\n") {
+				t.Errorf("%v was called with arguments %q, want no %q among them", test.name, args, skip)
+			}
+		}
+	}
+}
+
+func TestKdialogMenu(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	// The question is answered with More…, the menu with the second snooze choice.
+	script := fmt.Sprintf(`#!/bin/sh
+case "$*" in
+*--menu*) for a in "$@"; do echo "$a"; done > %s; echo "Snooze 10m" ;;
+*) exit 1 ;;
+esac
+`, argsFile)
+	if err := os.WriteFile(filepath.Join(dir, "kdialog"), []byte(script), 0755); err != nil {
+		t.Fatalf("cannot create fake kdialog: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	b, err := newKdialog(&Opts{})
+	if err != nil {
+		t.Fatalf("newKdialog() = _,%v, require nil error", err)
+	}
+	action, err := b.Show(context.Background(), Notification{
+		Title:  "standup & <retro>",
+		Snooze: []string{"5m", "10m", "{{.Title}}"},
+		Notes:  []string{"https://docs/agenda"},
+		Series: "abc",
+	})
+	if err != nil || action != snoozeFor(time.Minute*10) {
+		t.Errorf("Show() = %v,%v, want %v", action, err, snoozeFor(time.Minute*10))
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("cannot read back arguments: %v", err)
+	}
+	// Titles are plain text, entries are passed as they are.
+	want := []string{"--title", "goto-meet", "--menu", "standup & <retro>",
+		"Snooze 5m", "Snooze 5m", "Snooze 10m", "Snooze 10m", "Snooze {{.Title}}", "Snooze {{.Title}}",
+		"Calendar", "Calendar", "Notes", "Notes", "Mute series", "Mute series"}
+	if got := strings.Split(strings.TrimSpace(string(args)), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("kdialog menu was called with %q, want %q", got, want)
+	}
+}
+
+func TestDialogWithoutTimeout(t *testing.T) {
+	argsFile := fakeDialog(t, "zenity", "", 0)
+	b, err := newZenity(&Opts{})
+	if err != nil {
+		t.Fatalf("newZenity() = _,%v, require nil error", err)
+	}
+	if _, err := b.Show(context.Background(), Notification{Title: "standup"}); err != nil {
+		t.Fatalf("Show() = _,%v, require nil error", err)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("cannot read back arguments: %v", err)
+	}
+	if strings.Contains(string(args), "--timeout") || strings.Contains(string(args), "\n\n") {
+		t.Errorf("zenity was called with arguments %q, want no (empty) timeout", args)
+	}
+}
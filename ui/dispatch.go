@@ -0,0 +1,160 @@
+package ui
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+
+	"github.com/KarelKubat/goto-meet/l"
+)
+
+// modalBackend is implemented by backends that may block the user's screen, such as dialogs. These are
+// shown through a dispatcher, so that they don't pile up.
+type modalBackend interface {
+	modal() bool
+}
+
+// dispatcher limits how many modal notifications are on screen at once. Waiting notifications are
+// shown in order of the start of their meetings; notifications that became stale while waiting are
+// dropped.
+type dispatcher struct {
+	limit   int // max # of notifications on screen
+	mu      sync.Mutex
+	running int
+	queue   queue
+	seq     int
+}
+
+// newDispatcher creates a dispatcher for a limit, which is at least 1.
+func newDispatcher(limit int) *dispatcher {
+	if limit < 1 {
+		limit = 1
+	}
+	return &dispatcher{limit: limit}
+}
+
+// wrap returns a backend that is shown through the dispatcher when it's modal, or the backend itself
+// otherwise.
+func (d *dispatcher) wrap(b Backend) Backend {
+	if m, ok := b.(modalBackend); ok && m.modal() {
+		return &dispatched{d: d, b: b}
+	}
+	return b
+}
+
+// dispatched is a Backend that waits for its turn at a dispatcher.
+type dispatched struct {
+	d *dispatcher
+	b Backend
+}
+
+// Show implements Backend. Dropped notifications result in ActionNone.
+func (q *dispatched) Show(ctx context.Context, n Notification) (Action, error) {
+	e := q.d.enqueue(n)
+	select {
+	case run := <-e.turn:
+		if !run {
+			return ActionNone, nil
+		}
+	case <-ctx.Done():
+		if !q.d.remove(e) {
+			// It was dispatched in the meantime.
+			if <-e.turn {
+				q.d.done()
+			}
+		}
+		return ActionNone, ctx.Err()
+	}
+	defer q.d.done()
+	return q.b.Show(ctx, n)
+}
+
+// entry is a notification that waits in a dispatcher.
+type entry struct {
+	n     Notification
+	seq   int       // order of arrival, for notifications of meetings that start at the same time
+	turn  chan bool // receives true when the notification may be shown, false when it's dropped
+	index int       // index in the queue, -1 when dispatched
+}
+
+// enqueue is a helper to add a notification to the queue.
+func (d *dispatcher) enqueue(n Notification) *entry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.seq++
+	e := &entry{n: n, seq: d.seq, turn: make(chan bool, 1)}
+	heap.Push(&d.queue, e)
+	if d.running >= d.limit {
+		l.Infof("notification for %v queued, %d waiting, %d on screen", n.Item, len(d.queue), d.running)
+	}
+	d.dispatch()
+	return e
+}
+
+// remove is a helper to take a notification out of the queue. It returns false when it was already
+// dispatched.
+func (d *dispatcher) remove(e *entry) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e.index < 0 {
+		return false
+	}
+	heap.Remove(&d.queue, e.index)
+	return true
+}
+
+// done is a helper to signal that a notification left the screen.
+func (d *dispatcher) done() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.running--
+	d.dispatch()
+}
+
+// dispatch is a helper to give waiting notifications their turn, as far as the limit allows. The
+// caller must hold the lock.
+func (d *dispatcher) dispatch() {
+	for d.running < d.limit && len(d.queue) > 0 {
+		e := heap.Pop(&d.queue).(*entry)
+		if e.n.stale != nil && e.n.stale() {
+			l.Infof("dropping the notification for %v, it became stale while queued; %d waiting", e.n.Item, len(d.queue))
+			e.turn <- false
+			continue
+		}
+		d.running++
+		e.turn <- true
+	}
+}
+
+// queue is a priority queue of entries, ordered by start and arrival. It implements heap.Interface.
+type queue []*entry
+
+func (q queue) Len() int { return len(q) }
+
+func (q queue) Less(i, j int) bool {
+	if !q[i].n.Start.Equal(q[j].n.Start) {
+		return q[i].n.Start.Before(q[j].n.Start)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *queue) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *queue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*q = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,190 @@
+package ui
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/item"
+)
+
+// dialogBackend is a modal backend that stays on screen until released, and records the titles that
+// it showed.
+type dialogBackend struct {
+	mu      sync.Mutex
+	shown   []string
+	onShow  chan string
+	release chan struct{}
+}
+
+func newDialogBackend() *dialogBackend {
+	return &dialogBackend{onShow: make(chan string, 10), release: make(chan struct{})}
+}
+
+func (d *dialogBackend) modal() bool {
+	return true
+}
+
+func (d *dialogBackend) Show(ctx context.Context, n Notification) (Action, error) {
+	d.mu.Lock()
+	d.shown = append(d.shown, n.Title)
+	d.mu.Unlock()
+	d.onShow <- n.Title
+	<-d.release
+	return ActionSkip, nil
+}
+
+// notificationAt is a helper to create a notification for a meeting at a start time.
+func notificationAt(title string, start time.Time) Notification {
+	return newNotification(&item.Item{Title: title, Start: start, End: start.Add(time.Hour)}, 10)
+}
+
+// waitQueued is a helper to wait until a number of notifications are queued.
+func waitQueued(t *testing.T, d *dispatcher, want int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		d.mu.Lock()
+		got := len(d.queue)
+		d.mu.Unlock()
+		if got == want {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatalf("queue never reached %d entries", want)
+}
+
+func TestWrap(t *testing.T) {
+	d := newDispatcher(0)
+	if d.limit != 1 {
+		t.Errorf("newDispatcher(0).limit = %d, want 1", d.limit)
+	}
+	if _, ok := d.wrap(newDialogBackend()).(*dispatched); !ok {
+		t.Error("wrap(modal backend) isn't dispatched")
+	}
+	fb := &fakeBackend{}
+	if b := d.wrap(fb); b != fb {
+		t.Errorf("wrap(non-modal backend) = %v, want the backend itself", b)
+	}
+	if _, ok := d.wrap(&command{}).(*dispatched); ok {
+		t.Error("wrap(non-modal command) is dispatched")
+	}
+	if _, ok := d.wrap(&command{isModal: true}).(*dispatched); !ok {
+		t.Error("wrap(modal command) isn't dispatched")
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	db := newDialogBackend()
+	b := newDispatcher(1).wrap(db)
+	d := b.(*dispatched).d
+
+	var wg sync.WaitGroup
+	show := func(n Notification) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Show(context.Background(), n)
+		}()
+	}
+
+	// The first notification is shown right away, the others wait.
+	show(notificationAt("first", start.Add(time.Minute*30)))
+	if got := <-db.onShow; got != "first" {
+		t.Fatalf("first shown notification = %q, want first", got)
+	}
+	show(notificationAt("late", start.Add(time.Minute*20)))
+	waitQueued(t, d, 1)
+	stale := notificationAt("stale", start)
+	stale.stale = func() bool { return true }
+	show(stale)
+	waitQueued(t, d, 2)
+	show(notificationAt("early", start.Add(time.Minute*10)))
+	waitQueued(t, d, 3)
+
+	// Each release lets the next notification in order of start through, skipping the stale one.
+	for _, want := range []string{"early", "late"} {
+		db.release <- struct{}{}
+		if got := <-db.onShow; got != want {
+			t.Errorf("next shown notification = %q, want %q", got, want)
+		}
+	}
+	db.release <- struct{}{}
+	wg.Wait()
+
+	if len(db.shown) != 3 {
+		t.Errorf("shown notifications = %v, want first, early and late", db.shown)
+	}
+	if d.running != 0 || len(d.queue) != 0 {
+		t.Errorf("dispatcher has %d running and %d queued, want none", d.running, len(d.queue))
+	}
+}
+
+func TestDispatcherLimit(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	db := newDialogBackend()
+	b := newDispatcher(2).wrap(db)
+	d := b.(*dispatched).d
+
+	var wg sync.WaitGroup
+	for _, title := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(title string) {
+			defer wg.Done()
+			b.Show(context.Background(), notificationAt(title, start))
+		}(title)
+	}
+	<-db.onShow
+	<-db.onShow
+	waitQueued(t, d, 1)
+	for i := 0; i < 3; i++ {
+		db.release <- struct{}{}
+	}
+	wg.Wait()
+	if len(db.shown) != 3 {
+		t.Errorf("shown notifications = %v, want 3", db.shown)
+	}
+}
+
+func TestDispatcherCancel(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	db := newDialogBackend()
+	b := newDispatcher(1).wrap(db)
+	d := b.(*dispatched).d
+
+	go b.Show(context.Background(), notificationAt("first", start))
+	<-db.onShow
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() {
+		_, err := b.Show(ctx, notificationAt("canceled", start))
+		errc <- err
+	}()
+	waitQueued(t, d, 1)
+	cancel()
+	if err := <-errc; err == nil {
+		t.Error("Show() with a canceled context didn't fail")
+	}
+	waitQueued(t, d, 0)
+
+	db.release <- struct{}{}
+	for i := 0; i < 100; i++ {
+		d.mu.Lock()
+		running := d.running
+		d.mu.Unlock()
+		if running == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(db.shown) != 1 || d.running != 0 {
+		t.Errorf("shown notifications = %v with %d running, want only the first and none", db.shown, d.running)
+	}
+}
@@ -0,0 +1,313 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/l"
+)
+
+// Default templates of email reminders.
+const (
+	emailSubjectTpl = `{{.Title}}{{if not (or .Started .Ending .AutoJoin)}} starts at {{.Start | timefmt "15:04"}}{{end}}`
+	emailBodyTpl    = `{{.Title}}
+
+Starts at: {{.Start | timefmt "Mon Jan 2 15:04 MST"}}
+Join:      {{.JoinLink}}
+Calendar:  {{.CalendarLink}}
+{{- range .Notes}}
+Notes:     {{.}}
+{{- end}}
+`
+)
+
+// emailData is what the templates of email reminders can use: the notification and the recipient.
+type emailData struct {
+	Notification
+	Recipient string
+}
+
+// emailTemplates are the parsed subject and body templates for a recipient.
+type emailTemplates struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// email is a Backend that sends reminders through an SMTP server. It can't report a chosen action.
+type email struct {
+	cfg       *config.Email
+	templates []*emailTemplates // per recipient, in the order of the configuration
+	tlsConfig *tls.Config
+}
+
+// newEmail creates a backend for email reminders from the configuration file.
+func newEmail(e *config.Email) (Backend, error) {
+	host, _, err := net.SplitHostPort(e.Server)
+	if err != nil {
+		return nil, fmt.Errorf("email %q: %v", e.Name, err)
+	}
+	out := &email{
+		cfg:       e,
+		tlsConfig: &tls.Config{ServerName: host},
+	}
+	for _, r := range e.Recipients {
+		et := &emailTemplates{}
+		if et.subject, err = newTemplate(e.Name+" subject", EscapeNone,
+			firstOf(r.Subject, e.Subject, emailSubjectTpl)); err != nil {
+			return nil, fmt.Errorf("email %q: cannot parse subject for %v: %v", e.Name, r.Address, err)
+		}
+		if et.body, err = newTemplate(e.Name+" body", EscapeNone,
+			firstOf(r.Body, e.Body, emailBodyTpl)); err != nil {
+			return nil, fmt.Errorf("email %q: cannot parse body for %v: %v", e.Name, r.Address, err)
+		}
+		out.templates = append(out.templates, et)
+	}
+	return out, nil
+}
+
+// firstOf is a helper to return the first non-empty string.
+func firstOf(strs ...string) string {
+	for _, s := range strs {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// detached implements detachedBackend.
+func (e *email) detached() bool {
+	return true
+}
+
+// Show implements Backend. A reminder is sent to each recipient; errors are reported when any
+// can't be sent.
+func (e *email) Show(ctx context.Context, n Notification) (Action, error) {
+	errs := []string{}
+	for i, r := range e.cfg.Recipients {
+		msg, err := e.message(n, i, time.Now())
+		if err == nil {
+			err = e.send(ctx, r.Address, msg)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", r.Address, err))
+			continue
+		}
+		l.Infof("email %q: reminder for %v sent to %v", e.cfg.Name, n.Item, r.Address)
+	}
+	if len(errs) > 0 {
+		return ActionNone, fmt.Errorf("email %q: %v", e.cfg.Name, strings.Join(errs, "; "))
+	}
+	return ActionNone, nil
+}
+
+// message is a helper to compose the reminder for the i-th recipient: a text part and an .ics
+// attachment.
+func (e *email) message(n Notification, i int, now time.Time) ([]byte, error) {
+	to, et := e.cfg.Recipients[i].Address, e.templates[i]
+	data := emailData{
+		Notification: n,
+		Recipient:    to,
+	}
+	subject := new(bytes.Buffer)
+	if err := et.subject.Execute(subject, data); err != nil {
+		return nil, fmt.Errorf("cannot execute subject template: %v", err)
+	}
+	body := new(bytes.Buffer)
+	if err := et.body.Execute(body, data); err != nil {
+		return nil, fmt.Errorf("cannot execute body template: %v", err)
+	}
+
+	msg := new(bytes.Buffer)
+	mw := multipart.NewWriter(msg)
+	for _, h := range [][2]string{
+		{"From", e.cfg.From},
+		{"To", to},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject.String())},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf("multipart/mixed; boundary=%q", mw.Boundary())},
+	} {
+		fmt.Fprintf(msg, "%s: %s\r\n", h[0], h[1])
+	}
+	msg.WriteString("\r\n")
+
+	text, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	text.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(body.String(), "\r\n", "\n"), "\n", "\r\n")))
+
+	ics, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {`text/calendar; charset=utf-8; method=PUBLISH; name="meeting.ics"`},
+		"Content-Disposition":       {`attachment; filename="meeting.ics"`},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ics.Write([]byte(icsEvent(n, now)))
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// send is a helper to deliver a message to one recipient.
+func (e *email) send(ctx context.Context, to string, msg []byte) error {
+	deadline := time.Now().Add(time.Duration(e.cfg.Timeout))
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", e.cfg.Server)
+	if err != nil {
+		return fmt.Errorf("cannot connect to %v: %v", e.cfg.Server, err)
+	}
+	conn.SetDeadline(deadline)
+	c, err := smtp.NewClient(conn, e.tlsConfig.ServerName)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("cannot talk to %v: %v", e.cfg.Server, err)
+	}
+	defer c.Close()
+
+	if err := c.Hello("localhost"); err != nil {
+		return err
+	}
+	if *e.cfg.StartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%v doesn't support STARTTLS", e.cfg.Server)
+		}
+		if err := c.StartTLS(e.tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS failed: %v", err)
+		}
+	}
+	if e.cfg.Username != "" {
+		auth := smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.tlsConfig.ServerName)
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("cannot authenticate: %v", err)
+		}
+	}
+	from, err := mail.ParseAddress(e.cfg.From)
+	if err != nil {
+		return err
+	}
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return err
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(rcpt.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// icsEvent is a helper to render a notification as an iCalendar object with one event, or with one
+// event per meeting of a grouped notification.
+func icsEvent(n Notification, now time.Time) string {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//goto-meet//EN",
+		"METHOD:PUBLISH",
+	}
+	if len(n.Items) == 0 {
+		lines = append(lines, icsVEvent(n.Item, fmt.Sprintf("%d@goto-meet", n.Start.Unix()), n.Start, n.Title, n.JoinLink, now)...)
+	}
+	for _, it := range n.Items {
+		lines = append(lines, icsVEvent(it, "", it.Start, it.Title, it.JoinLink, now)...)
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	out := new(strings.Builder)
+	for _, line := range lines {
+		out.WriteString(icsFold(line))
+	}
+	return out.String()
+}
+
+// icsVEvent is a helper to render one meeting as the lines of an iCalendar event. The UID is taken
+// from the item when there is one.
+func icsVEvent(it *item.Item, uid string, start time.Time, title, joinLink string, now time.Time) []string {
+	const stamp = "20060102T150405Z"
+	if it != nil {
+		uid = it.EventID + "@goto-meet"
+		if it.Event != nil && it.Event.ICalUID != "" {
+			uid = it.Event.ICalUID
+		}
+	}
+	lines := []string{
+		"BEGIN:VEVENT",
+		"UID:" + icsText(uid),
+		"DTSTAMP:" + now.UTC().Format(stamp),
+		"DTSTART:" + start.UTC().Format(stamp),
+	}
+	if it != nil && !it.End.IsZero() {
+		lines = append(lines, "DTEND:"+it.End.UTC().Format(stamp))
+	}
+	lines = append(lines, "SUMMARY:"+icsText(title))
+	if joinLink != "" {
+		lines = append(lines, "URL:"+icsText(joinLink), "DESCRIPTION:"+icsText("Join: "+joinLink))
+	}
+	return append(lines, "END:VEVENT")
+}
+
+// icsText is a helper to escape a value of an iCalendar text property.
+func icsText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// icsFold is a helper to terminate an iCalendar line, folding it into lines of at most 75 octets.
+// UTF-8 sequences aren't split.
+func icsFold(line string) string {
+	out := new(strings.Builder)
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		out.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	out.WriteString(line + "\r\n")
+	return out.String()
+}
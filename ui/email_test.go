@@ -0,0 +1,393 @@
+package ui
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// smtpServer is a minimal SMTP server that records what it receives. It offers STARTTLS when it has
+// a TLS config, and accepts AUTH PLAIN for user "user" with password "secret".
+type smtpServer struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+	mu        sync.Mutex
+	mails     []*smtpMail
+	authed    []string
+}
+
+// smtpMail is a message that smtpServer received.
+type smtpMail struct {
+	from, to string
+	tls      bool
+	data     string
+}
+
+func newSMTPServer(t *testing.T, withTLS bool) (*smtpServer, *x509.CertPool) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	s := &smtpServer{ln: ln}
+	var pool *x509.CertPool
+	if withTLS {
+		cert, p := selfSigned(t)
+		s.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		pool = p
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s, pool
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	say := func(line string) { io.WriteString(conn, line+"\r\n") }
+	isTLS := false
+	m := &smtpMail{}
+	say("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO":
+			say("250-fake")
+			if s.tlsConfig != nil && !isTLS {
+				say("250-STARTTLS")
+			}
+			say("250 AUTH PLAIN")
+		case "STARTTLS":
+			say("220 go ahead")
+			tc := tls.Server(conn, s.tlsConfig)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			conn, r, isTLS = tc, bufio.NewReader(tc), true
+		case "AUTH":
+			parts := strings.Fields(line)
+			b, _ := base64.StdEncoding.DecodeString(parts[len(parts)-1])
+			creds := strings.Split(string(b), "\x00")
+			if len(creds) != 3 || creds[1] != "user" || creds[2] != "secret" {
+				say("535 bad credentials")
+				continue
+			}
+			s.mu.Lock()
+			s.authed = append(s.authed, creds[1])
+			s.mu.Unlock()
+			say("235 ok")
+		case "MAIL":
+			m.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			say("250 ok")
+		case "RCPT":
+			m.to = strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+			say("250 ok")
+		case "DATA":
+			say("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			m.data, m.tls = data.String(), isTLS
+			s.mu.Lock()
+			s.mails = append(s.mails, m)
+			s.mu.Unlock()
+			m = &smtpMail{}
+			say("250 queued")
+		case "QUIT":
+			say("221 bye")
+			return
+		default:
+			say("250 ok")
+		}
+	}
+}
+
+// selfSigned is a helper to create a certificate for 127.0.0.1 and a pool that trusts it.
+func selfSigned(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func newTestEmail(t *testing.T, e *config.Email, pool *x509.CertPool) *email {
+	if e.StartTLS == nil {
+		b := pool != nil
+		e.StartTLS = &b
+	}
+	if e.Timeout == 0 {
+		e.Timeout = config.DefaultEmailTimeout
+	}
+	b, err := newEmail(e)
+	if err != nil {
+		t.Fatalf("newEmail(%+v) = _,%v, require nil error", e, err)
+	}
+	em := b.(*email)
+	em.tlsConfig.RootCAs = pool
+	return em
+}
+
+func testEmailItem() *item.Item {
+	return &item.Item{
+		CalendarID:   "primary",
+		EventID:      "ev1",
+		Title:        "Weekly sync, part 1; café",
+		JoinLink:     "https://meet/abc",
+		CalendarLink: "https://calendar/abc",
+		Notes:        []string{"https://docs/agenda"},
+		Start:        time.Date(2021, 11, 1, 10, 30, 0, 0, time.UTC),
+		End:          time.Date(2021, 11, 1, 11, 0, 0, 0, time.UTC),
+		Event:        &calendar.Event{ICalUID: "uid-1@google.com"},
+	}
+}
+
+func TestEmailShow(t *testing.T) {
+	srv, pool := newSMTPServer(t, true)
+	b := newTestEmail(t, &config.Email{
+		Name:     "mail",
+		Server:   srv.ln.Addr().String(),
+		Username: "user",
+		Password: "secret",
+		From:     "goto-meet <me@example.com>",
+		Recipients: []*config.Recipient{
+			{Address: "a@example.com"},
+			{Address: "B <b@example.com>", Subject: "For {{.Recipient}}: {{.Title}}", Body: "Hi {{.Recipient}}"},
+			{Address: "a@example.com", Subject: "Again: {{.Title}}", Body: "Once more"},
+		},
+	}, pool)
+	if _, err := b.Show(context.Background(), newNotification(testEmailItem(), 0)); err != nil {
+		t.Fatalf("Show() = _,%v, want nil error", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.mails) != 3 || len(srv.authed) != 3 {
+		t.Fatalf("Show(): server got %v mails and %v authentications, want 3 of each", len(srv.mails), len(srv.authed))
+	}
+	for i, test := range []struct {
+		wantTo      string
+		wantSubject string
+		wantBody    []string
+	}{
+		{
+			wantTo:      "a@example.com",
+			wantSubject: "Weekly sync, part 1; café starts at 10:30",
+			wantBody:    []string{"Join:      https://meet/abc", "Notes:     https://docs/agenda"},
+		},
+		{
+			wantTo:      "b@example.com",
+			wantSubject: "For B <b@example.com>: Weekly sync, part 1; café",
+			wantBody:    []string{"Hi B <b@example.com>"},
+		},
+		{
+			wantTo:      "a@example.com",
+			wantSubject: "Again: Weekly sync, part 1; café",
+			wantBody:    []string{"Once more"},
+		},
+	} {
+		m := srv.mails[i]
+		if m.from != "me@example.com" || m.to != test.wantTo || !m.tls {
+			t.Errorf("Show(): mail %v from %q to %q (tls: %v), want from me@example.com to %q over TLS", i, m.from, m.to, m.tls, test.wantTo)
+		}
+		msg, err := mail.ReadMessage(strings.NewReader(m.data))
+		if err != nil {
+			t.Fatalf("Show(): mail %v can't be parsed: %v", i, err)
+		}
+		subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		if err != nil || subject != test.wantSubject {
+			t.Errorf("Show(): mail %v subject = %q,%v, want %q", i, subject, err, test.wantSubject)
+		}
+		_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatalf("Show(): mail %v has bad content type: %v", i, err)
+		}
+		mr := multipart.NewReader(msg.Body, params["boundary"])
+		text, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("Show(): mail %v has no text part: %v", i, err)
+		}
+		b, _ := io.ReadAll(text)
+		for _, want := range test.wantBody {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("Show(): mail %v body = %q, want it to contain %q", i, string(b), want)
+			}
+		}
+		ics, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("Show(): mail %v has no attachment: %v", i, err)
+		}
+		if ics.FileName() != "meeting.ics" {
+			t.Errorf("Show(): mail %v attachment is %q, want meeting.ics", i, ics.FileName())
+		}
+		b, _ = io.ReadAll(ics)
+		for _, want := range []string{"UID:uid-1@google.com\r\n", "DTSTART:20211101T103000Z\r\n", "DTEND:20211101T110000Z\r\n", `SUMMARY:Weekly sync\, part 1\; café`} {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("Show(): mail %v attachment = %q, want it to contain %q", i, string(b), want)
+			}
+		}
+	}
+}
+
+func TestEmailGroup(t *testing.T) {
+	srv, pool := newSMTPServer(t, false)
+	b := newTestEmail(t, &config.Email{
+		Name:       "mail",
+		Server:     srv.ln.Addr().String(),
+		From:       "me@example.com",
+		Recipients: []*config.Recipient{{Address: "a@example.com"}},
+	}, pool)
+	first := testEmailItem()
+	second := &item.Item{
+		EventID:  "ev2",
+		Title:    "retro",
+		JoinLink: "https://meet/def",
+		Start:    first.Start,
+		End:      time.Date(2021, 11, 1, 11, 30, 0, 0, time.UTC),
+	}
+	if _, err := b.Show(context.Background(), newGroupNotification([]*item.Item{first, second}, 0)); err != nil {
+		t.Fatalf("Show() = _,%v, want nil error", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.mails) != 1 {
+		t.Fatalf("Show(): server got %v mails, want 1", len(srv.mails))
+	}
+	data := srv.mails[0].data
+	if got := strings.Count(data, "BEGIN:VEVENT\r\n"); got != 2 {
+		t.Errorf("Show(): mail has %v events, want one per meeting: %q", got, data)
+	}
+	for _, want := range []string{
+		"UID:uid-1@google.com\r\n", "DTEND:20211101T110000Z\r\n", `SUMMARY:Weekly sync\, part 1\; café`,
+		"UID:ev2@goto-meet\r\n", "DTEND:20211101T113000Z\r\n", "SUMMARY:retro\r\n", "URL:https://meet/def\r\n",
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("Show(): mail = %q, want it to contain %q", data, want)
+		}
+	}
+}
+
+func TestEmailErrors(t *testing.T) {
+	plain, _ := newSMTPServer(t, false)
+	secure, pool := newSMTPServer(t, true)
+	for _, test := range []struct {
+		name      string
+		cfg       *config.Email
+		pool      *x509.CertPool
+		wantError string
+	}{
+		{
+			name: "no starttls offered",
+			cfg: &config.Email{
+				Server:   plain.ln.Addr().String(),
+				StartTLS: func() *bool { b := true; return &b }(),
+			},
+			wantError: "doesn't support STARTTLS",
+		},
+		{
+			name:      "untrusted certificate",
+			cfg:       &config.Email{Server: secure.ln.Addr().String()},
+			pool:      x509.NewCertPool(),
+			wantError: "STARTTLS failed",
+		},
+		{
+			name:      "bad password",
+			cfg:       &config.Email{Server: secure.ln.Addr().String(), Username: "user", Password: "guess"},
+			pool:      pool,
+			wantError: "cannot authenticate",
+		},
+	} {
+		test.cfg.Name = test.name
+		test.cfg.From = "me@example.com"
+		test.cfg.Recipients = []*config.Recipient{{Address: "a@example.com"}}
+		b := newTestEmail(t, test.cfg, test.pool)
+		_, err := b.Show(context.Background(), newNotification(testEmailItem(), 0))
+		if err == nil || !strings.Contains(err.Error(), test.wantError) {
+			t.Errorf("%v: Show() = _,%v, want error with %q", test.name, err, test.wantError)
+		}
+	}
+}
+
+func TestICSFold(t *testing.T) {
+	for _, test := range []struct {
+		line string
+	}{
+		{line: "SUMMARY:short"},
+		{line: "SUMMARY:" + strings.Repeat("x", 200)},
+		{line: "SUMMARY:" + strings.Repeat("é", 100)},
+	} {
+		got := icsFold(test.line)
+		if !strings.HasSuffix(got, "\r\n") {
+			t.Errorf("icsFold(%q) = %q, want CRLF at the end", test.line, got)
+		}
+		for _, l := range strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n") {
+			if len(l) > 75 {
+				t.Errorf("icsFold(%q): line %q is longer than 75 octets", test.line, l)
+			}
+		}
+		if unfolded := strings.ReplaceAll(strings.TrimSuffix(got, "\r\n"), "\r\n ", ""); unfolded != test.line {
+			t.Errorf("icsFold(%q) unfolds to %q", test.line, unfolded)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/l"
+)
+
+// backToBack is how far apart the end of a meeting and the start of the next one may be for the next
+// one to start right after.
+const backToBack = time.Minute
+
+// endKey is a helper to derive the key in the scheduler of the alert before the end of an event.
+func endKey(it *item.Item) string {
+	return "ending::" + it.Key()
+}
+
+// scheduleEnd is a helper to arrange for an alert at Opts.EndsIn before a meeting ends. Meetings
+// without a join link, without an end, or that last all day, aren't alerted.
+func (n *Notifier) scheduleEnd(it *item.Item) {
+	if n.opts.EndsIn <= 0 || it.JoinLink == "" || it.AllDay || !it.End.After(it.Start) {
+		return
+	}
+	key := endKey(it)
+	at := it.End.Add(-n.opts.EndsIn)
+	if due, ok := n.sched.Due(key); ok && due.Equal(at) {
+		return
+	}
+	if !n.sched.Now().Before(it.End) {
+		return
+	}
+	l.Infof("end alert at %v for event %v", at, it)
+	n.sched.At(key, at, func(now time.Time) {
+		n.ending(it, now)
+	})
+}
+
+// ending is a helper to alert that a meeting ends soon, and whether another one starts right after.
+func (n *Notifier) ending(it *item.Item, now time.Time) {
+	switch {
+	case !now.Before(it.End):
+		l.Infof("skipping end alert for %v, it has ended", it)
+		return
+	case n.processed.Superseded(it):
+		l.Infof("skipping end alert for %v, it was modified in the meantime", it)
+		return
+	case n.muted(it):
+		return
+	case n.quiet(it, now):
+		return
+	case !n.processed.FireEnd(it):
+		l.Infof("skipping end alert for %v, it was already shown", it)
+		return
+	}
+	n.mu.Lock()
+	next := nextAfter(n.agenda, it)
+	n.mu.Unlock()
+
+	notification := newNotification(it, n.opts.VisibilitySec)
+	notification.Ending = true
+	notification.Series = ""
+	notification.Snooze = nil
+	notification.Title = endingTitle(it.Title, it.End.Sub(now), next)
+	if next != nil {
+		notification.Next = next
+		notification.JoinLink = next.JoinLink
+	}
+	notification.stale = func() bool {
+		return n.stale([]*item.Item{it})
+	}
+	if n.named.sound.playsFor(config.AlertEnd) {
+		n.named.sound.play(notification)
+	}
+	action := showAll(context.Background(), n.named.backends, notification)
+	l.Infof("end alert for %v: user chose %v", it, action)
+	var err error
+	switch action.Kind {
+	case KindJoin:
+		// Joining goes to the next meeting, or back to this one when there is none.
+		if next != nil {
+			err = join(n.opts, next)
+		} else {
+			err = join(n.opts, it)
+		}
+	case KindCalendar:
+		err = openItem(n.opts, it, config.LinkCalendar)
+	case KindNotes:
+		err = openNotes(n.opts, it)
+	case KindSnooze:
+		l.Infof("end alerts can't be snoozed, ignoring %v", action)
+	}
+	if err != nil {
+		l.Warnf("cannot perform %v for %v: %v", action, it, err)
+	}
+}
+
+// nextAfter is a helper to find the meeting in an agenda that starts right after an item ends, or nil
+// when there is none.
+func nextAfter(agenda []*item.Item, it *item.Item) *item.Item {
+	var out *item.Item
+	for _, a := range agenda {
+		if a.AllDay || !a.Start.After(it.Start) || endKey(a) == endKey(it) {
+			continue
+		}
+		if a.Start.Before(it.End.Add(-backToBack)) || a.Start.After(it.End.Add(backToBack)) {
+			continue
+		}
+		if out == nil || a.Start.Before(out.Start) {
+			out = a
+		}
+	}
+	return out
+}
+
+// endingTitle is a helper to tell in a title that a meeting ends in a while, and which meeting starts
+// right after it.
+func endingTitle(title string, in time.Duration, next *item.Item) string {
+	out := fmt.Sprintf("%s ends now", title)
+	if m := int(in.Round(time.Minute).Minutes()); m == 1 {
+		out = fmt.Sprintf("%s ends in 1 minute", title)
+	} else if m > 1 {
+		out = fmt.Sprintf("%s ends in %d minutes", title, m)
+	}
+	if next != nil {
+		out += fmt.Sprintf(" — %s starts right after", next.Title)
+	}
+	return out
+}
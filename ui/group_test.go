@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/cache"
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/schedule"
+)
+
+func TestNewGroupNotification(t *testing.T) {
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	standup := &item.Item{Title: "standup", Start: start, End: start.Add(time.Minute * 15)}
+	retro := &item.Item{Title: "retro", Start: start, End: start.Add(time.Hour)}
+	planning := &item.Item{Title: "planning", Start: start.Add(time.Minute * 15), End: start.Add(time.Minute * 30)}
+	overlapping := &item.Item{Title: "overlapping", Start: start.Add(time.Minute * 10), End: start.Add(time.Minute * 20)}
+
+	for _, test := range []struct {
+		items        []*item.Item
+		wantTitle    string
+		wantConflict bool
+		wantFirst    *item.Item
+	}{
+		{
+			items:     []*item.Item{standup},
+			wantTitle: "standup",
+			wantFirst: standup,
+		},
+		{
+			items:        []*item.Item{standup, retro},
+			wantTitle:    "Conflict: standup + retro",
+			wantConflict: true,
+			wantFirst:    standup,
+		},
+		{
+			// Back-to-back meetings don't conflict.
+			items:     []*item.Item{planning, standup},
+			wantTitle: "standup + planning",
+			wantFirst: standup,
+		},
+		{
+			items:        []*item.Item{planning, overlapping, standup},
+			wantTitle:    "Conflict: standup + overlapping + planning",
+			wantConflict: true,
+			wantFirst:    standup,
+		},
+	} {
+		n := newGroupNotification(test.items, 30)
+		if n.Title != test.wantTitle || n.Conflict != test.wantConflict || n.Item != test.wantFirst {
+			t.Errorf("newGroupNotification(%v) = %q, conflict %v, first %v; want %q, %v, %v", test.items,
+				n.Title, n.Conflict, n.Item, test.wantTitle, test.wantConflict, test.wantFirst)
+		}
+		if len(n.Items) != len(test.items) || n.VisibilitySec != 30 {
+			t.Errorf("newGroupNotification(%v) = %+v, want all items and the visibility", test.items, n)
+		}
+	}
+}
+
+func TestGroupWith(t *testing.T) {
+	st := &stage{before: time.Minute, names: "a"}
+	other := &stage{before: time.Minute * 10, names: "a"}
+	n := &Notifier{
+		opts:      &Opts{GroupWindow: time.Minute},
+		processed: cache.New(),
+		sched:     schedule.New(newFakeClock()),
+	}
+	defer n.Close()
+	now := time.Now()
+	it := &item.Item{EventID: "it", Start: now.Add(time.Minute)}
+	soon := &item.Item{EventID: "soon", Start: now.Add(time.Minute + time.Second*30)}
+	later := &item.Item{EventID: "later", Start: now.Add(time.Minute * 5)}
+	otherStage := &item.Item{EventID: "other", Start: now.Add(time.Minute * 10)}
+
+	n.addPending("it", it, st, now)
+	n.addPending("soon", soon, st, now.Add(time.Second*30))
+	n.addPending("later", later, st, now.Add(time.Minute*4))
+	n.addPending("other", otherStage, other, now)
+
+	n.sched.At("soon", now.Add(time.Second*30), func(time.Time) {})
+
+	got := n.groupWith(it, st, now)
+	if len(got) != 1 || got[0] != soon {
+		t.Fatalf("groupWith() = %v, want only the meeting that is due soon", got)
+	}
+	// The grouped reminder is no longer scheduled.
+	if _, ok := n.sched.Due("soon"); ok {
+		t.Errorf("due() for a grouped meeting = _,true, want false")
+	}
+	// The grouped reminder fired and is no longer pending.
+	if n.processed.Fire(soon, st.before) {
+		t.Errorf("Fire() for a grouped meeting = true, want false")
+	}
+	if got := n.groupWith(it, st, now); len(got) != 0 {
+		t.Errorf("groupWith() a second time = %v, want none", got)
+	}
+
+	// Grouping can be disabled.
+	n.opts.GroupWindow = 0
+	n.addPending("soon", soon, st, now)
+	if got := n.groupWith(it, st, now); len(got) != 0 {
+		t.Errorf("groupWith() without a window = %v, want none", got)
+	}
+}
@@ -0,0 +1,44 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/item"
+)
+
+// DefaultGrace is the default value of Opts.Grace: meetings that started aren't notified, unless the
+// user asks for that.
+const DefaultGrace = 0
+
+// deadline is a helper to determine until when a reminder for an item may be shown: at its start, or
+// within a grace window after that, but not once the meeting has ended.
+func deadline(it *item.Item, grace time.Duration) time.Time {
+	if grace <= 0 {
+		return it.Start
+	}
+	out := it.Start.Add(grace)
+	if it.End.After(it.Start) && it.End.Before(out) {
+		out = it.End
+	}
+	return out
+}
+
+// started is a helper to determine how long ago a meeting started, in whole minutes. It is 0 when the
+// meeting didn't start, or less than a minute ago.
+func started(start, now time.Time) time.Duration {
+	ago := now.Sub(start).Truncate(time.Minute)
+	if ago < 0 {
+		return 0
+	}
+	return ago
+}
+
+// startedTitle is a helper to tell in a title that the meeting started a while ago.
+func startedTitle(title string, ago time.Duration) string {
+	minutes := "1 minute"
+	if m := int(ago.Minutes()); m != 1 {
+		minutes = fmt.Sprintf("%d minutes", m)
+	}
+	return fmt.Sprintf("%s: started %s ago — join now", title, minutes)
+}
@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/item"
+)
+
+func TestDeadline(t *testing.T) {
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		end   time.Time
+		grace time.Duration
+		want  time.Time
+	}{
+		{end: start.Add(time.Hour), want: start},
+		{end: start.Add(time.Hour), grace: time.Minute * 10, want: start.Add(time.Minute * 10)},
+		// Meetings that ended are no longer offered.
+		{end: start.Add(time.Minute * 5), grace: time.Minute * 10, want: start.Add(time.Minute * 5)},
+		{grace: time.Minute * 10, want: start.Add(time.Minute * 10)},
+	} {
+		it := &item.Item{Start: start, End: test.end}
+		if got := deadline(it, test.grace); !got.Equal(test.want) {
+			t.Errorf("deadline(%v-%v, %v) = %v, want %v", start, test.end, test.grace, got, test.want)
+		}
+	}
+}
+
+func TestStarted(t *testing.T) {
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		now       time.Time
+		want      time.Duration
+		wantTitle string
+	}{
+		{now: start.Add(-time.Minute * 5), want: 0},
+		{now: start.Add(time.Second * 59), want: 0},
+		{now: start.Add(time.Second * 61), want: time.Minute, wantTitle: "standup: started 1 minute ago — join now"},
+		{now: start.Add(time.Minute*3 + time.Second*30), want: time.Minute * 3, wantTitle: "standup: started 3 minutes ago — join now"},
+	} {
+		got := started(start, test.now)
+		if got != test.want {
+			t.Errorf("started(%v, %v) = %v, want %v", start, test.now, got, test.want)
+		}
+		if got > 0 {
+			if title := startedTitle("standup", got); title != test.wantTitle {
+				t.Errorf("startedTitle(standup, %v) = %q, want %q", got, title, test.wantTitle)
+			}
+		}
+	}
+}
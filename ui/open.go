@@ -0,0 +1,166 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/l"
+)
+
+// browser is the application, and its arguments, that opens a link. An empty application is the
+// default browser.
+type browser struct {
+	application string
+	args        []string
+}
+
+// openArgs is a helper to return the command that opens a link in a browser, for the given OS.
+// An empty application means the default one, which takes no arguments.
+func openArgs(goos string, b browser, link string) []string {
+	switch {
+	case goos == "darwin" && b.application != "" && len(b.args) > 0:
+		// A new instance is needed for the arguments to take effect when the browser is running.
+		out := []string{"open", "-na", b.application, "--args"}
+		out = append(out, b.args...)
+		return append(out, link)
+	case goos == "darwin" && b.application != "":
+		return []string{"open", "-a", b.application, link}
+	case goos == "darwin":
+		return []string{"open", link}
+	case b.application != "":
+		out := append([]string{b.application}, b.args...)
+		return append(out, link)
+	default:
+		return []string{"xdg-open", link}
+	}
+}
+
+// openLink opens a link in a browser. Only web links are opened, so that a crafted link can't be
+// mistaken for an option or a local program.
+func openLink(b browser, link string) error {
+	if link == "" {
+		return errors.New("no link to open")
+	}
+	if !strings.HasPrefix(link, "https://") && !strings.HasPrefix(link, "http://") {
+		return fmt.Errorf("refusing to open %q, it's not a web link", link)
+	}
+	args := openArgs(runtime.GOOS, b, link)
+	l.Infof("opening link: %v", args)
+	if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot open %q, output: %v, error: %v", link, string(out), err)
+	}
+	return nil
+}
+
+// openItem is a helper to open the join or calendar link of an item, in the browser that the routing
+// rules of the configuration choose, or else in Opts.Browser.
+func openItem(opts *Opts, it *item.Item, kind string) error {
+	link := it.JoinLink
+	if kind == config.LinkCalendar {
+		link = it.CalendarLink
+	}
+	return openLink(route(opts, it, kind), link)
+}
+
+// openNotes is a helper to open the links to the attachments and documents of an item, such as its
+// agenda. All links are tried, the first error is returned.
+func openNotes(opts *Opts, it *item.Item) error {
+	if len(it.Notes) == 0 {
+		return errors.New("no notes to open")
+	}
+	b := route(opts, it, config.LinkNotes)
+	var out error
+	for _, link := range it.Notes {
+		if err := openLink(b, link); err != nil && out == nil {
+			out = err
+		}
+	}
+	return out
+}
+
+// route is a helper to choose the browser for the join or calendar link of an item. The first rule that
+// matches wins.
+func route(opts *Opts, it *item.Item, kind string) browser {
+	if opts.Config != nil {
+		for _, r := range opts.Config.Browsers {
+			if routes(r, it, kind) {
+				return browser{application: r.Application, args: r.Args}
+			}
+		}
+	}
+	return browser{application: opts.Browser}
+}
+
+// routes is a helper to determine whether a routing rule applies to a link of an item.
+func routes(r *config.Browser, it *item.Item, kind string) bool {
+	if r.Link != "" && r.Link != kind {
+		return false
+	}
+	if r.Provider != "" && r.Provider != provider(it.JoinLink) {
+		return false
+	}
+	if len(r.Calendars) > 0 && !contains(r.Calendars, it.CalendarID) {
+		return false
+	}
+	if len(r.Accounts) == 0 {
+		return true
+	}
+	acc := it.Account()
+	if acc == "" {
+		return false
+	}
+	for _, a := range r.Accounts {
+		if strings.EqualFold(a, acc) || (strings.HasPrefix(a, "@") && strings.HasSuffix(strings.ToLower(acc), strings.ToLower(a))) {
+			return true
+		}
+	}
+	return false
+}
+
+// provider is a helper to determine the provider of a join link, such as "meet" or "teams".
+func provider(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Hostname() == "" {
+		return config.ProviderOther
+	}
+	host := strings.ToLower(u.Hostname())
+	for name, hosts := range config.Providers {
+		for _, h := range hosts {
+			if host == h || strings.HasSuffix(host, "."+h) {
+				return name
+			}
+		}
+	}
+	return config.ProviderOther
+}
+
+// contains is a helper to check whether a list holds a string.
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// join is a helper to run the join hooks of a meeting, if any, and to open its join link. With
+// Opts.JoinNotes, the notes of the meeting are opened too.
+func join(opts *Opts, it *item.Item) error {
+	if opts.Hooks != nil {
+		opts.Hooks.Join(it)
+	}
+	if err := openItem(opts, it, config.LinkJoin); err != nil {
+		return err
+	}
+	if opts.JoinNotes && len(it.Notes) > 0 {
+		return openNotes(opts, it)
+	}
+	return nil
+}
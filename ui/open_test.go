@@ -0,0 +1,183 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/hooks"
+	"github.com/KarelKubat/goto-meet/item"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestOpenArgs(t *testing.T) {
+	for _, test := range []struct {
+		goos     string
+		browser  browser
+		wantArgs []string
+	}{
+		{
+			goos:     "darwin",
+			wantArgs: []string{"open", "https://meet"},
+		},
+		{
+			goos:     "darwin",
+			browser:  browser{application: "Safari"},
+			wantArgs: []string{"open", "-a", "Safari", "https://meet"},
+		},
+		{
+			goos:     "darwin",
+			browser:  browser{application: "Google Chrome", args: []string{"--profile-directory=Profile 1"}},
+			wantArgs: []string{"open", "-na", "Google Chrome", "--args", "--profile-directory=Profile 1", "https://meet"},
+		},
+		{
+			goos:     "linux",
+			wantArgs: []string{"xdg-open", "https://meet"},
+		},
+		{
+			goos:     "linux",
+			browser:  browser{application: "firefox"},
+			wantArgs: []string{"firefox", "https://meet"},
+		},
+		{
+			goos:     "linux",
+			browser:  browser{application: "microsoft-edge", args: []string{"--new-window"}},
+			wantArgs: []string{"microsoft-edge", "--new-window", "https://meet"},
+		},
+	} {
+		if args := openArgs(test.goos, test.browser, "https://meet"); !reflect.DeepEqual(args, test.wantArgs) {
+			t.Errorf("openArgs(%q, %+v, _) = %v, want %v", test.goos, test.browser, args, test.wantArgs)
+		}
+	}
+}
+
+func TestOpenLink(t *testing.T) {
+	for _, link := range []string{
+		"",
+		"-a Calculator",
+		"file:///etc/passwd",
+		"javascript:alert(1)",
+	} {
+		if err := openLink(browser{}, link); err == nil {
+			t.Errorf("openLink(_, %q) = nil, want error", link)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "joined")
+	runner, err := hooks.New(&hooks.Opts{Hooks: []*config.Hook{{
+		Name:    "touch",
+		When:    config.HookJoin,
+		Command: []string{"touch", path},
+		Timeout: config.DefaultHookTimeout,
+	}}})
+	if err != nil {
+		t.Fatalf("hooks.New() = _,%v, require nil error", err)
+	}
+
+	// The join hooks run even when the link can't be opened.
+	if err := join(&Opts{Hooks: runner}, &item.Item{JoinLink: "file:///etc/passwd"}); err == nil {
+		t.Errorf("join() of a non-web link = nil, want error")
+	}
+	runner.Stop()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("join() didn't run the join hook: %v", err)
+	}
+}
+
+func TestOpenNotes(t *testing.T) {
+	if err := openNotes(&Opts{}, &item.Item{}); err == nil {
+		t.Errorf("openNotes() without notes = nil, want error")
+	}
+	if err := openNotes(&Opts{}, &item.Item{Notes: []string{"file:///etc/passwd"}}); err == nil {
+		t.Errorf("openNotes() of a non-web link = nil, want error")
+	}
+}
+
+func TestProvider(t *testing.T) {
+	for _, test := range []struct {
+		link string
+		want string
+	}{
+		{link: "https://meet.google.com/abc-defg-hij", want: "meet"},
+		{link: "https://stream.meet.google.com/stream/xyz", want: "meet"},
+		{link: "https://teams.microsoft.com/l/meetup-join/xyz", want: "teams"},
+		{link: "https://acme.zoom.us/j/123", want: "zoom"},
+		{link: "https://notzoom.us/j/123", want: config.ProviderOther},
+		{link: "https://acme.webex.com/meet/x", want: "webex"},
+		{link: "", want: config.ProviderOther},
+	} {
+		if got := provider(test.link); got != test.want {
+			t.Errorf("provider(%q) = %q, want %q", test.link, got, test.want)
+		}
+	}
+}
+
+func TestRoute(t *testing.T) {
+	opts := &Opts{
+		Browser: "firefox",
+		Config: &config.Config{Browsers: []*config.Browser{
+			{Link: config.LinkCalendar},
+			{Provider: "meet", Accounts: []string{"@work.com"}, Application: "Google Chrome", Args: []string{"--profile-directory=Profile 1"}},
+			{Provider: "teams", Application: "Microsoft Edge"},
+			{Calendars: []string{"family"}, Application: "Safari"},
+		}},
+	}
+	self := func(email string) *calendar.Event {
+		return &calendar.Event{Attendees: []*calendar.EventAttendee{{Email: "other@example.com"}, {Email: email, Self: true}}}
+	}
+	for _, test := range []struct {
+		name string
+		it   *item.Item
+		kind string
+		want browser
+	}{
+		{
+			name: "calendar links go to the default browser",
+			it:   &item.Item{JoinLink: "https://meet.google.com/x", Event: self("me@work.com")},
+			kind: config.LinkCalendar,
+			want: browser{},
+		},
+		{
+			name: "meet links of the work account go to a profile",
+			it:   &item.Item{JoinLink: "https://meet.google.com/x", Event: self("me@work.com")},
+			kind: config.LinkJoin,
+			want: browser{application: "Google Chrome", args: []string{"--profile-directory=Profile 1"}},
+		},
+		{
+			name: "the account may be the calendar",
+			it:   &item.Item{JoinLink: "https://meet.google.com/x", CalendarID: "me@work.com"},
+			kind: config.LinkJoin,
+			want: browser{application: "Google Chrome", args: []string{"--profile-directory=Profile 1"}},
+		},
+		{
+			name: "meet links of other accounts",
+			it:   &item.Item{JoinLink: "https://meet.google.com/x", Event: self("me@home.org")},
+			kind: config.LinkJoin,
+			want: browser{application: "firefox"},
+		},
+		{
+			name: "teams links",
+			it:   &item.Item{JoinLink: "https://teams.microsoft.com/l/x"},
+			kind: config.LinkJoin,
+			want: browser{application: "Microsoft Edge"},
+		},
+		{
+			name: "calendars",
+			it:   &item.Item{JoinLink: "https://zoom.us/j/1", CalendarID: "family"},
+			kind: config.LinkJoin,
+			want: browser{application: "Safari"},
+		},
+	} {
+		if got := route(opts, test.it, test.kind); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: route() = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+	if got, want := route(&Opts{Browser: "firefox"}, &item.Item{}, config.LinkJoin), (browser{application: "firefox"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("route() without a configuration = %+v, want %+v", got, want)
+	}
+}
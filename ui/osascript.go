@@ -0,0 +1,91 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// osascriptTpl renders a MacOSX dialog. Dialogs have at most three buttons, so "More…" offers a list
+// of the snooze choices, the calendar, the notes of meetings that have them, and muting the series of
+// recurring meetings. For grouped meetings, "Join" offers a list of the meetings.
+// The script prints the label of the clicked button or the chosen list entry ("Join 2" for the second
+// meeting), or nothing when the dialog timed out or a list was cancelled. Values are escaped for
+// AppleScript string literals.
+var osascriptTpl = template.Must(newTemplate("macos_osascript", EscapeAppleScript, `
+set res to display dialog ("{{.Title}}") buttons {"Skip", "More…", "Join"} default button "Join" giving up after {{.VisibilitySec}}
+if gave up of res then
+  return ""
+end if
+{{- if gt (len .Items) 1}}
+if button returned of res is "Join" then
+  set meetings to { {{- range $i, $it := .Items}}{{if $i}}, {{end}}"{{$it.Start | timefmt "15:04"}} {{$it.Title}}"{{end}}}
+  set choice to choose from list meetings with prompt ("{{.Title}}")
+  if choice is false then
+    return ""
+  end if
+  repeat with i from 1 to count of meetings
+    if item i of meetings is item 1 of choice then
+      return "Join " & i
+    end if
+  end repeat
+end if
+{{- end}}
+if button returned of res is "More…" then
+  set choice to choose from list { {{- range .Snooze}}"Snooze {{.}}", {{end}}"Calendar"{{if .Notes}}, "Notes"{{end}}{{if .Series}}, "Mute series"{{end}}} with prompt ("{{.Title}}")
+  if choice is false then
+    return ""
+  end if
+  return item 1 of choice
+end if
+return button returned of res
+`))
+
+// newOsascript creates a backend that shows dialogs using MacOSX's `osascript`.
+func newOsascript(opts *Opts) (Backend, error) {
+	return &command{
+		isModal: true,
+		args:    mustTemplates("macos_osascript", EscapeNone, "osascript"),
+		stdin:   osascriptTpl,
+		parse:   parseButton,
+	}, nil
+}
+
+// parseButton is a helper to map the output of a dialog, being the label of the clicked button or
+// the chosen list entry, to an action.
+func parseButton(out []byte, err error) (Action, error) {
+	if err != nil {
+		return ActionNone, fmt.Errorf("notifier failed, output: %v, error: %v", string(out), err)
+	}
+	label := strings.TrimSpace(string(out))
+	switch label {
+	case "":
+		return ActionNone, nil
+	case "Join":
+		return ActionJoin, nil
+	case "Calendar":
+		return ActionCalendar, nil
+	case "Skip":
+		return ActionSkip, nil
+	case "Notes":
+		return ActionNotes, nil
+	case "Mute series":
+		return ActionMute, nil
+	}
+	if nr := strings.TrimPrefix(label, "Join "); nr != label {
+		i, err := strconv.Atoi(nr)
+		if err != nil || i < 1 {
+			return ActionNone, fmt.Errorf("unexpected notifier output %q", string(out))
+		}
+		return Action{Kind: KindJoin, Item: i - 1}, nil
+	}
+	if name := strings.TrimPrefix(label, "Snooze "); name != label {
+		d, err := parseSnoozeName(name)
+		if err != nil {
+			return ActionNone, fmt.Errorf("unexpected notifier output %q: %v", string(out), err)
+		}
+		return snoozeFor(d), nil
+	}
+	return ActionNone, fmt.Errorf("unexpected notifier output %q", string(out))
+}
@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/item"
+)
+
+func TestOsascriptTemplate(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := osascriptTpl.Execute(buf, Notification{
+		Title:         "standup",
+		VisibilitySec: 42,
+		Snooze:        []string{"5m", "start"},
+	}); err != nil {
+		t.Fatalf("template execution = %v, require nil error", err)
+	}
+	for _, want := range []string{
+		`display dialog ("standup")`,
+		`giving up after 42`,
+		`choose from list {"Snooze 5m", "Snooze start", "Calendar"}`,
+		`return button returned of res`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expanded template %q lacks %q", buf.String(), want)
+		}
+	}
+}
+
+func TestParseButton(t *testing.T) {
+	for _, test := range []struct {
+		out        string
+		err        error
+		wantAction Action
+		wantError  bool
+	}{
+		{out: "\n", wantAction: ActionNone},
+		{out: "Join\n", wantAction: ActionJoin},
+		{out: "Calendar\n", wantAction: ActionCalendar},
+		{out: "Skip\n", wantAction: ActionSkip},
+		{out: "Mute series\n", wantAction: ActionMute},
+		{out: "Notes\n", wantAction: ActionNotes},
+		{out: "Snooze 5m\n", wantAction: snoozeFor(time.Minute * 5)},
+		{out: "Snooze start\n", wantAction: snoozeFor(SnoozeUntilStart)},
+		{out: "Snooze forever\n", wantError: true},
+		{out: "Join 2\n", wantAction: Action{Kind: KindJoin, Item: 1}},
+		{out: "Join zero\n", wantError: true},
+		{out: "Whatever\n", wantError: true},
+		{out: "Join\n", err: errors.New("boom"), wantError: true},
+	} {
+		action, err := parseButton([]byte(test.out), test.err)
+		if (err != nil) != test.wantError {
+			t.Errorf("parseButton(%q, %v) = _,%v, want error: %v", test.out, test.err, err, test.wantError)
+		}
+		if action != test.wantAction {
+			t.Errorf("parseButton(%q, %v) = %v,_, want %v", test.out, test.err, action, test.wantAction)
+		}
+	}
+}
+
+func TestOsascriptGroupTemplate(t *testing.T) {
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.Local)
+	n := newGroupNotification([]*item.Item{
+		{Title: "standup", Start: start, End: start.Add(time.Minute * 15)},
+		{Title: `"retro"`, Start: start, End: start.Add(time.Hour)},
+	}, 42)
+	buf := new(bytes.Buffer)
+	if err := osascriptTpl.Execute(buf, n); err != nil {
+		t.Fatalf("template execution = %v, require nil error", err)
+	}
+	for _, want := range []string{
+		`display dialog ("Conflict: standup + \"retro\"")`,
+		`set meetings to {"10:00 standup", "10:00 \"retro\""}`,
+		`return "Join " & i`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expanded template %q lacks %q", buf.String(), want)
+		}
+	}
+}
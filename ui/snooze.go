@@ -0,0 +1,97 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SnoozeUntilStart is the snooze choice that postpones a notification until its event starts.
+const SnoozeUntilStart = time.Duration(-1)
+
+// snoozeStart is the name of SnoozeUntilStart, as in --snooze=1m,start.
+const snoozeStart = "start"
+
+// DefaultSnooze is the default value of Opts.Snooze.
+var DefaultSnooze = []time.Duration{time.Minute, time.Minute * 2, time.Minute * 5, SnoozeUntilStart}
+
+// ParseSnooze converts a comma-separated list of snooze choices, such as "1m,5m,start", to
+// durations for Opts.Snooze.
+func ParseSnooze(s string) ([]time.Duration, error) {
+	out := []time.Duration{}
+	for _, name := range strings.Split(s, ",") {
+		d, err := parseSnoozeName(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// parseSnoozeName is a helper to convert the name of a snooze choice to its duration. Besides the
+// names of snoozeName, "until start" is understood.
+func parseSnoozeName(name string) (time.Duration, error) {
+	if name == snoozeStart || name == "until "+snoozeStart {
+		return SnoozeUntilStart, nil
+	}
+	d, err := time.ParseDuration(name)
+	if err != nil {
+		return 0, fmt.Errorf("snooze choices must be durations or %q: %v", snoozeStart, err)
+	}
+	if d <= 0 {
+		return 0, errors.New("snooze durations must be positive")
+	}
+	return d, nil
+}
+
+// snoozeName is a helper to give a snooze choice a short name, such as "5m" or "1h30m".
+func snoozeName(d time.Duration) string {
+	if d == SnoozeUntilStart {
+		return snoozeStart
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// snoozeNames is a helper to name all snooze choices.
+func snoozeNames(choices []time.Duration) []string {
+	out := []string{}
+	for _, d := range choices {
+		out = append(out, snoozeName(d))
+	}
+	return out
+}
+
+// snoozeUntil is a helper to determine until when an action snoozes a notification for an item that
+// starts at the given time. Snoozes without a duration take the first choice. Snoozing until the start
+// when the event has started takes the first choice that isn't that.
+func snoozeUntil(a Action, choices []time.Duration, start, now time.Time) time.Time {
+	d := a.Snooze
+	if d == 0 && len(choices) > 0 {
+		d = choices[0]
+	}
+	if d == SnoozeUntilStart {
+		if start.After(now) {
+			return start
+		}
+		d = 0
+		for _, c := range choices {
+			if c != SnoozeUntilStart {
+				d = c
+				break
+			}
+		}
+	}
+	if d <= 0 {
+		d = time.Minute
+	}
+	return now.Add(d)
+}
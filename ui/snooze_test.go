@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSnooze(t *testing.T) {
+	for _, test := range []struct {
+		s         string
+		want      []time.Duration
+		wantError bool
+	}{
+		{s: "1m", want: []time.Duration{time.Minute}},
+		{s: "1m, 90m,start", want: []time.Duration{time.Minute, time.Minute * 90, SnoozeUntilStart}},
+		{s: "", wantError: true},
+		{s: "1m,soon", wantError: true},
+		{s: "-1m", wantError: true},
+	} {
+		got, err := ParseSnooze(test.s)
+		if (err != nil) != test.wantError {
+			t.Errorf("ParseSnooze(%q) = _,%v, want error: %v", test.s, err, test.wantError)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseSnooze(%q) = %v,_, want %v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestSnoozeName(t *testing.T) {
+	for _, test := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: time.Minute, want: "1m"},
+		{d: time.Second * 30, want: "30s"},
+		{d: time.Minute*90 + time.Second*5, want: "1h30m5s"},
+		{d: time.Minute * 90, want: "1h30m"},
+		{d: time.Hour * 2, want: "2h"},
+		{d: SnoozeUntilStart, want: "start"},
+	} {
+		got := snoozeName(test.d)
+		if got != test.want {
+			t.Errorf("snoozeName(%v) = %q, want %q", test.d, got, test.want)
+		}
+		if back, err := parseSnoozeName(got); err != nil || back != test.d {
+			t.Errorf("parseSnoozeName(%q) = %v,%v, want %v,nil", got, back, err, test.d)
+		}
+	}
+}
+
+func TestSnoozeUntil(t *testing.T) {
+	now := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	choices := []time.Duration{SnoozeUntilStart, time.Minute * 2}
+	for _, test := range []struct {
+		action Action
+		start  time.Time
+		want   time.Time
+	}{
+		{action: snoozeFor(time.Minute * 5), start: now.Add(time.Hour), want: now.Add(time.Minute * 5)},
+		// The first choice is the default.
+		{action: ActionSnooze, start: now.Add(time.Hour), want: now.Add(time.Hour)},
+		// Events that started can't be snoozed until their start, the next choice is taken.
+		{action: ActionSnooze, start: now.Add(-time.Minute), want: now.Add(time.Minute * 2)},
+	} {
+		if got := snoozeUntil(test.action, choices, test.start, now); !got.Equal(test.want) {
+			t.Errorf("snoozeUntil(%v, %v, %v, _) = %v, want %v", test.action, choices, test.start, got, test.want)
+		}
+	}
+	if got, want := snoozeUntil(ActionSnooze, nil, now, now), now.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("snoozeUntil() without choices = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/l"
+)
+
+// soundTimeout is how long a sound may play. Commands that take longer are killed.
+const soundTimeout = time.Second * 30
+
+// Default commands of sounds, by OS, to play a file or to speak. Other OSes than "darwin" use the
+// defaults of "linux".
+var (
+	DefaultPlayCommands = map[string][]string{
+		"darwin": {"afplay", "{{.File}}"},
+		"linux":  {"paplay", "{{.File}}"},
+	}
+	DefaultSpeakCommands = map[string][]string{
+		"darwin": {"say", "{{.Speech}}"},
+		"linux":  {"espeak-ng", "{{.Speech}}"},
+	}
+)
+
+// soundData is what the command of a sound can use: the notification, the sound file and what to say.
+type soundData struct {
+	Notification
+	File   string
+	Speech string
+}
+
+// sound plays a sound file or speaks the meeting, alongside the notifications of a stage. It isn't a
+// Backend: nobody answers a sound, so notifications don't wait for it.
+type sound struct {
+	file   string
+	args   []*template.Template
+	alerts []string // other alerts than start reminders that play the sound
+}
+
+// newSound creates an audible alert. Without a command, the default for the OS is used.
+func newSound(s *config.Sound, goos string) (*sound, error) {
+	command := s.Command
+	if len(command) == 0 {
+		if goos != "darwin" {
+			goos = "linux"
+		}
+		command = DefaultSpeakCommands[goos]
+		if s.File != "" {
+			command = DefaultPlayCommands[goos]
+		}
+	}
+	args, err := templates("sound", EscapeNone, command...)
+	if err != nil {
+		return nil, fmt.Errorf("sound: cannot parse command: %v", err)
+	}
+	return &sound{file: s.File, args: args, alerts: s.Alerts}, nil
+}
+
+// newSoundFor is a helper to create a sound for this OS, or nil when there is no sound.
+func newSoundFor(s *config.Sound) (*sound, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return newSound(s, runtime.GOOS)
+}
+
+// playsFor is a helper to determine whether a sound plays for other alerts than start reminders, such as
+// config.AlertEnd. A nil sound plays for nothing.
+func (s *sound) playsFor(alert string) bool {
+	return s != nil && contains(s.alerts, alert)
+}
+
+// play plays a sound for a notification without waiting for it. A nil sound plays nothing.
+func (s *sound) play(n Notification) {
+	if s == nil {
+		return
+	}
+	go func() {
+		if err := s.run(context.Background(), n); err != nil {
+			l.Warnf("cannot play sound for %v: %v", n.Item, err)
+		}
+	}()
+}
+
+// run is a helper to play a sound for a notification, until it's done.
+func (s *sound) run(ctx context.Context, n Notification) error {
+	data := soundData{Notification: n, File: s.file, Speech: speech(n, time.Now())}
+	args := []string{}
+	for _, tpl := range s.args {
+		buf := new(bytes.Buffer)
+		if err := tpl.Execute(buf, data); err != nil {
+			return fmt.Errorf("cannot execute template: %v", err)
+		}
+		if buf.Len() > 0 {
+			args = append(args, buf.String())
+		}
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("sound: command is empty")
+	}
+	l.Infof("sound: %q", args)
+
+	ctx, cancel := context.WithTimeout(ctx, soundTimeout)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("sound %q failed, output: %v, error: %v", args, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// speech is a helper to describe a notification in a sentence to speak, such as "standup starts in 5
+// minutes". Titles of meetings that started, that end soon or that are about to be joined already tell
+// what's going on.
+func speech(n Notification, now time.Time) string {
+	if n.Started > 0 || n.Ending || n.AutoJoin {
+		return n.Title
+	}
+	m := int(n.Start.Sub(now).Round(time.Minute).Minutes())
+	switch {
+	case m <= 0:
+		return fmt.Sprintf("%s starts now", n.Title)
+	case m == 1:
+		return fmt.Sprintf("%s starts in 1 minute", n.Title)
+	default:
+		return fmt.Sprintf("%s starts in %d minutes", n.Title, m)
+	}
+}
@@ -0,0 +1,154 @@
+package ui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+)
+
+func TestSpeech(t *testing.T) {
+	now := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	n := newNotification(&item.Item{Title: "standup", Start: now.Add(time.Minute * 5)}, 0)
+	for _, test := range []struct {
+		start time.Time
+		want  string
+	}{
+		{start: now.Add(time.Minute * 5), want: "standup starts in 5 minutes"},
+		{start: now.Add(time.Minute*5 - time.Second*10), want: "standup starts in 5 minutes"},
+		{start: now.Add(time.Minute), want: "standup starts in 1 minute"},
+		{start: now.Add(time.Second * 20), want: "standup starts now"},
+	} {
+		n.Start = test.start
+		if got := speech(n, now); got != test.want {
+			t.Errorf("speech() of a meeting at %v = %q, want %q", test.start.Sub(now), got, test.want)
+		}
+	}
+	n.Started = time.Minute * 3
+	n.Title = startedTitle("standup", n.Started)
+	if got := speech(n, now); got != n.Title {
+		t.Errorf("speech() of a meeting that started = %q, want the title %q", got, n.Title)
+	}
+}
+
+func TestNewSound(t *testing.T) {
+	for _, test := range []struct {
+		sound *config.Sound
+		goos  string
+		want  string
+	}{
+		{sound: &config.Sound{}, goos: "darwin", want: "say standup starts in 5 minutes"},
+		{sound: &config.Sound{File: "/tmp/bell.wav"}, goos: "darwin", want: "afplay /tmp/bell.wav"},
+		{sound: &config.Sound{}, goos: "linux", want: "espeak-ng standup starts in 5 minutes"},
+		{sound: &config.Sound{File: "/tmp/bell.wav"}, goos: "freebsd", want: "paplay /tmp/bell.wav"},
+		{
+			sound: &config.Sound{Command: []string{"spd-say", "--wait", "{{.Speech}}"}},
+			goos:  "linux",
+			want:  "spd-say --wait standup starts in 5 minutes",
+		},
+	} {
+		s, err := newSound(test.sound, test.goos)
+		if err != nil {
+			t.Fatalf("newSound(%+v, %q) = _,%v, require nil error", test.sound, test.goos, err)
+		}
+		data := soundData{File: s.file, Speech: "standup starts in 5 minutes"}
+		args := []string{}
+		for _, tpl := range s.args {
+			buf := new(strings.Builder)
+			if err := tpl.Execute(buf, data); err != nil {
+				t.Fatalf("cannot execute template: %v", err)
+			}
+			args = append(args, buf.String())
+		}
+		if got := strings.Join(args, " "); got != test.want {
+			t.Errorf("newSound(%+v, %q) runs %q, want %q", test.sound, test.goos, got, test.want)
+		}
+	}
+	if _, err := newSound(&config.Sound{Command: []string{"say", "{{.Speech"}}, "linux"); err == nil {
+		t.Errorf("newSound() with a bad template = _,nil, want error")
+	}
+}
+
+func TestSoundRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "said")
+	s, err := newSound(&config.Sound{Command: []string{"sh", "-c", `echo "$1" > ` + path, "sh", "{{.Speech}}"}}, "linux")
+	if err != nil {
+		t.Fatalf("newSound() = _,%v, require nil error", err)
+	}
+	n := newNotification(&item.Item{Title: "standup", Start: time.Now().Add(time.Minute*2 + time.Second*10)}, 0)
+	if err := s.run(context.Background(), n); err != nil {
+		t.Fatalf("run() = %v, want nil error", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("os.ReadFile(%q) = _,%v, require nil error", path, err)
+	}
+	if want := "standup starts in 2 minutes\n"; string(got) != want {
+		t.Errorf("run() said %q, want %q", got, want)
+	}
+
+	s, err = newSound(&config.Sound{Command: []string{"false"}}, "linux")
+	if err != nil {
+		t.Fatalf("newSound() = _,%v, require nil error", err)
+	}
+	if err := s.run(context.Background(), n); err == nil {
+		t.Errorf("run() of a failing command = nil, want error")
+	}
+}
+
+func TestSoundPlay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "said")
+	// The sound takes a while, playing it doesn't.
+	s, err := newSound(&config.Sound{Command: []string{"sh", "-c", `sleep 1; echo "$1" > ` + path, "sh", "{{.Speech}}"}}, "linux")
+	if err != nil {
+		t.Fatalf("newSound() = _,%v, require nil error", err)
+	}
+	start := time.Now()
+	s.play(newNotification(&item.Item{Title: "standup", Start: time.Now()}, 0))
+	if d := time.Since(start); d > time.Millisecond*500 {
+		t.Errorf("play() took %v, want it not to wait for the sound", d)
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "standup starts now\n" {
+		t.Errorf("play() said %q (error: %v), want %q", got, err, "standup starts now\n")
+	}
+
+	// Without a sound, nothing plays.
+	var none *sound
+	none.play(Notification{})
+	if none.playsFor(config.AlertEnd) {
+		t.Errorf("playsFor() of no sound = true, want false")
+	}
+}
+
+func TestSoundPlaysFor(t *testing.T) {
+	for _, test := range []struct {
+		alerts []string
+		alert  string
+		want   bool
+	}{
+		{alert: config.AlertEnd, want: false},
+		{alert: config.AlertAutoJoin, want: false},
+		{alerts: []string{config.AlertEnd}, alert: config.AlertEnd, want: true},
+		{alerts: []string{config.AlertEnd}, alert: config.AlertAutoJoin, want: false},
+		{alerts: []string{config.AlertEnd, config.AlertAutoJoin}, alert: config.AlertAutoJoin, want: true},
+	} {
+		s, err := newSound(&config.Sound{Alerts: test.alerts}, "linux")
+		if err != nil {
+			t.Fatalf("newSound() = _,%v, require nil error", err)
+		}
+		if got := s.playsFor(test.alert); got != test.want {
+			t.Errorf("playsFor(%q) of a sound for %v = %v, want %v", test.alert, test.alerts, got, test.want)
+		}
+	}
+}
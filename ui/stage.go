@@ -0,0 +1,127 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/l"
+)
+
+// stage is a reminder at a lead time before events, rendered by its own backends.
+type stage struct {
+	before   time.Duration // lead time, 0 is at the start
+	names    string        // names of the backends, for logging
+	backends []Backend
+	sound    *sound // played alongside the backends, may be nil
+}
+
+// String returns a readable representation of a stage.
+func (s *stage) String() string {
+	return fmt.Sprintf("%v before the start through %q", s.before, s.names)
+}
+
+// reminder is a stage that is due for an item after a wait time.
+type reminder struct {
+	stage   *stage
+	wait    time.Duration
+	snoozed bool // the wait was extended to the end of a snooze
+}
+
+// newStages is a helper to create the reminder stages of a notifier and the backends that they use.
+// Without stages in the configuration, there is one at opts.StartsIn through the backends of opts.Name.
+// Stages that share a backend share its instance, and the stages are sorted by lead time, longest
+// first. The backends of opts.Name are returned as a separate stage at the start, for stages that follow
+// the reminders of events and for alerts before meetings end; it has the sound of the configuration.
+func newStages(opts *Opts) (stages []*stage, all []Backend, named *stage, err error) {
+	// Modal backends share one dispatcher, so that dialogs don't pile up.
+	d := newDispatcher(opts.ModalLimit)
+	byName := map[string]Backend{}
+	backendsFor := func(names string) ([]Backend, error) {
+		out := []Backend{}
+		for _, name := range strings.Split(names, ",") {
+			name = strings.TrimSpace(name)
+			b, ok := byName[name]
+			if !ok {
+				var err error
+				b, err = newBackend(name, opts)
+				if err != nil {
+					return nil, err
+				}
+				b = d.wrap(b)
+				byName[name] = b
+				all = append(all, b)
+			}
+			out = append(out, b)
+		}
+		return out, nil
+	}
+
+	if opts.Config == nil || len(opts.Config.Stages) == 0 || opts.CalendarReminders || opts.EndsIn > 0 {
+		named = &stage{names: opts.Name}
+		if named.backends, err = backendsFor(opts.Name); err != nil {
+			return nil, nil, nil, err
+		}
+		if opts.Config != nil {
+			if named.sound, err = newSoundFor(opts.Config.Sound); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+	if opts.Config == nil || len(opts.Config.Stages) == 0 {
+		stages = []*stage{{before: opts.StartsIn, names: opts.Name, backends: named.backends, sound: named.sound}}
+		return stages, all, named, nil
+	}
+	for _, st := range opts.Config.Stages {
+		s := &stage{
+			before: time.Duration(st.Before),
+			names:  st.Notification,
+		}
+		if s.names == "" {
+			s.names = opts.Name
+		}
+		if s.backends, err = backendsFor(s.names); err != nil {
+			return nil, nil, nil, err
+		}
+		if s.sound, err = newSoundFor(st.Sound); err != nil {
+			return nil, nil, nil, err
+		}
+		stages = append(stages, s)
+	}
+	sort.SliceStable(stages, func(i, j int) bool {
+		return stages[i].before > stages[j].before
+	})
+	return stages, all, named, nil
+}
+
+// reminderStages is a helper to create stages for the reminders of an event, through the backends and
+// with the sound of a stage.
+func reminderStages(it *item.Item, st *stage) []*stage {
+	out := []*stage{}
+	for _, before := range it.Reminders {
+		out = append(out, &stage{before: before, names: st.names, backends: st.backends, sound: st.sound})
+	}
+	return out
+}
+
+// reminders is a helper to determine when the stages are due for an item, when reminders can't be
+// shown before `from`. Of the stages that are due by then, only the one closest to the start is kept:
+// there's no point in a series of reminders at once.
+func reminders(stages []*stage, it *item.Item, from time.Duration) []*reminder {
+	out := []*reminder{}
+	for i, st := range stages {
+		if i+1 < len(stages) && it.StartsIn-stages[i+1].before <= from {
+			l.Infof("%v: skipping reminder %v, a later one is due", it, st)
+			continue
+		}
+		r := &reminder{stage: st, wait: it.StartsIn - st.before}
+		if r.wait < from {
+			r.wait = from
+			r.snoozed = from > 0
+		}
+		out = append(out, r)
+	}
+	return out
+}
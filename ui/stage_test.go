@@ -0,0 +1,172 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+)
+
+func TestNewStages(t *testing.T) {
+	for _, test := range []struct {
+		name              string
+		cfg               *config.Config
+		calendarReminders bool
+		wantBefore        []time.Duration
+		wantBackends      []int // backends per stage
+		wantAll           int
+		wantNamed         int    // backends of the stage of opts.Name, -1 when there is none
+		wantSounds        []bool // whether stages have a sound
+		wantNamedSound    bool
+	}{
+		{
+			name:         "default",
+			wantBefore:   []time.Duration{time.Minute * 2},
+			wantBackends: []int{2},
+			wantAll:      2,
+			wantNamed:    2,
+			wantSounds:   []bool{false},
+		},
+		{
+			name: "configured",
+			cfg: &config.Config{
+				Stages: []*config.Stage{
+					{Before: 0, Notification: "zenity"},
+					{Before: config.Duration(time.Minute * 10), Notification: "linux_dbus"},
+					{Before: config.Duration(time.Minute)},
+				},
+			},
+			wantBefore:   []time.Duration{time.Minute * 10, time.Minute, 0},
+			wantBackends: []int{1, 2, 1},
+			wantAll:      3, // linux_dbus is shared
+			wantNamed:    -1,
+			wantSounds:   []bool{false, false, false},
+		},
+		{
+			name: "configured with calendar reminders",
+			cfg: &config.Config{
+				Stages: []*config.Stage{
+					{Before: 0, Notification: "zenity"},
+				},
+			},
+			calendarReminders: true,
+			wantBefore:        []time.Duration{0},
+			wantBackends:      []int{1},
+			wantAll:           3,
+			wantNamed:         2,
+			wantSounds:        []bool{false},
+		},
+		{
+			name:           "default with a sound",
+			cfg:            &config.Config{Sound: &config.Sound{}},
+			wantBefore:     []time.Duration{time.Minute * 2},
+			wantBackends:   []int{2}, // sounds aren't backends
+			wantAll:        2,
+			wantNamed:      2,
+			wantSounds:     []bool{true},
+			wantNamedSound: true,
+		},
+		{
+			name: "configured with a sound",
+			cfg: &config.Config{
+				Stages: []*config.Stage{
+					{Before: 0, Notification: "zenity", Sound: &config.Sound{File: "/tmp/bell.wav"}},
+					{Before: config.Duration(time.Minute)},
+				},
+			},
+			wantBefore:   []time.Duration{time.Minute, 0},
+			wantBackends: []int{2, 1},
+			wantAll:      3,
+			wantNamed:    -1,
+			wantSounds:   []bool{false, true},
+		},
+	} {
+		stages, all, named, err := newStages(&Opts{
+			Name:              "linux_dbus,macos_osascript",
+			StartsIn:          time.Minute * 2,
+			Config:            test.cfg,
+			CalendarReminders: test.calendarReminders,
+		})
+		if err != nil {
+			t.Fatalf("%v: newStages() = _,_,_,%v, require nil error", test.name, err)
+		}
+		switch {
+		case named == nil && test.wantNamed >= 0:
+			t.Errorf("%v: newStages() returned no stage of the named backends, want %v backends", test.name, test.wantNamed)
+		case named != nil && len(named.backends) != test.wantNamed:
+			t.Errorf("%v: newStages() returned %v named backends, want %v", test.name, len(named.backends), test.wantNamed)
+		case named != nil && (named.sound != nil) != test.wantNamedSound:
+			t.Errorf("%v: newStages() returned named backends with sound: %v, want %v", test.name, named.sound != nil, test.wantNamedSound)
+		}
+		if len(all) != test.wantAll {
+			t.Errorf("%v: newStages() created %v backends, want %v", test.name, len(all), test.wantAll)
+		}
+		if len(stages) != len(test.wantBefore) {
+			t.Fatalf("%v: newStages() = %v, want %v stages", test.name, stages, len(test.wantBefore))
+		}
+		for i, st := range stages {
+			if st.before != test.wantBefore[i] || len(st.backends) != test.wantBackends[i] {
+				t.Errorf("%v: stage %v = %v with %v backends, want %v with %v", test.name, i, st.before, len(st.backends),
+					test.wantBefore[i], test.wantBackends[i])
+			}
+			if (st.sound != nil) != test.wantSounds[i] {
+				t.Errorf("%v: stage %v has a sound: %v, want %v", test.name, i, st.sound != nil, test.wantSounds[i])
+			}
+		}
+	}
+
+	if _, _, _, err := newStages(&Opts{
+		Name:   "linux_dbus",
+		Config: &config.Config{Stages: []*config.Stage{{Notification: "nonsense"}}},
+	}); err == nil {
+		t.Errorf("newStages() with an unknown notification type = _,_,_,nil, want error")
+	}
+}
+
+func TestReminders(t *testing.T) {
+	stages := []*stage{{before: time.Minute * 10}, {before: time.Minute}, {before: 0}}
+	for _, test := range []struct {
+		startsIn  time.Duration
+		from      time.Duration
+		wantWaits []time.Duration // per stage, -1 when skipped
+	}{
+		{
+			startsIn:  time.Hour,
+			wantWaits: []time.Duration{time.Minute * 50, time.Minute * 59, time.Hour},
+		},
+		{
+			// The first stage is due and shown right away.
+			startsIn:  time.Minute * 5,
+			wantWaits: []time.Duration{0, time.Minute * 4, time.Minute * 5},
+		},
+		{
+			// Only the last due stage is shown.
+			startsIn:  time.Second * 30,
+			wantWaits: []time.Duration{-1, 0, time.Second * 30},
+		},
+		{
+			// A snooze postpones stages, the ones that are due by then collapse.
+			startsIn:  time.Minute * 15,
+			from:      time.Minute * 14,
+			wantWaits: []time.Duration{-1, time.Minute * 14, time.Minute * 15},
+		},
+	} {
+		it := &item.Item{StartsIn: test.startsIn}
+		rems := reminders(stages, it, test.from)
+		got := map[*stage]time.Duration{}
+		for _, r := range rems {
+			got[r.stage] = r.wait
+		}
+		for i, st := range stages {
+			wait, ok := got[st]
+			if !ok {
+				wait = -1
+			}
+			if wait != test.wantWaits[i] {
+				t.Errorf("reminders(_, starts in %v, %v): stage %v waits %v, want %v", test.startsIn, test.from, st.before,
+					wait, test.wantWaits[i])
+			}
+		}
+	}
+}
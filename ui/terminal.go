@@ -0,0 +1,450 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/l"
+)
+
+// ANSI sequences to control the terminal.
+const (
+	ansiClear      = "\x1b[H\x1b[2J"
+	ansiHideCursor = "\x1b[?25l"
+	ansiShowCursor = "\x1b[?25h"
+	ansiBold       = "\x1b[1m"
+	ansiReverse    = "\x1b[7m"
+	ansiReset      = "\x1b[0m"
+	ansiBell       = "\a"
+)
+
+// Keys that the terminal backend understands.
+const (
+	keyJoin     = 'j'
+	keyCalendar = 'c'
+	keySnooze   = 's'
+	keySkip     = 'k'
+	keyMute     = 'm'
+	keyNotes    = 'n'
+)
+
+// terminalWidth is the width that titles are truncated to.
+const terminalWidth = 60
+
+// prompt is a notification that waits for a key.
+type prompt struct {
+	n        Notification
+	action   chan Action
+	choosing bool // for grouped meetings: join was pressed, the number of the meeting must follow
+}
+
+// terminal is a Backend that renders an agenda of upcoming meetings with countdowns in a terminal.
+// Notifications ring the bell and wait for a key. When no notification is waiting, keys act on
+// the next meeting.
+type terminal struct {
+	opts    *Opts
+	out     io.Writer
+	keys    chan byte
+	redraw  chan struct{}
+	done    chan struct{} // closed when the terminal is closed
+	restore func()        // puts the terminal back into its original mode
+	mu      sync.Mutex
+	agenda  []*item.Item
+	prompts []*prompt // most recent last
+	once    sync.Once
+}
+
+// newTerminal creates a backend that uses the terminal of goto-meet. The terminal is put into a mode
+// where keys are read without waiting for enter, and without echoing them.
+func newTerminal(opts *Opts) (Backend, error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("terminal notifications need a terminal: %v", err)
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil, fmt.Errorf("cannot set terminal mode: %v", err)
+	}
+	t := newTerminalOn(opts, os.Stdin, os.Stdout)
+	t.restore = func() {
+		io.WriteString(os.Stdout, ansiShowCursor+ansiReset+"\n")
+		if _, err := stty(strings.TrimSpace(saved)); err != nil {
+			l.Warnf("cannot restore terminal mode: %v", err)
+		}
+	}
+	// Closing the notifier, also when goto-meet is interrupted, restores the terminal.
+	return t, nil
+}
+
+// newTerminalOn is a helper to create a terminal backend on a given input and output.
+func newTerminalOn(opts *Opts, in io.Reader, out io.Writer) *terminal {
+	t := &terminal{
+		opts:    opts,
+		out:     out,
+		keys:    make(chan byte),
+		redraw:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		restore: func() {},
+	}
+	io.WriteString(out, ansiHideCursor)
+	go t.readKeys(in)
+	go t.run()
+	return t
+}
+
+// stty is a helper to run stty on the terminal of goto-meet.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// Show implements Backend. It rings the bell and waits for a key, until the notification should
+// disappear.
+func (t *terminal) Show(ctx context.Context, n Notification) (Action, error) {
+	p := &prompt{
+		n:      n,
+		action: make(chan Action, 1),
+	}
+	t.mu.Lock()
+	t.prompts = append(t.prompts, p)
+	io.WriteString(t.out, ansiBell)
+	t.mu.Unlock()
+	t.kick()
+
+	var timeout <-chan time.Time
+	if n.VisibilitySec > 0 {
+		timer := time.NewTimer(time.Duration(n.VisibilitySec) * time.Second)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case a := <-p.action:
+		return a, nil
+	case <-ctx.Done():
+	case <-timeout:
+	}
+	t.mu.Lock()
+	removed := t.removePrompt(p)
+	t.mu.Unlock()
+	if !removed {
+		// A key was pressed in the meantime.
+		return <-p.action, nil
+	}
+	t.kick()
+	return ActionNone, nil
+}
+
+// Agenda implements agendaBackend.
+func (t *terminal) Agenda(items []*item.Item) {
+	agenda := append([]*item.Item{}, items...)
+	sort.SliceStable(agenda, func(i, j int) bool {
+		return agenda[i].Start.Before(agenda[j].Start)
+	})
+	t.mu.Lock()
+	t.agenda = agenda
+	t.mu.Unlock()
+	t.kick()
+}
+
+// Close implements io.Closer. Rendering stops and the terminal is restored.
+func (t *terminal) Close() error {
+	t.once.Do(func() {
+		close(t.done)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.restore()
+	})
+	return nil
+}
+
+// kick is a helper to request a redraw.
+func (t *terminal) kick() {
+	select {
+	case t.redraw <- struct{}{}:
+	default:
+	}
+}
+
+// readKeys is a helper to pass key presses to the render loop.
+func (t *terminal) readKeys(in io.Reader) {
+	buf := make([]byte, 16)
+	for {
+		n, err := in.Read(buf)
+		for _, b := range buf[:n] {
+			select {
+			case t.keys <- b:
+			case <-t.done:
+				return
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				l.Warnf("cannot read keys: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// run is a helper to redraw the screen every second and upon changes, and to handle keys.
+func (t *terminal) run() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		t.render(time.Now())
+		select {
+		case k := <-t.keys:
+			t.handle(k)
+		case <-t.redraw:
+		case <-ticker.C:
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// handle is a helper to act upon a key. It answers the most recent notification, or acts on the next
+// meeting in the background, so that starting a browser doesn't hold up the screen. Digits choose a
+// snooze choice of the notification. For grouped meetings, join asks which meeting, which the next
+// digit chooses.
+func (t *terminal) handle(k byte) {
+	t.mu.Lock()
+	var p *prompt
+	if len(t.prompts) > 0 {
+		p = t.prompts[len(t.prompts)-1]
+	}
+	if p != nil && len(p.n.Items) > 1 && (p.choosing || unicode.ToLower(rune(k)) == keyJoin) {
+		choosing := p.choosing
+		p.choosing = !choosing
+		if i := int(k) - '1'; choosing && i >= 0 && i < len(p.n.Items) && i < 9 {
+			t.removePrompt(p)
+			t.mu.Unlock()
+			p.action <- Action{Kind: KindJoin, Item: i}
+			return
+		}
+		t.mu.Unlock()
+		t.kick()
+		return
+	}
+	action, ok := keyAction(k, p)
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	if p != nil {
+		t.removePrompt(p)
+		t.mu.Unlock()
+		p.action <- action
+		return
+	}
+	next := nextItem(t.agenda, time.Now())
+	t.mu.Unlock()
+
+	if next != nil {
+		go t.act(action, next)
+	}
+}
+
+// act is a helper to perform an action on the next meeting.
+func (t *terminal) act(action Action, next *item.Item) {
+	var err error
+	switch action.Kind {
+	case KindJoin:
+		err = join(t.opts, next)
+	case KindCalendar:
+		err = openItem(t.opts, next, config.LinkCalendar)
+	case KindNotes:
+		err = openNotes(t.opts, next)
+	}
+	if err != nil {
+		l.Warnf("cannot perform %v for %v: %v", action, next, err)
+	}
+}
+
+// keyAction is a helper to map a key to an action. Snoozing doesn't apply to end alerts. Digits only
+// apply to a prompt that has as many snooze choices, muting only to a prompt of a recurring meeting,
+// notes only to a prompt of a meeting that has them or to the next meeting.
+func keyAction(k byte, p *prompt) (Action, bool) {
+	switch unicode.ToLower(rune(k)) {
+	case keyJoin:
+		return ActionJoin, true
+	case keyCalendar:
+		return ActionCalendar, true
+	case keySnooze:
+		if p != nil && p.n.Ending {
+			return ActionNone, false
+		}
+		return ActionSnooze, true
+	case keySkip:
+		return ActionSkip, true
+	case keyMute:
+		if p == nil || p.n.Series == "" {
+			return ActionNone, false
+		}
+		return ActionMute, true
+	case keyNotes:
+		if p != nil && len(p.n.Notes) == 0 {
+			return ActionNone, false
+		}
+		return ActionNotes, true
+	}
+	if p == nil || k < '1' || k > '9' || int(k-'1') >= len(p.n.Snooze) {
+		return ActionNone, false
+	}
+	d, err := parseSnoozeName(p.n.Snooze[k-'1'])
+	if err != nil {
+		return ActionNone, false
+	}
+	return snoozeFor(d), true
+}
+
+// removePrompt is a helper to remove a prompt, it returns false if it was already removed. The
+// caller must hold the lock.
+func (t *terminal) removePrompt(p *prompt) bool {
+	for i, q := range t.prompts {
+		if q == p {
+			t.prompts = append(t.prompts[:i], t.prompts[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// render is a helper to draw the screen. The lock is held while writing, so that a closing terminal
+// isn't drawn upon after it was restored.
+func (t *terminal) render(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	io.WriteString(t.out, t.screen(now))
+}
+
+// screen is a helper to compose the screen: the agenda with the next meeting highlighted, and the
+// notifications that wait for a key. The caller must hold the lock.
+func (t *terminal) screen(now time.Time) string {
+	b := new(strings.Builder)
+	b.WriteString(ansiClear)
+	fmt.Fprintf(b, "%sgoto-meet%s  %s\r\n\r\n", ansiBold, ansiReset, now.Format("Mon Jan 2 15:04:05"))
+
+	next := nextItem(t.agenda, now)
+	shown := 0
+	for _, it := range t.agenda {
+		if !now.Before(it.End) && !now.Before(it.Start) {
+			continue
+		}
+		line := fmt.Sprintf("%s  %8s  %s", it.Start.Local().Format("15:04"), countdown(it.Start.Sub(now)),
+			truncate(terminalWidth, terminalSafe(it.Title)))
+		if it == next {
+			line = ansiReverse + "> " + line + ansiReset
+		} else {
+			line = "  " + line
+		}
+		b.WriteString(line + "\r\n")
+		shown++
+	}
+	if shown == 0 {
+		b.WriteString("  No upcoming meetings.\r\n")
+	}
+
+	b.WriteString("\r\n")
+	if len(t.prompts) > 0 {
+		p := t.prompts[len(t.prompts)-1]
+		if p.n.Started > 0 || p.n.Ending || p.n.AutoJoin {
+			fmt.Fprintf(b, "%s%s%s\r\n", ansiBold, truncate(terminalWidth, terminalSafe(p.n.Title)), ansiReset)
+		} else {
+			fmt.Fprintf(b, "%s%s starts in %s%s\r\n", ansiBold, truncate(terminalWidth, terminalSafe(p.n.Title)),
+				countdown(p.n.Start.Sub(now)), ansiReset)
+		}
+		if len(p.n.Items) > 1 {
+			for i, it := range p.n.Items {
+				fmt.Fprintf(b, "  [%d] %s  %s\r\n", i+1, it.Start.Local().Format("15:04"),
+					truncate(terminalWidth, terminalSafe(it.Title)))
+			}
+			if p.n.Conflict {
+				b.WriteString("  These meetings overlap.\r\n")
+			}
+		}
+		if p.choosing {
+			fmt.Fprintf(b, "Join which meeting? Press 1-%d, any other key cancels.\r\n", len(p.n.Items))
+			return b.String()
+		}
+		if p.n.Ending {
+			b.WriteString("[j]oin  [c]alendar  s[k]ip")
+		} else {
+			b.WriteString("[j]oin  [c]alendar  [s]nooze  s[k]ip")
+		}
+		if len(p.n.Notes) > 0 {
+			b.WriteString("  [n]otes")
+		}
+		if p.n.Series != "" {
+			b.WriteString("  [m]ute series")
+		}
+		b.WriteString("\r\n")
+		if len(p.n.Snooze) > 0 {
+			b.WriteString("Snooze:")
+			for i, name := range p.n.Snooze {
+				if i < 9 {
+					fmt.Fprintf(b, "  [%d] %s", i+1, name)
+				}
+			}
+			b.WriteString("\r\n")
+		}
+	} else if next != nil {
+		b.WriteString("Next meeting: [j]oin  [c]alendar")
+		if len(next.Notes) > 0 {
+			b.WriteString("  [n]otes")
+		}
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+// nextItem is a helper to find the first meeting in an agenda that didn't start yet.
+func nextItem(agenda []*item.Item, now time.Time) *item.Item {
+	for _, it := range agenda {
+		if it.Start.After(now) {
+			return it
+		}
+	}
+	return nil
+}
+
+// countdown is a helper to show the time until a start as "now", "mm:ss" or "1h02m".
+func countdown(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "now"
+	case d < time.Hour:
+		d = d.Round(time.Second)
+		return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// terminalSafe is a helper to remove control characters, so that meeting titles can't send escape
+// sequences to the terminal.
+func terminalSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
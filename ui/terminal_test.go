@@ -0,0 +1,281 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/item"
+)
+
+// screenBuffer collects what a terminal backend writes.
+type screenBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *screenBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *screenBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+// lastScreen returns what was drawn since the last clear.
+func (s *screenBuffer) lastScreen() string {
+	out := s.String()
+	if i := strings.LastIndex(out, ansiClear); i >= 0 {
+		return out[i+len(ansiClear):]
+	}
+	return out
+}
+
+func newTestTerminal(t *testing.T) (*terminal, *io.PipeWriter, *screenBuffer) {
+	in, keys := io.Pipe()
+	out := &screenBuffer{}
+	term := newTerminalOn(&Opts{}, in, out)
+	t.Cleanup(func() {
+		keys.Close()
+		term.Close()
+	})
+	return term, keys, out
+}
+
+func TestTerminalShow(t *testing.T) {
+	for _, test := range []struct {
+		key        string
+		series     string
+		notes      []string
+		wantAction Action
+	}{
+		{key: "j", wantAction: ActionJoin},
+		{key: "C", wantAction: ActionCalendar},
+		{key: "s", wantAction: ActionSnooze},
+		{key: "2", wantAction: snoozeFor(SnoozeUntilStart)},
+		{key: "3k", wantAction: ActionSkip}, // there's no third snooze choice
+		{key: "k", wantAction: ActionSkip},
+		{key: "?k", wantAction: ActionSkip}, // unknown keys are ignored
+		{key: "mk", wantAction: ActionSkip}, // only recurring meetings can be muted
+		{key: "m", series: "abc", wantAction: ActionMute},
+		{key: "nk", wantAction: ActionSkip}, // only meetings with notes can open them
+		{key: "n", notes: []string{"https://docs/agenda"}, wantAction: ActionNotes},
+	} {
+		term, keys, out := newTestTerminal(t)
+		done := make(chan Action)
+		go func() {
+			action, err := term.Show(context.Background(), Notification{
+				Title:  "standup",
+				Start:  time.Now().Add(time.Minute),
+				Snooze: []string{"5m", "start"},
+				Series: test.series,
+				Notes:  test.notes,
+			})
+			if err != nil {
+				t.Errorf("Show() = _,%v, want nil error", err)
+			}
+			done <- action
+		}()
+
+		// Wait for the prompt before pressing keys.
+		for !strings.Contains(out.String(), "[j]oin") {
+			time.Sleep(time.Millisecond * 10)
+		}
+		if !strings.Contains(out.String(), ansiBell) || !strings.Contains(out.lastScreen(), "standup starts in") ||
+			!strings.Contains(out.lastScreen(), "[2] start") {
+			t.Errorf("Show(): screen = %q, want bell and prompt", out.String())
+		}
+		io.WriteString(keys, test.key)
+		if got := <-done; got != test.wantAction {
+			t.Errorf("Show() with key %q = %v, want %v", test.key, got, test.wantAction)
+		}
+	}
+}
+
+func TestTerminalEnding(t *testing.T) {
+	term, keys, out := newTestTerminal(t)
+	done := make(chan Action)
+	go func() {
+		action, _ := term.Show(context.Background(), Notification{
+			Title:  "standup ends in 5 minutes",
+			Ending: true,
+		})
+		done <- action
+	}()
+	for !strings.Contains(out.String(), "[j]oin") {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if screen := out.lastScreen(); strings.Contains(screen, "[s]nooze") {
+		t.Errorf("Show() of an end alert: screen = %q, want no snooze", screen)
+	}
+	// End alerts can't be snoozed.
+	io.WriteString(keys, "sk")
+	if got := <-done; got != ActionSkip {
+		t.Errorf("Show() of an end alert with keys \"sk\" = %v, want %v", got, ActionSkip)
+	}
+}
+
+func TestTerminalGroup(t *testing.T) {
+	start := time.Now().Add(time.Minute)
+	n := newGroupNotification([]*item.Item{
+		{Title: "standup", Start: start, End: start.Add(time.Minute * 15)},
+		{Title: "retro", Start: start, End: start.Add(time.Hour)},
+	}, 0)
+	for _, test := range []struct {
+		keys       string
+		wantAction Action
+	}{
+		{keys: "j2", wantAction: Action{Kind: KindJoin, Item: 1}},
+		{keys: "J1", wantAction: ActionJoin},
+		{keys: "jxk", wantAction: ActionSkip}, // other keys cancel choosing
+		{keys: "j9j1", wantAction: ActionJoin},
+	} {
+		term, keys, out := newTestTerminal(t)
+		done := make(chan Action)
+		go func() {
+			action, _ := term.Show(context.Background(), n)
+			done <- action
+		}()
+		for !strings.Contains(out.String(), "[j]oin") {
+			time.Sleep(time.Millisecond * 10)
+		}
+		screen := out.lastScreen()
+		if !strings.Contains(screen, "[2] ") || !strings.Contains(screen, "retro") || !strings.Contains(screen, "overlap") {
+			t.Errorf("Show(): screen = %q, want the meetings and the conflict", screen)
+		}
+		io.WriteString(keys, test.keys)
+		if got := <-done; got != test.wantAction {
+			t.Errorf("Show() with keys %q = %v, want %v", test.keys, got, test.wantAction)
+		}
+	}
+}
+
+func TestTerminalTimeout(t *testing.T) {
+	term, _, out := newTestTerminal(t)
+	action, err := term.Show(context.Background(), Notification{
+		Title:         "standup",
+		VisibilitySec: 1,
+	})
+	if action != ActionNone || err != nil {
+		t.Errorf("Show() = %v,%v, want %v,nil", action, err, ActionNone)
+	}
+	term.kick()
+	time.Sleep(time.Millisecond * 50)
+	if strings.Contains(out.lastScreen(), "standup starts in") {
+		t.Errorf("Show(): prompt is still on screen after timing out: %q", out.lastScreen())
+	}
+}
+
+func TestTerminalAgenda(t *testing.T) {
+	now := time.Now()
+	past := &item.Item{Title: "past", Start: now.Add(-time.Hour * 2), End: now.Add(-time.Hour)}
+	current := &item.Item{Title: "current", Start: now.Add(-time.Minute), End: now.Add(time.Hour)}
+	next := &item.Item{Title: "next\x1b[31m", Start: now.Add(time.Minute * 5), End: now.Add(time.Hour)}
+	later := &item.Item{Title: "later", Start: now.Add(time.Hour * 2), End: now.Add(time.Hour * 3)}
+
+	term, _, _ := newTestTerminal(t)
+	term.Agenda([]*item.Item{later, next, current, past})
+	term.mu.Lock()
+	screen := term.screen(now)
+	term.mu.Unlock()
+
+	for _, want := range []string{
+		"current",
+		// The escape character is removed, the rest of the sequence is harmless text.
+		ansiReverse + "> " + next.Start.Local().Format("15:04") + "     05:00  next[31m" + ansiReset,
+		"2h00m  later",
+	} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen = %q, want it to contain %q", screen, want)
+		}
+	}
+	for _, unwanted := range []string{"past", "\x1b[31m"} {
+		if strings.Contains(screen, unwanted) {
+			t.Errorf("screen = %q, don't want it to contain %q", screen, unwanted)
+		}
+	}
+	if strings.Index(screen, "current") > strings.Index(screen, "later") {
+		t.Errorf("screen = %q, want meetings in order of start", screen)
+	}
+}
+
+func TestTerminalNextMeeting(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("browsers are started through open(1)")
+	}
+	// The browser takes a while to start.
+	dir := t.TempDir()
+	opened := filepath.Join(dir, "opened")
+	browser := filepath.Join(dir, "browser")
+	if err := os.WriteFile(browser, []byte(fmt.Sprintf("#!/bin/sh\nsleep 1\necho \"$1\" > %s\n", opened)), 0755); err != nil {
+		t.Fatalf("cannot create fake browser: %v", err)
+	}
+	in, keys := io.Pipe()
+	term := newTerminalOn(&Opts{Browser: browser}, in, &screenBuffer{})
+	defer func() {
+		keys.Close()
+		term.Close()
+	}()
+	term.Agenda([]*item.Item{{Title: "next", JoinLink: "https://meet/next", Start: time.Now().Add(time.Minute * 5), End: time.Now().Add(time.Hour)}})
+
+	start := time.Now()
+	term.handle(keyJoin)
+	if d := time.Since(start); d > time.Millisecond*500 {
+		t.Errorf("handle() of join took %v, want it not to wait for the browser", d)
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(opened); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	if got, err := os.ReadFile(opened); err != nil || strings.TrimSpace(string(got)) != "https://meet/next" {
+		t.Errorf("handle() of join opened %q (error: %v), want the link of the next meeting", got, err)
+	}
+}
+
+func TestTerminalClose(t *testing.T) {
+	term, _, out := newTestTerminal(t)
+	restored := 0
+	term.restore = func() { restored++ }
+	term.Close()
+	term.Close()
+	if restored != 1 {
+		t.Errorf("Close() restored the terminal %v times, want once", restored)
+	}
+	before := out.String()
+	term.render(time.Now())
+	if out.String() != before {
+		t.Errorf("render() after Close() drew on the terminal")
+	}
+}
+
+func TestCountdown(t *testing.T) {
+	for _, test := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: -time.Minute, want: "now"},
+		{d: 0, want: "now"},
+		{d: time.Second * 5, want: "00:05"},
+		{d: time.Minute*59 + time.Second*59, want: "59:59"},
+		{d: time.Hour, want: "1h00m"},
+		{d: time.Hour*25 + time.Minute*2, want: "25h02m"},
+	} {
+		if got := countdown(test.d); got != test.want {
+			t.Errorf("countdown(%v) = %q, want %q", test.d, got, test.want)
+		}
+	}
+}
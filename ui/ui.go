@@ -2,188 +2,425 @@
 package ui
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"os/exec"
-	"text/template"
+	"io"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/KarelKubat/goto-meet/cache"
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/hooks"
 	"github.com/KarelKubat/goto-meet/item"
 	"github.com/KarelKubat/goto-meet/l"
-)
-
-const (
-	// Interval between clock skew checks
-	heartbeatInterval = time.Second * 10
-)
-
-// The name, system command and corresponding template for a notification.
-type notificationSettings struct {
-	name string
-	args []string
-	tpl  *template.Template
-}
-
-var (
-	// UI notifications. Use {{.Browser}} and so on to fill in. The `args`
-	// program will be called with the expanded `tpl` on stdin.
-	notificationConfig = []*notificationSettings{
-		{
-			name: "macos_osascript",
-			args: []string{"osascript"},
-			tpl: template.Must(template.New("macos_osascript").Parse(`
-display dialog ("{{.Title}}") buttons {"Join", "Calendar", "Skip"} giving up after {{.VisibilitySec}}
-if button returned of result = "Join" then
-  {{if .Browser }}
-  tell application "{{.Browser}}"
-    activate
-    open location "{{.JoinLink}}"
-  end tell
-  {{ else }}
-  open location "{{.JoinLink}}"
-  {{ end }}
-else if button returned of result = "Calendar" then
-  {{if .Browser }}
-  tell application "{{.Browser}}"
-    activate
-    open location "{{.CalendarLink}}"
-  end tell
-  {{ else }}
-  open location "{{.CalendarLink}}"
-  {{ end }}
-end if
-`)),
-		},
-	}
+	"github.com/KarelKubat/goto-meet/mute"
+	"github.com/KarelKubat/goto-meet/quiet"
+	"github.com/KarelKubat/goto-meet/schedule"
 )
 
 // Opts wraps the options to create a notifier.
 type Opts struct {
-	Name          string        // Name of this notifier
-	StartsIn      time.Duration // Duration before the event to render the UI
-	VisibilitySec int           // How long the UI should stay visible
-	Browser       string        // Browser to call upon "join"
+	Name              string          // Name of this notifier
+	StartsIn          time.Duration   // Duration before the event to render the UI, unless Config has stages
+	VisibilitySec     int             // How long the UI should stay visible
+	Browser           string          // Browser to call upon "join"
+	Config            *config.Config  // User-defined notifiers and such, may be nil
+	Snooze            []time.Duration // Snooze choices, the first one is the default; DefaultSnooze when nil
+	CalendarReminders bool            // Remind at the popup reminders of events through the backends of Name, if they have any
+	GroupWindow       time.Duration   // Reminders of a stage that are due within this window share a notification, 0 to disable
+	ModalLimit        int             // Max # of modal notifications, such as dialogs, on screen at once; 0 means 1
+	Grace             time.Duration   // Meetings that started up to this long ago are still notified, 0 to disable
+	Quiet             *quiet.Policy   // When notifications are suppressed, may be nil
+	Hooks             *hooks.Runner   // Runs the join hooks when the user joins a meeting, may be nil
+	EndsIn            time.Duration   // Duration before the end of a meeting to alert through the backends of Name, 0 to disable
+	JoinRecord        string          // File that records the meetings that were joined without asking, "" for none
+	Mute              *mute.List      // Recurring meetings that aren't notified, may be nil
+	JoinNotes         bool            // Joining a meeting also opens its attachments and documents
 }
 
-// Notifier wraps the applicable notification configuration.
+// Notifier wraps the applicable notification backends.
 type Notifier struct {
-	opts      *Opts                 // Name, lead time etc. to show an alert before a meeting starts
-	config    *notificationSettings // One of the notificationConfigs
-	processed *cache.Cache          // Has an event been processed yet?
+	opts      *Opts               // Name, lead time etc. to show an alert before a meeting starts
+	backends  []Backend           // One or more of the backendTypes, or user-defined ones
+	named     *stage              // The backends of opts.Name, for the reminders of events and end alerts
+	stages    []*stage            // When to show alerts through which backends, longest lead time first
+	processed *cache.Cache        // Has an event been processed yet? Which stages fired?
+	sched     *schedule.Scheduler // Runs reminders and snoozes when they are due
+	pending   map[string]*pending // Reminders that wait to be shown, by their key in the scheduler
+	agenda    []*item.Item        // The items of the last calendar poll, to find back-to-back meetings
+	autoJoins []*autoJoin         // Rules for meetings that are joined without asking
+	mu        sync.Mutex
 }
 
 // New creates a Notifier.
 func New(opts *Opts) (*Notifier, error) {
-	availableNotificationTypes := []string{}
-	for _, config := range notificationConfig {
-		if config.name == opts.Name {
-			// Matched the requested UI notifier.
-			out := &Notifier{
-				config:    config,
-				opts:      opts,
-				processed: cache.New(),
-			}
-			// Start the heartbeat to remove cached entries when a clock skew is detected.
-			go func() {
-				for {
-					start := time.Now()
-					time.Sleep(heartbeatInterval)
-					// Unconsciousness for more than 1 second will be detected.
-					if time.Now().After(start.Add(heartbeatInterval + time.Second)) {
-						l.Infof("time skew detected")
-						out.processed.Clear()
-					}
-				}
-			}()
-			l.Infof("notifier %q created to alert %v before event start", opts.Name, opts.StartsIn)
-			return out, nil
-		}
-		availableNotificationTypes = append(availableNotificationTypes, config.name)
+	if opts.Snooze == nil {
+		opts.Snooze = DefaultSnooze
 	}
-
-	// No handler found for the requested UI notifier.
-	return nil, fmt.Errorf("no such notification type %q, choose one of %v", opts.Name, availableNotificationTypes)
-}
-
-// temp is used in template expansion.
-type temp struct {
-	Title         string // event title
-	VisibilitySec int    // # secs on screen
-	Browser       string // browser to fire up
-	JoinLink      string // link to join the meet
-	CalendarLink  string // link to see the event on the calendar
+	stages, backends, named, err := newStages(opts)
+	if err != nil {
+		return nil, err
+	}
+	autoJoins, err := newAutoJoins(opts.Config)
+	if err != nil {
+		return nil, err
+	}
+	out := &Notifier{
+		opts:      opts,
+		backends:  backends,
+		named:     named,
+		stages:    stages,
+		processed: cache.New(),
+		sched:     schedule.New(nil),
+		autoJoins: autoJoins,
+	}
+	for _, st := range stages {
+		l.Infof("notifier created to alert %v", st)
+	}
+	if opts.CalendarReminders {
+		l.Infof("notifier created to alert at the reminders of events through %q", opts.Name)
+	}
+	if opts.EndsIn > 0 {
+		l.Infof("notifier created to alert %v before the end of meetings through %q", opts.EndsIn, opts.Name)
+	}
+	return out, nil
 }
 
-// Show notifies the user of an upcoming event.
+// Schedule arranges for the user to be notified of an upcoming event.
 func (n *Notifier) Schedule(it *item.Item) {
 	n.processed.Weed()
-	toSchedule, waitTime := n.shouldSchedule(it)
+	// Muted meetings aren't recorded as processed, so that they are scheduled when they are unmuted.
+	if n.muted(it) {
+		return
+	}
+	n.scheduleEnd(it)
+	toSchedule, rems := n.shouldSchedule(it)
 	if !toSchedule {
 		return
 	}
+	// A modified event replaces the reminders of its earlier version.
+	n.cancel(reminderKey(it, nil))
+	// Meetings that are joined without asking get a countdown instead of reminders.
+	if a := n.autoJoinFor(it); a != nil {
+		n.scheduleAutoJoin(it, a)
+		return
+	}
+	now := n.sched.Now()
+	for _, r := range rems {
+		r := r
+		key := reminderKey(it, r.stage)
+		l.Infof("notification in %v for event %v, %v", r.wait, it, r.stage)
+		n.addPending(key, it, r.stage, now.Add(r.wait))
+		n.sched.At(key, now.Add(r.wait), func(now time.Time) {
+			n.removePending(key)
+			n.remind(it, r, now)
+		})
+	}
+}
 
-	go func(it *item.Item) {
-		l.Infof("notification in %v for event %v", waitTime, it)
-		time.Sleep(waitTime)
+// reminderPrefix starts the keys in the scheduler of reminders.
+const reminderPrefix = "remind::"
 
-		// We've woken up and it's time to show a notification. In the meantime the laptop might have
-		// gone to sleep and woken up way past the the starttime of the event - in which case we just return.
-		//
-		// Fortunately there's a heartbeat that detects clock skew and clears the cache, so that events are
-		// re-scheduled into another go-routine. So this event notifier may fail, there will be a backup.
-		if time.Now().After(it.Start.Add(time.Second)) {
-			l.Infof("skipping notifiying for %v, it's too much in the past", it)
-			return
-		}
+// reminderKey is a helper to derive the key in the scheduler of an event's reminder at a stage. The
+// key is stable when the event is modified, so that the reminder of the new version replaces the old
+// one. Without a stage, the key is the prefix of all reminders of the event.
+func reminderKey(it *item.Item, st *stage) string {
+	key := reminderPrefix + it.Key() + "::"
+	if st != nil {
+		key += st.before.String()
+	}
+	return key
+}
+
+// seriesPrefix is a helper to derive the prefix of the keys in the scheduler of the reminders of all
+// instances of a recurring event, or "" when the event doesn't recur.
+func seriesPrefix(it *item.Item) string {
+	if it.SeriesKey() == "" {
+		return ""
+	}
+	return reminderPrefix + it.SeriesKey()
+}
 
-		t := &temp{
-			Title:         it.Title,
-			VisibilitySec: n.opts.VisibilitySec,
-			Browser:       n.opts.Browser,
-			JoinLink:      it.JoinLink,
-			CalendarLink:  it.CalendarLink,
+// cancel is a helper to cancel the scheduled reminders with keys that start with a prefix.
+func (n *Notifier) cancel(prefix string) {
+	if c := n.sched.CancelPrefix(prefix); c > 0 {
+		l.Infof("canceled %d scheduled reminder(s) for %q", c, prefix)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for key := range n.pending {
+		if strings.HasPrefix(key, prefix) {
+			delete(n.pending, key)
 		}
-		buf := new(bytes.Buffer)
-		if err := n.config.tpl.Execute(buf, t); err != nil {
-			l.Warnf("cannot execute template: %v", err)
+	}
+}
+
+// remind is a helper to show a reminder that is due at the given time.
+func (n *Notifier) remind(it *item.Item, r *reminder, now time.Time) {
+	// The notification may have been snoozed while we were waiting, e.g. when the user snoozed an
+	// earlier reminder or a notification for an earlier version of the event. The reminder waits for
+	// the snooze; whoever ends the snooze first shows the notification. Snoozed notifications may be
+	// shown until the event ends.
+	until := deadline(it, n.opts.Grace)
+	if r.snoozed {
+		until = it.End
+	}
+	if snoozed := n.processed.SnoozedUntil(it); !snoozed.IsZero() {
+		if snoozed.Sub(now) > time.Second {
+			l.Infof("notification for %v is snoozed until %v", it, snoozed)
+			n.sched.At(reminderKey(it, r.stage), snoozed, func(now time.Time) {
+				n.remind(it, r, now)
+			})
 			return
 		}
-		l.Infof("template: %v", buf.String())
-		cmd := exec.Command(n.config.args[0], n.config.args[1:]...)
-		stdin, err := cmd.StdinPipe()
-		if err != nil {
-			l.Warnf("cannot create pipe to notifier: %v", err)
+		if !n.processed.EndSnooze(it, snoozed) {
+			l.Infof("skipping notifying for %v, the snooze showed it", it)
 			return
 		}
-		go func() {
-			defer stdin.Close()
-			_, err := stdin.Write(buf.Bytes())
-			if err != nil {
-				l.Warnf("failed to write to notifier: %v", err)
+		until = it.End
+	}
+
+	// It's time to show a notification. In the meantime the laptop might have gone to sleep and woken
+	// up way past the the starttime of the event. Within the grace window the user is told to join a
+	// meeting that started, past that we just return.
+	if now.After(until.Add(time.Second)) {
+		l.Infof("skipping notifiying for %v, it's too much in the past", it)
+		return
+	}
+	// The event may have been modified while we were waiting, in which case the new version
+	// was scheduled separately.
+	if n.processed.Superseded(it) {
+		l.Infof("skipping notifying for %v, it was modified in the meantime", it)
+		return
+	}
+	// The series may have been muted while we were waiting.
+	if n.muted(it) {
+		return
+	}
+	// Quiet hours suppress the notification.
+	if n.quiet(it, now) {
+		return
+	}
+	// The stage may have fired already for an earlier version of the event.
+	if !n.processed.Fire(it, r.stage.before) {
+		l.Infof("skipping notifying for %v, the reminder %v was already shown", it, r.stage)
+		return
+	}
+
+	// Meetings at about the same time share the notification.
+	n.show(append([]*item.Item{it}, n.groupWith(it, r.stage, now)...), r.stage)
+}
+
+// show is a helper to render a notification for one or more meetings through the backends of a stage
+// and to perform the chosen action.
+func (n *Notifier) show(items []*item.Item, st *stage) {
+	notification := newGroupNotification(items, n.opts.VisibilitySec)
+	notification.Snooze = snoozeNames(n.opts.Snooze)
+	if ago := started(notification.Start, time.Now()); ago > 0 {
+		notification.Started = ago
+		notification.Title = startedTitle(notification.Title, ago)
+	}
+	notification.stale = func() bool {
+		return n.stale(items)
+	}
+	st.sound.play(notification)
+	action := showAll(context.Background(), st.backends, notification)
+	it := notification.Item
+	if action.Item > 0 && action.Item < len(notification.Items) {
+		it = notification.Items[action.Item]
+	}
+	l.Infof("notification for %v: user chose %v", it, action)
+	var err error
+	switch action.Kind {
+	case KindJoin:
+		err = join(n.opts, it)
+	case KindCalendar:
+		err = openItem(n.opts, it, config.LinkCalendar)
+	case KindSnooze:
+		n.snooze(notification.Items, st, snoozeUntil(action, n.opts.Snooze, it.Start, time.Now()))
+	case KindMute:
+		err = n.mute(it)
+	case KindNotes:
+		err = openNotes(n.opts, it)
+	}
+	if err != nil {
+		l.Warnf("cannot perform %v for %v: %v", action, it, err)
+	}
+}
+
+// muted is a helper to determine whether the series of a meeting is muted.
+func (n *Notifier) muted(it *item.Item) bool {
+	if n.opts.Mute == nil || !n.opts.Mute.Muted(it) {
+		return false
+	}
+	l.Infof("skipping %v, its series is muted", it)
+	return true
+}
+
+// mute is a helper to mute the series of a meeting, and to cancel the reminders of its instances that
+// were already scheduled.
+func (n *Notifier) mute(it *item.Item) error {
+	if n.opts.Mute == nil {
+		return fmt.Errorf("there is no mute list")
+	}
+	if err := n.opts.Mute.Mute(it); err != nil {
+		return err
+	}
+	l.Infof("muted the series %q of %v", mute.Series(it), it)
+	if prefix := seriesPrefix(it); prefix != "" {
+		n.cancel(prefix)
+	}
+	return nil
+}
+
+// snooze is a helper to show a notification of a stage again at the given time. The snooze is recorded
+// in the cache, so that a modified version of an event, which is scheduled anew, waits for it too.
+// Snoozing may go past the start of an event, but not past its end.
+func (n *Notifier) snooze(items []*item.Item, st *stage, until time.Time) {
+	for _, it := range items {
+		n.processed.Snooze(it, until)
+	}
+	// Snoozing again replaces the snooze.
+	key := "snooze::" + reminderKey(items[0], st)
+	n.sched.At(key, until, func(now time.Time) {
+		again := []*item.Item{}
+		for _, it := range items {
+			switch {
+			case !now.Before(it.End) && !now.Before(it.Start):
+				l.Infof("skipping snoozed notification for %v, it has ended", it)
+			case n.processed.Superseded(it):
+				// A new version leaves the snooze to its reminder.
+				l.Infof("skipping snoozed notification for %v, it was modified in the meantime", it)
+			case !n.processed.EndSnooze(it, until):
+				l.Infof("skipping snoozed notification for %v, it was snoozed again or shown", it)
+			default:
+				again = append(again, it)
+			}
+		}
+		if len(again) > 0 && !n.quiet(again[0], now) {
+			n.show(again, st)
+		}
+	})
+}
+
+// quiet is a helper to determine whether notifications are suppressed at a given time.
+func (n *Notifier) quiet(it *item.Item, now time.Time) bool {
+	if n.opts.Quiet == nil {
+		return false
+	}
+	reason := n.opts.Quiet.Quiet(now)
+	if reason == "" {
+		return false
+	}
+	l.Infof("not notifying for %v, %v", it, reason)
+	return true
+}
+
+// stale is a helper to determine whether notifying about meetings became pointless, because they all
+// ended or were modified.
+func (n *Notifier) stale(items []*item.Item) bool {
+	now := time.Now()
+	for _, it := range items {
+		if n.processed.Superseded(it) {
+			continue
+		}
+		// Events without an end are over once they started.
+		if now.Before(it.End) || now.Before(it.Start.Add(time.Second)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Agenda passes the items of the last calendar poll to the backends that show them. The notifier keeps
+// them to tell whether meetings are back-to-back.
+func (n *Notifier) Agenda(items []*item.Item) {
+	n.mu.Lock()
+	n.agenda = items
+	n.mu.Unlock()
+	for _, b := range n.backends {
+		if a, ok := b.(agendaBackend); ok {
+			a.Agenda(items)
+		}
+	}
+}
+
+// Close stops the scheduling of reminders and releases the backends that need that, such as a terminal
+// that must be restored.
+func (n *Notifier) Close() {
+	if n.sched != nil {
+		n.sched.Close()
+	}
+	for _, b := range n.backends {
+		if c, ok := b.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				l.Warnf("cannot close notification backend: %v", err)
 			}
-		}()
-		out, err := cmd.CombinedOutput()
-		if err != nil {
-			l.Warnf("notifier failed, output: %v, error: %v", string(out), err)
-			return
 		}
-	}(it)
+	}
+}
+
+// showAll is a helper to present a notification through backends at once. The first action that any
+// backend reports is returned, and the notifications of the other backends are withdrawn. Deliveries
+// of detached backends, such as webhooks, go on: they have their own timeouts.
+func showAll(ctx context.Context, backends []Backend, notification Notification) Action {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	actions := make(chan Action, len(backends))
+	for _, b := range backends {
+		go func(b Backend) {
+			bctx := ctx
+			if d, ok := b.(detachedBackend); ok && d.detached() {
+				bctx = context.Background()
+			}
+			action, err := b.Show(bctx, notification)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				l.Warnf("cannot show notification for %v: %v", notification.Item, err)
+			}
+			actions <- action
+		}(b)
+	}
+	for range backends {
+		if action := <-actions; action != ActionNone {
+			return action
+		}
+	}
+	return ActionNone
 }
 
-// shouldSchedule is a helper to determine whether an item is worthy of scheduling.
-func (n *Notifier) shouldSchedule(it *item.Item) (bool, time.Duration) {
+// shouldSchedule is a helper to determine whether an item is worthy of scheduling, and when to remind
+// the user.
+func (n *Notifier) shouldSchedule(it *item.Item) (bool, []*reminder) {
 	switch {
-	case it.StartsIn < 0:
+	case it.StartsIn < 0 && time.Now().After(deadline(it, n.opts.Grace)):
 		l.Infof("%q starts in the past, not worthy scheduling; start: %v", it.Title, it.Start)
-		return false, 0
+		return false, nil
 	case it.JoinLink == "":
 		l.Infof("%v has no join link, not worthy scheduling; entry: %v", it, it.Event)
-		return false, 0
-	case n.processed.Lookup(it):
+		return false, nil
+	}
+	switch n.processed.Lookup(it) {
+	case cache.Unchanged:
 		l.Infof("%v already processed, not worthy (re)scheduling", it)
-		return false, 0
-	default:
-		return true, it.StartsIn - n.opts.StartsIn
+		return false, nil
+	case cache.Changed:
+		l.Infof("%v was modified since it was processed, rescheduling", it)
+	}
+	if it.StartsIn < 0 {
+		l.Infof("%v started %v ago, notifying within the grace window of %v", it, -it.StartsIn, n.opts.Grace)
+	}
+	// Reminders wait for a snooze to end.
+	from := time.Until(n.processed.SnoozedUntil(it))
+	if from > 0 {
+		l.Infof("%v is snoozed, notifying in %v at the earliest", it, from)
+	} else {
+		from = 0
+	}
+	stages := n.stages
+	if n.opts.CalendarReminders && len(it.Reminders) > 0 {
+		stages = reminderStages(it, n.named)
 	}
+	return true, reminders(stages, it, from)
 }
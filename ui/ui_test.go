@@ -1,12 +1,22 @@
 package ui
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/KarelKubat/goto-meet/cache"
 	"github.com/KarelKubat/goto-meet/item"
+	"github.com/KarelKubat/goto-meet/mute"
+	"github.com/KarelKubat/goto-meet/quiet"
+	"github.com/KarelKubat/goto-meet/schedule"
+
+	"google.golang.org/api/calendar/v3"
 )
 
 func TestNew(t *testing.T) {
@@ -21,6 +31,13 @@ func TestNew(t *testing.T) {
 			notifier:  "nonsense",
 			wantError: "no such notification type",
 		},
+		{
+			notifier: "macos_osascript, linux_dbus",
+		},
+		{
+			notifier:  "macos_osascript,nonsense",
+			wantError: "no such notification type",
+		},
 	} {
 		_, err := New(&Opts{
 			Name: test.notifier,
@@ -40,6 +57,7 @@ func TestShouldSchedule(t *testing.T) {
 	for _, test := range []struct {
 		title       string
 		startsIn    time.Duration
+		grace       time.Duration
 		joinLink    string
 		wantOutcome bool
 	}{
@@ -60,20 +78,556 @@ func TestShouldSchedule(t *testing.T) {
 			startsIn:    time.Hour,
 			wantOutcome: false,
 		},
+		{
+			title:       "started within the grace window",
+			startsIn:    -3 * time.Minute,
+			grace:       time.Minute * 10,
+			joinLink:    "whatever",
+			wantOutcome: true,
+		},
+		{
+			title:       "started before the grace window",
+			startsIn:    -20 * time.Minute,
+			grace:       time.Minute * 10,
+			joinLink:    "whatever",
+			wantOutcome: false,
+		},
+		{
+			title:       "ended within the grace window",
+			startsIn:    -40 * time.Minute,
+			grace:       time.Hour,
+			joinLink:    "whatever",
+			wantOutcome: false,
+		},
 	} {
 		n := &Notifier{
 			opts: &Opts{
 				StartsIn: time.Minute * 30, // consider anything that starts within half an hour
+				Grace:    test.grace,
 			},
 			processed: cache.New(),
 		}
+		start := time.Now().Add(test.startsIn)
 		it := &item.Item{
 			Title:    "whatever",
 			JoinLink: test.joinLink,
 			StartsIn: test.startsIn,
+			Start:    start,
+			End:      start.Add(time.Minute * 30),
 		}
 		if outcome, _ := n.shouldSchedule(it); outcome != test.wantOutcome {
 			t.Errorf("%v: shouldSchedule(%v) = %v, want %v", test.title, it, outcome, test.wantOutcome)
 		}
 	}
 }
+
+func TestQuiet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pause")
+	policy, err := quiet.New(&quiet.Opts{PauseFile: path})
+	if err != nil {
+		t.Fatalf("quiet.New() = _,%v, require nil error", err)
+	}
+	fb := &fakeBackend{action: ActionSkip}
+	n := &Notifier{
+		opts:      &Opts{Quiet: policy},
+		processed: cache.New(),
+	}
+	st := &stage{backends: []Backend{fb}}
+	it := &item.Item{Title: "standup", JoinLink: "https://meet", Start: time.Now().Add(time.Minute)}
+
+	if err := quiet.Pause(path, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("quiet.Pause() = %v, require nil error", err)
+	}
+	n.remind(it, &reminder{stage: st}, time.Now())
+	if len(fb.shown) != 0 {
+		t.Errorf("remind() while paused showed %v notifications, want 0", len(fb.shown))
+	}
+	// A suppressed reminder didn't fire.
+	if err := quiet.Pause(path, time.Time{}); err != nil {
+		t.Fatalf("quiet.Pause() = %v, require nil error", err)
+	}
+	n.remind(it, &reminder{stage: st}, time.Now())
+	if len(fb.shown) != 1 {
+		t.Errorf("remind() after resuming showed %v notifications, want 1", len(fb.shown))
+	}
+}
+
+func TestStale(t *testing.T) {
+	now := time.Now()
+	n := &Notifier{opts: &Opts{}, processed: cache.New()}
+	upcoming := &item.Item{EventID: "upcoming", Version: "1", Start: now.Add(time.Minute), End: now.Add(time.Hour)}
+	ended := &item.Item{EventID: "ended", Version: "1", Start: now.Add(-time.Hour), End: now.Add(-time.Minute)}
+	started := &item.Item{EventID: "started", Version: "1", Start: now.Add(-time.Minute)} // no end
+	modified := &item.Item{EventID: "upcoming", Version: "2", Start: now.Add(time.Minute), End: now.Add(time.Hour)}
+	n.processed.Lookup(modified)
+
+	for _, test := range []struct {
+		items []*item.Item
+		want  bool
+	}{
+		{items: []*item.Item{ended}, want: true},
+		{items: []*item.Item{started}, want: true},
+		{items: []*item.Item{upcoming}, want: true}, // superseded by the modified version
+		{items: []*item.Item{modified}, want: false},
+		{items: []*item.Item{ended, modified}, want: false},
+	} {
+		if got := n.stale(test.items); got != test.want {
+			t.Errorf("stale(%v) = %v, want %v", test.items, got, test.want)
+		}
+	}
+}
+
+// fakeBackend records what it is asked to show and responds with a fixed action.
+type fakeBackend struct {
+	action Action
+	shown  []Notification
+	mu     sync.Mutex
+}
+
+func (f *fakeBackend) Show(ctx context.Context, n Notification) (Action, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.shown = append(f.shown, n)
+	return f.action, nil
+}
+
+func TestShow(t *testing.T) {
+	for _, test := range []struct {
+		start       time.Time
+		wantTitle   string
+		wantStarted time.Duration
+	}{
+		{start: time.Now().Add(time.Minute), wantTitle: "standup"},
+		{start: time.Now().Add(-time.Second * 30), wantTitle: "standup"},
+		{
+			start:       time.Now().Add(-time.Minute * 3),
+			wantTitle:   "standup: started 3 minutes ago — join now",
+			wantStarted: time.Minute * 3,
+		},
+	} {
+		fb := &fakeBackend{action: ActionSkip}
+		n := &Notifier{
+			opts: &Opts{
+				VisibilitySec: 30,
+			},
+			backends:  []Backend{fb},
+			processed: cache.New(),
+		}
+		n.show([]*item.Item{{
+			Title:    "standup",
+			JoinLink: "https://meet",
+			Start:    test.start,
+		}}, &stage{backends: n.backends})
+		if len(fb.shown) != 1 {
+			t.Fatalf("show() rendered %v notifications, want 1", len(fb.shown))
+		}
+		got := fb.shown[0]
+		if got.Title != test.wantTitle || got.Started != test.wantStarted || got.JoinLink != "https://meet" || got.VisibilitySec != 30 {
+			t.Errorf("show() of a meeting at %v rendered %+v, want title %q, started %v, join link and visibility of the item",
+				test.start, got, test.wantTitle, test.wantStarted)
+		}
+	}
+}
+
+func TestShowAll(t *testing.T) {
+	for _, test := range []struct {
+		actions    []Action
+		wantAction Action
+	}{
+		{actions: []Action{ActionNone}, wantAction: ActionNone},
+		{actions: []Action{ActionNone, ActionNone}, wantAction: ActionNone},
+		{actions: []Action{ActionNone, ActionJoin}, wantAction: ActionJoin},
+		{actions: []Action{ActionCalendar, ActionNone}, wantAction: ActionCalendar},
+	} {
+		backends := []Backend{}
+		for _, a := range test.actions {
+			backends = append(backends, &fakeBackend{action: a})
+		}
+		if action := showAll(context.Background(), backends, Notification{Title: "standup"}); action != test.wantAction {
+			t.Errorf("showAll() with backends responding %v = %v, want %v", test.actions, action, test.wantAction)
+		}
+	}
+}
+
+// blockingBackend is a Backend that waits until its notification is withdrawn.
+type blockingBackend struct {
+	withdrawn chan struct{}
+}
+
+func (b *blockingBackend) Show(ctx context.Context, n Notification) (Action, error) {
+	<-ctx.Done()
+	close(b.withdrawn)
+	return ActionNone, ctx.Err()
+}
+
+func TestShowAllWithdraws(t *testing.T) {
+	blocking := &blockingBackend{withdrawn: make(chan struct{})}
+	fast := &fakeBackend{action: ActionJoin}
+	done := make(chan Action)
+	go func() {
+		done <- showAll(context.Background(), []Backend{blocking, fast}, Notification{Title: "standup"})
+	}()
+	select {
+	case action := <-done:
+		if action != ActionJoin {
+			t.Errorf("showAll() = %v, want %v", action, ActionJoin)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("showAll() waits for a backend that doesn't answer")
+	}
+	select {
+	case <-blocking.withdrawn:
+	case <-time.After(time.Second):
+		t.Errorf("showAll() didn't withdraw the notification of the backend that didn't answer")
+	}
+	if len(fast.shown) != 1 {
+		t.Errorf("showAll(): the answering backend showed %v notifications, want 1", len(fast.shown))
+	}
+}
+
+func TestSnooze(t *testing.T) {
+	fb := &fakeBackend{action: snoozeFor(time.Millisecond * 50)}
+	n := &Notifier{
+		opts: &Opts{
+			Snooze: []time.Duration{time.Minute, SnoozeUntilStart},
+		},
+		backends:  []Backend{fb},
+		processed: cache.New(),
+		sched:     schedule.New(nil),
+	}
+	defer n.Close()
+	it := &item.Item{
+		Title:    "standup",
+		JoinLink: "https://meet",
+		Start:    time.Now().Add(time.Hour),
+		End:      time.Now().Add(time.Hour * 2),
+	}
+	n.show([]*item.Item{it}, &stage{backends: n.backends})
+	if until := n.processed.SnoozedUntil(it); until.IsZero() {
+		t.Errorf("show() snoozed, but the cache has no snooze")
+	}
+	fb.mu.Lock()
+	fb.action = ActionSkip
+	fb.mu.Unlock()
+
+	time.Sleep(time.Millisecond * 150)
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	if len(fb.shown) != 2 {
+		t.Fatalf("show() with a snooze rendered %v notifications, want 2", len(fb.shown))
+	}
+	if got := strings.Join(fb.shown[0].Snooze, ","); got != "1m,start" {
+		t.Errorf("show() offered snooze choices %q, want 1m,start", got)
+	}
+}
+
+func TestShouldScheduleSnoozed(t *testing.T) {
+	n := &Notifier{
+		opts:      &Opts{},
+		stages:    []*stage{{before: time.Minute}},
+		processed: cache.New(),
+	}
+	it := &item.Item{
+		Title:    "standup",
+		JoinLink: "https://meet",
+		Version:  "1",
+		StartsIn: time.Minute * 10,
+	}
+	if _, rems := n.shouldSchedule(it); len(rems) != 1 || rems[0].wait != time.Minute*9 {
+		t.Errorf("shouldSchedule(%v) = _,%v, want one reminder in 9m", it, rems)
+	}
+	// A modified event that was snoozed waits for the snooze.
+	n.processed.Snooze(it, time.Now().Add(time.Minute*20))
+	modified := *it
+	modified.Version = "2"
+	if _, rems := n.shouldSchedule(&modified); len(rems) != 1 || rems[0].wait < time.Minute*19 {
+		t.Errorf("shouldSchedule(%v) of a snoozed event = _,%v, want one reminder in about 20m", &modified, rems)
+	}
+}
+
+func TestShouldScheduleCalendarReminders(t *testing.T) {
+	fb := &fakeBackend{}
+	for _, test := range []struct {
+		calendarReminders bool
+		reminders         []time.Duration
+		wantWaits         []time.Duration
+	}{
+		{calendarReminders: false, reminders: []time.Duration{time.Minute * 10}, wantWaits: []time.Duration{time.Minute * 29}},
+		{calendarReminders: true, reminders: []time.Duration{time.Minute * 10, 0}, wantWaits: []time.Duration{time.Minute * 20, time.Minute * 30}},
+		// Without reminders, the stages apply.
+		{calendarReminders: true, wantWaits: []time.Duration{time.Minute * 29}},
+	} {
+		n := &Notifier{
+			opts:      &Opts{CalendarReminders: test.calendarReminders},
+			named:     &stage{backends: []Backend{fb}},
+			stages:    []*stage{{before: time.Minute}},
+			processed: cache.New(),
+		}
+		it := &item.Item{
+			Title:     "standup",
+			JoinLink:  "https://meet",
+			StartsIn:  time.Minute * 30,
+			Reminders: test.reminders,
+		}
+		_, rems := n.shouldSchedule(it)
+		waits := []time.Duration{}
+		for _, r := range rems {
+			waits = append(waits, r.wait)
+		}
+		if !reflect.DeepEqual(waits, test.wantWaits) {
+			t.Errorf("shouldSchedule() with reminders %v, calendar reminders: %v = waits %v, want %v",
+				test.reminders, test.calendarReminders, waits, test.wantWaits)
+		}
+	}
+}
+
+func TestStages(t *testing.T) {
+	first := &fakeBackend{action: ActionSkip}
+	second := &fakeBackend{action: ActionSkip}
+	n := &Notifier{
+		opts: &Opts{},
+		stages: []*stage{
+			{before: time.Hour, backends: []Backend{first}},
+			{before: time.Hour - time.Millisecond*50, backends: []Backend{second}},
+		},
+		processed: cache.New(),
+		sched:     schedule.New(nil),
+	}
+	defer n.Close()
+	start := time.Now().Add(time.Hour)
+	it := &item.Item{
+		Title:    "standup",
+		JoinLink: "https://meet",
+		Version:  "1",
+		Start:    start,
+		StartsIn: time.Until(start),
+	}
+	n.Schedule(it)
+	time.Sleep(time.Millisecond * 20)
+
+	// Modifying the event doesn't repeat the stage that fired.
+	modified := *it
+	modified.Version = "2"
+	modified.StartsIn = time.Until(start)
+	n.Schedule(&modified)
+	time.Sleep(time.Millisecond * 100)
+
+	for _, test := range []struct {
+		name string
+		fb   *fakeBackend
+	}{
+		{name: "first", fb: first},
+		{name: "second", fb: second},
+	} {
+		test.fb.mu.Lock()
+		if len(test.fb.shown) != 1 {
+			t.Errorf("Schedule(): %v stage showed %v notifications, want 1", test.name, len(test.fb.shown))
+		}
+		test.fb.mu.Unlock()
+	}
+}
+
+// fakeAgendaBackend records agendas and whether it was closed.
+type fakeAgendaBackend struct {
+	fakeBackend
+	agenda []*item.Item
+	closed bool
+}
+
+func (f *fakeAgendaBackend) Agenda(items []*item.Item) {
+	f.agenda = items
+}
+
+func (f *fakeAgendaBackend) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestAgendaAndClose(t *testing.T) {
+	plain := &fakeBackend{}
+	fab := &fakeAgendaBackend{}
+	n := &Notifier{
+		opts:      &Opts{},
+		backends:  []Backend{plain, fab},
+		processed: cache.New(),
+	}
+	items := []*item.Item{{Title: "standup"}, {Title: "retro"}}
+	n.Agenda(items)
+	if len(fab.agenda) != 2 {
+		t.Errorf("Agenda(): backend got %v items, want 2", len(fab.agenda))
+	}
+	n.Close()
+	if !fab.closed {
+		t.Errorf("Close(): backend wasn't closed")
+	}
+}
+
+func TestSeriesPrefix(t *testing.T) {
+	st := &stage{before: time.Minute}
+	instance := func(id string) *item.Item {
+		return &item.Item{CalendarID: "primary", EventID: id, Event: &calendar.Event{Id: id, RecurringEventId: "abc"}}
+	}
+	prefix := seriesPrefix(instance("abc_1"))
+	if key := reminderKey(instance("abc_2"), st); !strings.HasPrefix(key, prefix) {
+		t.Errorf("reminderKey() of another instance = %q, want it to start with seriesPrefix() = %q", key, prefix)
+	}
+	if got := seriesPrefix(&item.Item{CalendarID: "primary", EventID: "abc"}); got != "" {
+		t.Errorf("seriesPrefix() of an event that doesn't recur = %q, want \"\"", got)
+	}
+}
+
+func TestMute(t *testing.T) {
+	list, err := mute.Load(filepath.Join(t.TempDir(), "muted.json"))
+	if err != nil {
+		t.Fatalf("mute.Load() = _,%v, require nil error", err)
+	}
+	fb := &fakeBackend{action: ActionMute}
+	st := &stage{before: time.Minute * 5, backends: []Backend{fb}}
+	n := &Notifier{
+		opts:      &Opts{Mute: list},
+		stages:    []*stage{st},
+		processed: cache.New(),
+		sched:     schedule.New(nil),
+	}
+	defer n.Close()
+	instance := func(day int) *item.Item {
+		start := time.Now().Add(time.Hour * 24 * time.Duration(day))
+		return &item.Item{
+			CalendarID: "primary",
+			EventID:    fmt.Sprintf("abc_%d", day),
+			Title:      "standup",
+			JoinLink:   "https://meet",
+			Version:    "1",
+			Start:      start,
+			StartsIn:   time.Until(start),
+			Event:      &calendar.Event{RecurringEventId: "abc"},
+		}
+	}
+
+	// Muting from a notification cancels the reminders of the other instances.
+	n.Schedule(instance(2))
+	if _, ok := n.sched.Due(reminderKey(instance(2), st)); !ok {
+		t.Fatalf("Schedule() didn't schedule a reminder")
+	}
+	n.show([]*item.Item{instance(1)}, st)
+	if !list.Muted(instance(1)) {
+		t.Errorf("show() with the mute action didn't mute the series")
+	}
+	if _, ok := n.sched.Due(reminderKey(instance(2), st)); ok {
+		t.Errorf("show() with the mute action didn't cancel the reminder of another instance")
+	}
+
+	// Instances of muted series are neither scheduled nor shown.
+	n.Schedule(instance(3))
+	if _, ok := n.sched.Due(reminderKey(instance(3), st)); ok {
+		t.Errorf("Schedule() of a muted series scheduled a reminder")
+	}
+	n.remind(instance(3), &reminder{stage: st}, time.Now())
+	if len(fb.shown) != 1 {
+		t.Errorf("remind() of a muted series showed %v notifications, want 1 from before muting", len(fb.shown))
+	}
+
+	// Unmuting schedules the next poll.
+	if ok, err := list.Unmute("abc"); !ok || err != nil {
+		t.Fatalf("Unmute() = %v,%v, require true,nil", ok, err)
+	}
+	n.Schedule(instance(3))
+	if _, ok := n.sched.Due(reminderKey(instance(3), st)); !ok {
+		t.Errorf("Schedule() after unmuting didn't schedule a reminder")
+	}
+}
+
+// newFakeClock is a helper to create a clock that only moves when advanced.
+func newFakeClock() *schedule.FakeClock {
+	return schedule.NewFakeClock(time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC))
+}
+
+func TestNotifierSchedule(t *testing.T) {
+	c := newFakeClock()
+	fb := &fakeBackend{action: ActionSkip}
+	st := &stage{before: time.Minute * 5, backends: []Backend{fb}}
+	n := &Notifier{
+		opts:      &Opts{},
+		stages:    []*stage{st},
+		processed: cache.New(),
+		sched:     schedule.New(c),
+	}
+	defer n.Close()
+	shown := func() int {
+		time.Sleep(time.Millisecond * 100)
+		fb.mu.Lock()
+		defer fb.mu.Unlock()
+		return len(fb.shown)
+	}
+
+	start := c.Now()
+	it := &item.Item{
+		EventID:  "standup",
+		Title:    "standup",
+		JoinLink: "https://meet",
+		Version:  "1",
+		StartsIn: time.Minute * 30,
+		Start:    start.Add(time.Minute * 30),
+	}
+	n.Schedule(it)
+	key := reminderKey(it, st)
+	if due, ok := n.sched.Due(key); !ok || !due.Equal(start.Add(time.Minute*25)) {
+		t.Fatalf("Schedule() due = %v,%v, want in 25m", due, ok)
+	}
+
+	// A modified event replaces the reminder.
+	moved := *it
+	moved.Version = "2"
+	moved.StartsIn = time.Minute * 40
+	moved.Start = start.Add(time.Minute * 40)
+	n.Schedule(&moved)
+	if due, ok := n.sched.Due(key); !ok || !due.Equal(start.Add(time.Minute*35)) {
+		t.Fatalf("Schedule() of a modified event due = %v,%v, want in 35m", due, ok)
+	}
+	c.Advance(time.Minute * 30)
+	if got := shown(); got != 0 {
+		t.Errorf("the reminder of the earlier version was shown %v times, want 0", got)
+	}
+	c.Advance(time.Minute * 5)
+	if got := shown(); got != 1 {
+		t.Errorf("the reminder was shown %v times, want 1", got)
+	}
+
+	// A reminder that becomes due while the machine sleeps is skipped when it wakes up after the start.
+	retro := &item.Item{
+		EventID:  "retro",
+		Title:    "retro",
+		JoinLink: "https://meet",
+		Version:  "1",
+		StartsIn: time.Minute * 10,
+		Start:    c.Now().Add(time.Minute * 10),
+	}
+	n.Schedule(retro)
+	c.Advance(time.Hour)
+	if got := shown(); got != 1 {
+		t.Errorf("after waking up past the start, reminders were shown %v times, want still 1", got)
+	}
+
+	// Within the grace window, it is shown. A new notifier doesn't disturb the reminders that ran.
+	n = &Notifier{
+		opts:      &Opts{Grace: time.Minute * 10},
+		stages:    []*stage{st},
+		processed: cache.New(),
+		sched:     schedule.New(c),
+	}
+	defer n.Close()
+	planning := &item.Item{
+		EventID:  "planning",
+		Title:    "planning",
+		JoinLink: "https://meet",
+		Version:  "1",
+		StartsIn: time.Minute * 10,
+		Start:    c.Now().Add(time.Minute * 10),
+		End:      c.Now().Add(time.Hour),
+	}
+	n.Schedule(planning)
+	c.Advance(time.Minute * 13)
+	if got := shown(); got != 2 {
+		t.Errorf("after waking up within the grace window, reminders were shown %v times, want 2", got)
+	}
+}
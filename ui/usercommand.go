@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/KarelKubat/goto-meet/config"
+)
+
+// outcome is a compiled config.Match.
+type outcome struct {
+	output   *regexp.Regexp
+	exitCode *int
+	action   Action
+}
+
+// newUserCommand creates a backend for a notifier from the configuration file.
+func newUserCommand(n *config.Notifier) (Backend, error) {
+	args, err := templates(n.Name, n.Escape, n.Command...)
+	if err != nil {
+		return nil, fmt.Errorf("notifier %q: cannot parse command: %v", n.Name, err)
+	}
+	tpl, err := newTemplate(n.Name, n.Escape, n.Template)
+	if err != nil {
+		return nil, fmt.Errorf("notifier %q: cannot parse template: %v", n.Name, err)
+	}
+	outcomes := []*outcome{}
+	for _, m := range n.Actions {
+		o, err := newOutcome(m)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %q: %v", n.Name, err)
+		}
+		outcomes = append(outcomes, o)
+	}
+
+	c := &command{
+		args:    args,
+		isModal: n.Modal,
+		parse: func(out []byte, err error) (Action, error) {
+			return parseOutcome(outcomes, out, err)
+		},
+	}
+	if n.Input == config.InputArgs {
+		c.args = append(c.args, tpl)
+	} else {
+		c.stdin = tpl
+	}
+	return c, nil
+}
+
+// newOutcome compiles a config.Match.
+func newOutcome(m *config.Match) (*outcome, error) {
+	re, err := regexp.Compile(m.Output)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compile %q: %v", m.Output, err)
+	}
+	action, err := parseAction(m.Action)
+	if err != nil {
+		return nil, err
+	}
+	return &outcome{
+		output:   re,
+		exitCode: m.ExitCode,
+		action:   action,
+	}, nil
+}
+
+// parseOutcome is a helper to map the output and exit code of a command to the action of the first
+// matching outcome. Commands that fail without a matching outcome are an error; commands that succeed
+// without one result in no action.
+func parseOutcome(outcomes []*outcome, out []byte, err error) (Action, error) {
+	code, ok := exitCode(err)
+	if !ok {
+		return ActionNone, fmt.Errorf("notifier failed, output: %v, error: %v", string(out), err)
+	}
+	for _, o := range outcomes {
+		if o.exitCode != nil && *o.exitCode != code {
+			continue
+		}
+		if !o.output.Match(out) {
+			continue
+		}
+		return o.action, nil
+	}
+	if code != 0 {
+		return ActionNone, fmt.Errorf("notifier failed, output: %v, error: %v", string(out), err)
+	}
+	return ActionNone, nil
+}
@@ -0,0 +1,201 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestNewUserCommand(t *testing.T) {
+	for _, test := range []struct {
+		notifier  *config.Notifier
+		wantError string
+	}{
+		{
+			notifier: &config.Notifier{
+				Name:    "ok",
+				Command: []string{"notify-send", "{{.Title}}"},
+				Actions: []*config.Match{{Output: "^J", Action: "join"}},
+			},
+		},
+		{
+			notifier: &config.Notifier{
+				Name:    "bad command",
+				Command: []string{"notify-send", "{{.Title"},
+			},
+			wantError: "cannot parse command",
+		},
+		{
+			notifier: &config.Notifier{
+				Name:     "bad template",
+				Command:  []string{"cat"},
+				Template: "{{if}}",
+			},
+			wantError: "cannot parse template",
+		},
+		{
+			notifier: &config.Notifier{
+				Name:    "bad regexp",
+				Command: []string{"cat"},
+				Actions: []*config.Match{{Output: "(", Action: "join"}},
+			},
+			wantError: "cannot compile",
+		},
+		{
+			notifier: &config.Notifier{
+				Name:    "bad action",
+				Command: []string{"cat"},
+				Actions: []*config.Match{{Action: "dance"}},
+			},
+			wantError: "no such action",
+		},
+	} {
+		_, err := newUserCommand(test.notifier)
+		switch {
+		case err == nil && test.wantError != "":
+			t.Errorf("newUserCommand(%q) = _,nil, want error with %q", test.notifier.Name, test.wantError)
+		case err != nil && test.wantError == "":
+			t.Errorf("newUserCommand(%q) = _,%v, want nil error", test.notifier.Name, err)
+		case err != nil && !strings.Contains(err.Error(), test.wantError):
+			t.Errorf("newUserCommand(%q) = _,%v, want error with %q", test.notifier.Name, err, test.wantError)
+		}
+	}
+}
+
+func TestUserCommandShow(t *testing.T) {
+	outFile := filepath.Join(t.TempDir(), "out")
+	for _, test := range []struct {
+		input      string
+		command    []string
+		wantOutput string
+		wantAction Action
+	}{
+		{
+			// The template is piped to stdin, the output selects an action
+			input:      config.InputStdin,
+			command:    []string{"sh", "-c", "cat > " + outFile + "; echo Calendar"},
+			wantOutput: "Location: office",
+			wantAction: ActionCalendar,
+		},
+		{
+			// The template is the last argument, the exit code selects an action
+			input:      config.InputArgs,
+			command:    []string{"sh", "-c", `echo "$0" > ` + outFile + "; exit 3"},
+			wantOutput: "Location: office\n",
+			wantAction: ActionSkip,
+		},
+	} {
+		b, err := newBackend("mine", &Opts{
+			Config: &config.Config{
+				Notifiers: []*config.Notifier{
+					{
+						Name:     "mine",
+						Command:  test.command,
+						Input:    test.input,
+						Template: "Location: {{.Item.Event.Location}}",
+						Actions: []*config.Match{
+							{Output: "^Join", Action: "join"},
+							{Output: "^Calendar", Action: "calendar"},
+							{ExitCode: intPtr(3), Action: "skip"},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("newBackend() = _,%v, require nil error", err)
+		}
+		it := &item.Item{Event: &calendar.Event{Location: "office"}}
+		action, err := b.Show(context.Background(), Notification{Item: it})
+		if err != nil {
+			t.Fatalf("%v: Show() = _,%v, require nil error", test.input, err)
+		}
+		if action != test.wantAction {
+			t.Errorf("%v: Show() = %v,_, want %v", test.input, action, test.wantAction)
+		}
+		out, err := os.ReadFile(outFile)
+		if err != nil {
+			t.Fatalf("cannot read back %q: %v", outFile, err)
+		}
+		if string(out) != test.wantOutput {
+			t.Errorf("%v: command received %q, want %q", test.input, string(out), test.wantOutput)
+		}
+	}
+}
+
+func TestUserCommandOverridesBuiltin(t *testing.T) {
+	b, err := newBackend("macos_osascript", &Opts{
+		Config: &config.Config{
+			Notifiers: []*config.Notifier{
+				{Name: "macos_osascript", Command: []string{"my-osascript"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newBackend() = _,%v, require nil error", err)
+	}
+	if c := b.(*command); c.args[0].Root.String() != "my-osascript" {
+		t.Errorf("newBackend(macos_osascript) runs %v, want the user-defined command", c.args[0].Root)
+	}
+}
+
+func TestParseOutcome(t *testing.T) {
+	outcomes := []*outcome{}
+	for _, m := range []*config.Match{
+		{Output: "^Join$", Action: "join"},
+		{ExitCode: intPtr(0), Output: "Cal", Action: "calendar"},
+		{ExitCode: intPtr(1), Action: "skip"},
+	} {
+		o, err := newOutcome(m)
+		if err != nil {
+			t.Fatalf("newOutcome(%+v) = _,%v, require nil error", m, err)
+		}
+		outcomes = append(outcomes, o)
+	}
+	exit1 := exitErr(t, 1)
+	exit2 := exitErr(t, 2)
+	for _, test := range []struct {
+		out        string
+		err        error
+		wantAction Action
+		wantError  bool
+	}{
+		{out: "Join", wantAction: ActionJoin},
+		{out: "Calendar", wantAction: ActionCalendar},
+		{out: "Calendar", err: exit1, wantAction: ActionSkip},
+		{out: "whatever", wantAction: ActionNone},
+		{out: "whatever", err: exit2, wantError: true},
+		{out: "Join", err: errors.New("cannot start"), wantError: true},
+	} {
+		action, err := parseOutcome(outcomes, []byte(test.out), test.err)
+		if (err != nil) != test.wantError {
+			t.Errorf("parseOutcome(_, %q, %v) = _,%v, want error: %v", test.out, test.err, err, test.wantError)
+		}
+		if action != test.wantAction {
+			t.Errorf("parseOutcome(_, %q, %v) = %v,_, want %v", test.out, test.err, action, test.wantAction)
+		}
+	}
+}
+
+// exitErr is a helper to obtain the error of a command that exits with the given code.
+func exitErr(t *testing.T, code int) error {
+	err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+	if err == nil {
+		t.Fatalf("cannot obtain an error for exit code %v", code)
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/l"
+)
+
+// webhookTpl is the default payload of a webhook. The "text" field is understood by Slack and
+// Mattermost incoming webhooks.
+const webhookTpl = `{
+  "text": {{if or .Started .Ending .AutoJoin}}{{printf "%s: %s" .Title .JoinLink}}{{else}}{{printf "%s starts at %s: %s" .Title (.Start | timefmt "15:04") .JoinLink}}{{end}},
+  "title": {{.Title}},
+  "start": {{.Start}},
+  "join_link": {{.JoinLink}},
+  "calendar_link": {{.CalendarLink}},
+  "notes": {{.Notes}},
+  "calendar": {{.Calendar}},
+  "attendees": {{.Attendees}}
+}
+`
+
+// webhook is a Backend that posts notifications to URLs. It can't report a chosen action.
+type webhook struct {
+	cfg    *config.Webhook
+	tpl    *template.Template
+	client *http.Client
+}
+
+// newWebhook creates a backend for a webhook from the configuration file.
+func newWebhook(w *config.Webhook) (Backend, error) {
+	text := w.Template
+	if text == "" {
+		text = webhookTpl
+	}
+	tpl, err := newTemplate(w.Name, EscapeJSON, text)
+	if err != nil {
+		return nil, fmt.Errorf("webhook %q: cannot parse template: %v", w.Name, err)
+	}
+	return &webhook{
+		cfg: w,
+		tpl: tpl,
+		client: &http.Client{
+			Timeout: time.Duration(w.Timeout),
+		},
+	}, nil
+}
+
+// detached implements detachedBackend.
+func (w *webhook) detached() bool {
+	return true
+}
+
+// Show implements Backend. The notification is posted to all URLs, errors are reported when any post
+// fails.
+func (w *webhook) Show(ctx context.Context, n Notification) (Action, error) {
+	buf := new(bytes.Buffer)
+	if err := w.tpl.Execute(buf, n); err != nil {
+		return ActionNone, fmt.Errorf("cannot execute template: %v", err)
+	}
+	errs := []string{}
+	for _, u := range w.cfg.URLs {
+		if err := w.post(ctx, u, buf.Bytes()); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return ActionNone, fmt.Errorf("webhook %q: %v", w.cfg.Name, strings.Join(errs, "; "))
+	}
+	return ActionNone, nil
+}
+
+// post is a helper to post a body to a URL, retrying with an increasing backoff when that fails.
+func (w *webhook) post(ctx context.Context, u string, body []byte) error {
+	backoff := time.Duration(w.cfg.Backoff)
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		if retry, err = w.postOnce(ctx, u, body); err == nil {
+			l.Infof("webhook %q: posted to %v", w.cfg.Name, u)
+			return nil
+		}
+		if !retry || attempt >= *w.cfg.Retries {
+			break
+		}
+		l.Warnf("webhook %q: attempt %v failed, retrying in %v: %v", w.cfg.Name, attempt+1, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+// postOnce is a helper to post a body to a URL. It returns an error when that fails, and true when
+// a retry makes sense: upon network errors, server errors and throttling.
+func (w *webhook) postOnce(ctx context.Context, u string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("cannot create request for %v: %v", u, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "goto-meet")
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if w.cfg.Secret != "" {
+		req.Header.Set(w.cfg.SignatureHeader, sign(w.cfg.Secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("cannot post to %v: %v", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("post to %v failed: %v %s", u, resp.Status, msg)
+}
+
+// sign is a helper to compute the signature of a body, as "sha256=" and the hex HMAC-SHA256.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,252 @@
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KarelKubat/goto-meet/config"
+	"github.com/KarelKubat/goto-meet/item"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// webhookServer records the requests that it receives. The first `failures` requests fail with
+// `status`.
+type webhookServer struct {
+	failures int
+	status   int
+	bodies   [][]byte
+	headers  []http.Header
+	mu       sync.Mutex
+}
+
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	s.bodies = append(s.bodies, body)
+	s.headers = append(s.headers, r.Header)
+	if len(s.bodies) <= s.failures {
+		w.WriteHeader(s.status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func newTestWebhook(t *testing.T, w *config.Webhook) Backend {
+	if w.Retries == nil {
+		r := 3
+		w.Retries = &r
+	}
+	if w.Backoff == 0 {
+		w.Backoff = config.Duration(time.Millisecond)
+	}
+	if w.SignatureHeader == "" {
+		w.SignatureHeader = config.DefaultSignatureHeader
+	}
+	b, err := newWebhook(w)
+	if err != nil {
+		t.Fatalf("newWebhook(%+v) = _,%v, require nil error", w, err)
+	}
+	return b
+}
+
+func TestWebhookDefaultPayload(t *testing.T) {
+	srv := &webhookServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	start := time.Date(2021, 11, 1, 10, 30, 0, 0, time.UTC)
+	it := &item.Item{
+		CalendarID:   "primary",
+		Title:        `Karel's "sync"`,
+		JoinLink:     "https://meet/abc",
+		CalendarLink: "https://calendar/abc",
+		Notes:        []string{"https://docs/agenda"},
+		Start:        start,
+		Event: &calendar.Event{
+			Attendees: []*calendar.EventAttendee{{Email: "a@example.com"}, {Email: "b@example.com"}},
+		},
+	}
+	b := newTestWebhook(t, &config.Webhook{
+		Name: "chat",
+		URLs: []string{ts.URL},
+	})
+	if _, err := b.Show(context.Background(), newNotification(it, 0)); err != nil {
+		t.Fatalf("Show() = _,%v, require nil error", err)
+	}
+
+	if len(srv.bodies) != 1 {
+		t.Fatalf("server received %v requests, want 1", len(srv.bodies))
+	}
+	var payload struct {
+		Text         string    `json:"text"`
+		Title        string    `json:"title"`
+		Start        time.Time `json:"start"`
+		JoinLink     string    `json:"join_link"`
+		CalendarLink string    `json:"calendar_link"`
+		Notes        []string  `json:"notes"`
+		Calendar     string    `json:"calendar"`
+		Attendees    []string  `json:"attendees"`
+	}
+	if err := json.Unmarshal(srv.bodies[0], &payload); err != nil {
+		t.Fatalf("server received invalid JSON %s: %v", srv.bodies[0], err)
+	}
+	if want := `Karel's "sync" starts at 10:30: https://meet/abc`; payload.Text != want {
+		t.Errorf("payload text = %q, want %q", payload.Text, want)
+	}
+	if payload.Title != it.Title || !payload.Start.Equal(start) || payload.JoinLink != it.JoinLink ||
+		payload.CalendarLink != it.CalendarLink || payload.Calendar != "primary" || len(payload.Attendees) != 2 ||
+		len(payload.Notes) != 1 || payload.Notes[0] != it.Notes[0] {
+		t.Errorf("payload = %+v, want the details of %v", payload, it)
+	}
+	if ct := srv.headers[0].Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	// Meetings that started have the message in their title.
+	late := newNotification(it, 0)
+	late.Started = time.Minute * 3
+	late.Title = startedTitle(it.Title, late.Started)
+	if _, err := b.Show(context.Background(), late); err != nil {
+		t.Fatalf("Show() = _,%v, require nil error", err)
+	}
+	if err := json.Unmarshal(srv.bodies[1], &payload); err != nil {
+		t.Fatalf("server received invalid JSON %s: %v", srv.bodies[1], err)
+	}
+	if want := `Karel's "sync": started 3 minutes ago — join now: https://meet/abc`; payload.Text != want {
+		t.Errorf("payload text = %q, want %q", payload.Text, want)
+	}
+	if sig := srv.headers[0].Get(config.DefaultSignatureHeader); sig != "" {
+		t.Errorf("%v = %q without a secret, want none", config.DefaultSignatureHeader, sig)
+	}
+}
+
+func TestWebhookTemplateAndSignature(t *testing.T) {
+	srv := &webhookServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	b := newTestWebhook(t, &config.Webhook{
+		Name:     "discord",
+		URLs:     []string{ts.URL, ts.URL},
+		Template: `{"content": {{.Title}}}`,
+		Headers:  map[string]string{"X-Extra": "yes"},
+		Secret:   "s3cr3t",
+	})
+	if _, err := b.Show(context.Background(), Notification{Title: `say "hi"`}); err != nil {
+		t.Fatalf("Show() = _,%v, require nil error", err)
+	}
+	if len(srv.bodies) != 2 {
+		t.Fatalf("server received %v requests, want 2 (one per URL)", len(srv.bodies))
+	}
+	if want := `{"content": "say \"hi\""}`; string(srv.bodies[0]) != want {
+		t.Errorf("server received %s, want %s", srv.bodies[0], want)
+	}
+	if got := srv.headers[0].Get("X-Extra"); got != "yes" {
+		t.Errorf("X-Extra = %q, want yes", got)
+	}
+	want := sign("s3cr3t", srv.bodies[0])
+	if got := srv.headers[0].Get(config.DefaultSignatureHeader); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	for _, test := range []struct {
+		failures     int
+		status       int
+		retries      int
+		wantRequests int
+		wantError    bool
+	}{
+		{
+			// Server errors are retried
+			failures:     2,
+			status:       http.StatusBadGateway,
+			retries:      3,
+			wantRequests: 3,
+		},
+		{
+			// Throttling is retried, until retries run out
+			failures:     10,
+			status:       http.StatusTooManyRequests,
+			retries:      2,
+			wantRequests: 3,
+			wantError:    true,
+		},
+		{
+			// Client errors aren't retried
+			failures:     10,
+			status:       http.StatusNotFound,
+			retries:      3,
+			wantRequests: 1,
+			wantError:    true,
+		},
+	} {
+		srv := &webhookServer{
+			failures: test.failures,
+			status:   test.status,
+		}
+		ts := httptest.NewServer(srv)
+		retries := test.retries
+		b := newTestWebhook(t, &config.Webhook{
+			Name:    "flaky",
+			URLs:    []string{ts.URL},
+			Retries: &retries,
+		})
+		_, err := b.Show(context.Background(), Notification{Title: "standup"})
+		ts.Close()
+		if (err != nil) != test.wantError {
+			t.Errorf("status %v: Show() = _,%v, want error: %v", test.status, err, test.wantError)
+		}
+		if len(srv.bodies) != test.wantRequests {
+			t.Errorf("status %v: server received %v requests, want %v", test.status, len(srv.bodies), test.wantRequests)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Reference: echo -n 'hello' | openssl dgst -sha256 -hmac key
+	want := "sha256=9307b3b915efb5171ff14d8cb55fbcc798c6c0ef1456d66ded1a6aa723a58b7b"
+	if got := sign("key", []byte("hello")); got != want {
+		t.Errorf("sign(key, hello) = %q, want %q", got, want)
+	}
+}
+
+func TestWebhookOutlivesAnswer(t *testing.T) {
+	// The server answers only after the user answered a dialog.
+	release := make(chan struct{})
+	posted := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		posted <- struct{}{}
+	}))
+	defer ts.Close()
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+
+	b := newTestWebhook(t, &config.Webhook{Name: "chat", URLs: []string{ts.URL}, Retries: new(int)})
+	if action := showAll(context.Background(), []Backend{b, &fakeBackend{action: ActionJoin}}, Notification{Title: "standup"}); action != ActionJoin {
+		t.Fatalf("showAll() = %v, want %v", action, ActionJoin)
+	}
+	close(release)
+	select {
+	case <-posted:
+	case <-time.After(time.Second * 5):
+		t.Errorf("the webhook wasn't posted after another backend answered")
+	}
+}